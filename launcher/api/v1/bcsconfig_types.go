@@ -35,6 +35,9 @@ type BcsConfigSpec struct {
 	Nmos                Nmos     `json:"nmos"`
 	ScheduleOnNode      []string `json:"scheduleOnNode,omitempty"`
 	DoNotScheduleOnNode []string `json:"doNotScheduleOnNode,omitempty"`
+	// SecurityProfile is minimal or privileged (default). The minimal profile requires App.Devices.
+	// +kubebuilder:validation:Enum=minimal;privileged
+	SecurityProfile string `json:"securityProfile,omitempty" jsonschema:"enum=minimal|privileged"`
 }
 
 type App struct {
@@ -43,6 +46,10 @@ type App struct {
 	EnvironmentVariables []EnvVar          `json:"environmentVariables"`
 	Volumes              map[string]string `json:"volumes"`
 	Resources            bcs.HwResources   `json:"resources,omitempty"`
+	// Devices are the extended resources of device plugins allocated to the container with their count, e.g.
+	// intel.com/intel_sriov_netdevice for a VF bound to vfio-pci or gpu.intel.com/i915 for a GPU. The minimal
+	// security profile requires them.
+	Devices map[string]int64 `json:"devices,omitempty"`
 }
 
 type EnvVar struct {
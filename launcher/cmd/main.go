@@ -20,10 +20,14 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -46,6 +50,8 @@ var (
 	scheme            = runtime.NewScheme()
 	setupLog          = ctrl.Log.WithName("[Setup]")
 	setupContainerLog = ctrl.Log.WithName("[Containerized setup]")
+	supervisorLog     = ctrl.Log.WithName("[Supervisor]")
+	logCollectorLog   = ctrl.Log.WithName("[Log collector]")
 	leaderElectionID  = "2d95eb0a.bcs.intel"
 )
 
@@ -63,9 +69,51 @@ func main() {
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var configPath string
+	var dockerAction string
+	var outputFormat string
+	var stopTimeout time.Duration
+	var onFailure string
+	var launcherID string
+	var containerRuntime string
+	var containerHost string
+	restartPolicy := containercontroller.DefaultRestartPolicy()
+	runOptions := containercontroller.DefaultRunOptions()
+	logOptions := containercontroller.DefaultLogOptions()
+	var logMaxSizeMiB int64
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.StringVar(&configPath, "bcs-config-path", "/etc/config/config.yaml", "The path to provide BCS config about mode and MCM objects.")
+	flag.StringVar(&configPath, "bcs-config-path", "/etc/config/config.yaml", "The path to provide BCS config about mode and MCM objects. "+
+		"Several files separated by commas, e.g. base.yaml,host-a.yaml, are merged in order in docker mode.")
+	flag.StringVar(&dockerAction, "action", "up", "The action executed in docker mode: up (create and run containers) | down (stop and remove containers) | "+
+		"supervise (create and run containers, then restart them when they fail) | plan (print what up would do without changing anything) | "+
+		"prune (stop and remove containers of this launcher that are no longer in the configuration) | status (report the state of the declared containers) | "+
+		"export-compose (print a Docker Compose file creating the same containers) | validate (check the configuration file for conflicts without a container runtime) | "+
+		"print-config (print the configuration merged from the configuration files and their includes).")
+	flag.StringVar(&launcherID, "launcher-id", "bcs-launcher", "The ID of this launcher instance in docker mode. It is stamped on every created container; "+
+		"prune removes only containers with the same ID.")
+	flag.StringVar(&containerRuntime, "container-runtime", string(containercontroller.RuntimeDocker), "The container runtime managing the containers in docker mode: docker | podman.")
+	flag.StringVar(&containerHost, "container-host", "", "The API socket of the container runtime, e.g. unix:///run/podman/podman.sock. "+
+		"Defaults to DOCKER_HOST or the Docker socket for docker and to CONTAINER_HOST or the rootful Podman socket for podman.")
+	flag.StringVar(&outputFormat, "output", "text", "The output format of the plan and status actions: text | json.")
+	flag.DurationVar(&runOptions.ReadinessTimeout, "readiness-timeout", runOptions.ReadinessTimeout, "The time a container gets in docker mode to start listening "+
+		"before the containers depending on it fail. 0 disables the readiness checks.")
+	flag.StringVar(&runOptions.Lockfile, "lockfile", "", "The lockfile pinning the images in docker mode. It is written with the digests of the images "+
+		"on the first run; later runs refuse to start containers when a local image does not match it. Empty disables image locking.")
+	flag.StringVar(&runOptions.HostRoot, "host-root", "/", "The root the proc and sys filesystems of the host are read from in docker mode, "+
+		"e.g. /host when the launcher runs in a container with the host root mounted there.")
+	flag.BoolVar(&runOptions.CheckHostPaths, "check-host-paths", runOptions.CheckHostPaths, "Whether the validate, up and supervise actions check that the NMOS json files "+
+		"and the sources of the volumes and devices exist under host-root. Disable it to validate a configuration file on another machine, e.g. in CI.")
+	flag.StringVar(&onFailure, "on-failure", string(runOptions.OnFailure), "What happens in docker mode when a container fails to start: "+
+		"rollback (remove every container created during the run) | keep-going (keep them and start everything not depending on the failed container).")
+	flag.StringVar(&logOptions.Dir, "log-dir", "", "The directory the logs of the containers are collected in docker mode. The supervise action follows them "+
+		"into rotating files; every action archives the logs of a container before removing it. Empty disables the log collection.")
+	flag.Int64Var(&logMaxSizeMiB, "log-max-size", logOptions.MaxSize>>20, "The size in MiB a log file of a container is rotated at.")
+	flag.DurationVar(&logOptions.MaxAge, "log-max-age", logOptions.MaxAge, "The age rotated log files and archived logs are removed at.")
+	flag.IntVar(&logOptions.MaxFiles, "log-max-files", logOptions.MaxFiles, "The number of rotated log files and archived logs kept per container.")
+	flag.DurationVar(&stopTimeout, "stop-timeout", containercontroller.DefaultStopTimeout, "The time given to a container to stop gracefully in docker mode before it is killed.")
+	flag.IntVar(&restartPolicy.MaxRestarts, "max-restarts", restartPolicy.MaxRestarts, "The number of times a failed container is restarted by the supervisor before it gives up.")
+	flag.DurationVar(&restartPolicy.InitialBackoff, "restart-backoff", restartPolicy.InitialBackoff, "The delay before the supervisor restarts a failed container. It doubles with every restart.")
+	flag.DurationVar(&restartPolicy.MaxBackoff, "restart-max-backoff", restartPolicy.MaxBackoff, "The upper bound of the delay between restarts of a failed container.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -81,32 +129,33 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	launcherStartupConfig := configPath
+	launcherStartupConfigs := strings.Split(configPath, ",")
 
-	fmt.Println("Argument passed:", launcherStartupConfig)
-	if _, err := os.Stat(launcherStartupConfig); err != nil {
-		setupLog.Error(err, "Error checking file", "file", launcherStartupConfig)
-		os.Exit(1)
+	setupLog.Info("Argument passed", "bcs-config-path", configPath)
+	for _, launcherStartupConfig := range launcherStartupConfigs {
+		if _, err := os.Stat(launcherStartupConfig); err != nil {
+			setupLog.Error(err, "Error checking file", "file", launcherStartupConfig)
+			os.Exit(1)
+		}
 	}
 
-	fmt.Println("Launcher configuration file exists")
+	setupLog.Info("Launcher configuration file exists")
 
-	isKubernetesMode, err := parser.ParseLauncherMode(launcherStartupConfig)
-	fmt.Println("Launcher mode: ", isKubernetesMode)
+	isKubernetesMode, err := parser.ParseLauncherMode(launcherStartupConfigs...)
 	if err != nil {
-		fmt.Println("Launcher mode: ", err)
 		setupLog.Error(err, "Failed to parse launcher mode")
 		os.Exit(1)
 	}
+	setupLog.Info("Launcher mode", "k8s", isKubernetesMode)
 
 	if !isKubernetesMode {
-		controller, err := containercontroller.NewDockerContainerController()
+		controller, err := containercontroller.NewContainerController(containercontroller.Runtime(containerRuntime), containerHost)
 		if err != nil {
-			setupContainerLog.Error(err, "Error creating DockerContainerController")
+			setupContainerLog.Error(err, "Error creating container controller", "runtime", containerRuntime)
 			os.Exit(1)
 		}
 		// Handle container configuration
-		config, err := parser.ParseLauncherConfiguration(launcherStartupConfig)
+		config, err := parser.ParseLauncherConfiguration(launcherStartupConfigs...)
 		if err != nil {
 			setupLog.Error(err, "Failed to parse launcher configuration file")
 			os.Exit(1)
@@ -115,8 +164,146 @@ func main() {
 			setupLog.Error(err, "Failed to parse launcher configuration file. Configuration is empty")
 			os.Exit(1)
 		}
-		if err := containercontroller.CreateAndRunContainers(ctx, controller, setupContainerLog, &config); err != nil {
-			setupLog.Error(err, "unable to create and run containers!")
+		config.LauncherID = launcherID
+		configFiles := make([]string, len(launcherStartupConfigs))
+		for i, launcherStartupConfig := range launcherStartupConfigs {
+			configFiles[i], err = filepath.Abs(launcherStartupConfig)
+			if err != nil {
+				setupLog.Error(err, "Failed to resolve path of launcher configuration file")
+				os.Exit(1)
+			}
+		}
+		config.ConfigFile = strings.Join(configFiles, ",")
+		var collector *containercontroller.LogCollector
+		if logOptions.Dir != "" {
+			logOptions.MaxSize = logMaxSizeMiB << 20
+			collector = containercontroller.NewLogCollector(controller, logCollectorLog, &config, logOptions)
+			controller = collector
+		}
+		runOptions.OnFailure = containercontroller.FailurePolicy(onFailure)
+		if runOptions.OnFailure != containercontroller.FailurePolicyRollback && runOptions.OnFailure != containercontroller.FailurePolicyKeepGoing {
+			setupLog.Error(fmt.Errorf("unknown failure policy %q", onFailure), "Unsupported value of on-failure flag")
+			os.Exit(1)
+		}
+		switch dockerAction {
+		case "up", "supervise":
+			if err := containercontroller.PinPipelines(setupContainerLog, &config, runOptions.HostRoot); err != nil {
+				setupLog.Error(err, "unable to pin pipelines to the cores of their NUMA node")
+				os.Exit(1)
+			}
+		case "plan", "status", "export-compose":
+			// read-only actions show the pinning when the host allows it, but do not need it
+			if err := containercontroller.PinPipelines(setupContainerLog, &config, runOptions.HostRoot); err != nil {
+				setupLog.Info("Pipelines are shown without CPU pinning, the cores of their NUMA node cannot be allocated", "error", err.Error())
+			}
+		}
+		switch dockerAction {
+		case "up":
+			if err := createAndRunContainers(ctx, controller, &config, runOptions); err != nil {
+				setupLog.Error(err, "unable to create and run containers!")
+				os.Exit(1)
+			}
+		case "supervise":
+			if err := createAndRunContainers(ctx, controller, &config, runOptions); err != nil {
+				setupLog.Error(err, "unable to create and run containers!")
+				os.Exit(1)
+			}
+			if collector != nil {
+				go func() {
+					if err := collector.Run(ctx); err != nil {
+						setupLog.Error(err, "problem collecting container logs")
+					}
+				}()
+			}
+			supervisor := containercontroller.NewSupervisor(controller, supervisorLog, &config, restartPolicy)
+			if err := supervisor.Run(ctx); err != nil {
+				setupLog.Error(err, "problem running supervisor")
+				os.Exit(1)
+			}
+		case "plan":
+			plan, err := containercontroller.PlanContainers(ctx, controller, setupContainerLog, &config)
+			if err != nil {
+				setupLog.Error(err, "unable to plan containers!")
+				os.Exit(1)
+			}
+			switch outputFormat {
+			case "text":
+				err = plan.WriteText(os.Stdout)
+			case "json":
+				err = plan.WriteJSON(os.Stdout)
+			default:
+				err = fmt.Errorf("unknown output format %q", outputFormat)
+			}
+			if err != nil {
+				setupLog.Error(err, "unable to print the plan")
+				os.Exit(1)
+			}
+		case "status":
+			status, err := containercontroller.StatusContainers(ctx, controller, setupContainerLog, &config)
+			if err != nil {
+				setupLog.Error(err, "unable to get status of containers!")
+				os.Exit(1)
+			}
+			switch outputFormat {
+			case "text":
+				err = status.WriteTable(os.Stdout)
+			case "json":
+				err = status.WriteJSON(os.Stdout)
+			default:
+				err = fmt.Errorf("unknown output format %q", outputFormat)
+			}
+			if err != nil {
+				setupLog.Error(err, "unable to print the status")
+				os.Exit(1)
+			}
+		case "validate":
+			err := containercontroller.ValidateConfiguration(&config, containercontroller.ValidateOptions{HostPaths: runOptions.CheckHostPaths, HostRoot: runOptions.HostRoot})
+			if err != nil {
+				fmt.Println(err)
+				setupLog.Error(fmt.Errorf("configuration file %s has errors", config.ConfigFile), "invalid launcher configuration")
+				os.Exit(1)
+			}
+			fmt.Println("Configuration file", config.ConfigFile, "is valid")
+		case "export-compose":
+			project, err := containercontroller.ExportCompose(setupContainerLog, &config)
+			if err != nil {
+				setupLog.Error(err, "unable to export compose file!")
+				os.Exit(1)
+			}
+			if err := project.WriteYAML(os.Stdout); err != nil {
+				setupLog.Error(err, "unable to print the compose file")
+				os.Exit(1)
+			}
+		case "print-config":
+			effective, err := parser.EffectiveLauncherConfiguration(launcherStartupConfigs...)
+			if err != nil {
+				setupLog.Error(err, "unable to merge launcher configuration files")
+				os.Exit(1)
+			}
+			if _, err := os.Stdout.Write(effective); err != nil {
+				setupLog.Error(err, "unable to print the configuration")
+				os.Exit(1)
+			}
+		case "prune":
+			report, err := containercontroller.PruneContainers(ctx, controller, setupContainerLog, &config, stopTimeout)
+			fmt.Println("Stopped containers:", report.Stopped)
+			fmt.Println("Removed containers:", report.Removed)
+			if err != nil {
+				setupLog.Error(err, "unable to prune containers!")
+				os.Exit(1)
+			}
+		case "down":
+			report, err := containercontroller.StopAndRemoveContainers(ctx, controller, setupContainerLog, &config, stopTimeout)
+			fmt.Println("Stopped containers:", report.Stopped)
+			fmt.Println("Containers already stopped:", report.AlreadyStopped)
+			fmt.Println("Removed containers:", report.Removed)
+			fmt.Println("Containers missing:", report.Missing)
+			if err != nil {
+				setupLog.Error(err, "unable to stop and remove containers!")
+				os.Exit(1)
+			}
+		default:
+			setupLog.Error(fmt.Errorf("unknown action %q", dockerAction), "Unsupported action in docker mode")
 			os.Exit(1)
 		}
 	} else {
@@ -177,3 +364,14 @@ func main() {
 		}
 	}
 }
+
+// createAndRunContainers starts the containers of the launcher configuration and prints the summary of the run.
+func createAndRunContainers(ctx context.Context, controller containercontroller.ContainerController, config *parser.Configuration, runOptions containercontroller.RunOptions) error {
+	report, err := containercontroller.CreateAndRunContainersWithOptions(ctx, controller, setupContainerLog, config, runOptions)
+	if len(report.Entries) > 0 {
+		if err := report.WriteTable(os.Stdout); err != nil {
+			setupLog.Error(err, "unable to print the summary of the run")
+		}
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+// Command schemagen writes the JSON Schemas of the configuration files of BCS launcher. The schemas are generated
+// from the Go types the files are decoded into, run go generate ./cmd/schemagen after changing them.
+package main
+
+//go:generate go run . -out ../../schemas
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	bcsv1 "bcs.pod.launcher.intel/api/v1"
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/nmos"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// publishedSchema is a schema written to the schemas directory.
+type publishedSchema struct {
+	File  string
+	Title string
+	Type  reflect.Type
+	Tag   string
+}
+
+var publishedSchemas = []publishedSchema{
+	{File: "bcs-launcher.schema.json", Title: "BCS launcher configuration (docker mode)", Type: reflect.TypeOf(parser.Config{}), Tag: "yaml"},
+	{File: "bcsconfig.schema.json", Title: "BcsConfig custom resource", Type: reflect.TypeOf(bcsv1.BcsConfig{}), Tag: "json"},
+	{File: "nmos-node.schema.json", Title: "NMOS node configuration", Type: reflect.TypeOf(nmos.Config{}), Tag: "json"},
+}
+
+// generate returns the schema of a published schema. The metadata of a custom resource is checked by Kubernetes.
+func (p publishedSchema) generate() *parser.Schema {
+	generator := parser.SchemaGenerator{Tag: p.Tag, Overrides: map[reflect.Type]*parser.Schema{
+		reflect.TypeOf(metav1.ObjectMeta{}): {Type: parser.SchemaTypes{"object"}},
+	}}
+	schema := generator.Generate(p.Type)
+	schema.Draft = parser.SchemaDraft
+	schema.Title = p.Title
+	return schema
+}
+
+// render returns the schema as it is written to its file.
+func (p publishedSchema) render() ([]byte, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(p.generate()); err != nil {
+		return nil, fmt.Errorf("%s: %w", p.File, err)
+	}
+	return out.Bytes(), nil
+}
+
+func main() {
+	var outDir string
+	flag.StringVar(&outDir, "out", "schemas", "The directory the schemas are written to.")
+	flag.Parse()
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, published := range publishedSchemas {
+		data, err := published.render()
+		if err == nil {
+			err = os.WriteFile(filepath.Join(outDir, published.File), data, 0644)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
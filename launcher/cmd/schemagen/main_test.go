@@ -0,0 +1,157 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"github.com/stretchr/testify/assert"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+func TestSchemasUpToDate(t *testing.T) {
+	for _, published := range publishedSchemas {
+		expected, err := published.render()
+		assert.NoError(t, err)
+		written, err := os.ReadFile(filepath.Join("../../schemas", published.File))
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(written), "schemas/%s is out of date, run go generate ./cmd/schemagen", published.File)
+	}
+}
+
+func TestShippedFilesMatchSchemas(t *testing.T) {
+	schemas := map[string]*parser.Schema{}
+	for _, published := range publishedSchemas {
+		schemas[published.File] = published.generate()
+	}
+	files := map[string]string{
+		"../../configuration_files/bcsconfig-k8s-custom-resource-example.yaml": "bcsconfig.schema.json",
+	}
+	launcherFiles, err := filepath.Glob("../../configuration_files/bcslauncher-static-config-*.yaml")
+	assert.NoError(t, err)
+	for _, file := range launcherFiles {
+		files[file] = "bcs-launcher.schema.json"
+	}
+	for file, schema := range files {
+		data, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.NoError(t, parser.CheckSchema(file, data, schemas[schema]), file)
+	}
+}
+
+func TestSchemaEnums(t *testing.T) {
+	var nmosSchema *parser.Schema
+	for _, published := range publishedSchemas {
+		if published.File == "nmos-node.schema.json" {
+			nmosSchema = published.generate()
+		}
+	}
+	err := parser.CheckSchema("nmos.json", []byte(`{
+  "gpu_hw_acceleration": "amd",
+  "sender": [{
+    "stream_payload": {"video": {"pixel_format": "yuv422p10le"}},
+    "stream_type": {"st2110": {"transport": "st2110-21"}}
+  }],
+  "receiver": [{
+    "stream_payload": {"video": {"pixel_format": "yuv422p10"}},
+    "stream_type": {"mcm": {"conn_type": "st2110", "transport": "st2110-20", "transportPixelFormat": "yuv422p10rfc4175"}}
+  }]
+}`), nmosSchema)
+	assert.EqualError(t, err, `nmos.json:2:26: gpu_hw_acceleration: expected one of "none", "intel", "nvidia", got "amd"
+nmos.json:5:45: sender[0].stream_type.st2110.transport: expected one of "st2110-20", "st2110-22", "st2110-30", got "st2110-21"
+nmos.json:8:50: receiver[0].stream_payload.video.pixel_format: expected one of "", "yuv422p10le", "y210le", "yuv422p", "yuv420p", "yuv444p10le", "rgb24", got "yuv422p10"`)
+}
+
+// TestCRDMatchesSchema keeps the hand-written schema of the BcsConfig CRD, the one Kubernetes validates custom
+// resources with, in line with the BcsConfigSpec type.
+func TestCRDMatchesSchema(t *testing.T) {
+	data, err := os.ReadFile("../../configuration_files/bcsconfig-crd.yaml")
+	assert.NoError(t, err)
+	var crd struct {
+		Spec struct {
+			Versions []struct {
+				Schema struct {
+					OpenAPIV3Schema map[string]interface{} `yaml:"openAPIV3Schema"`
+				} `yaml:"schema"`
+			} `yaml:"versions"`
+		} `yaml:"spec"`
+	}
+	assert.NoError(t, yamlv3.Unmarshal(data, &crd))
+	if !assert.NotEmpty(t, crd.Spec.Versions) {
+		return
+	}
+	crdSpec := crd.Spec.Versions[0].Schema.OpenAPIV3Schema["properties"].(map[string]interface{})["spec"].(map[string]interface{})
+
+	for _, published := range publishedSchemas {
+		if published.File == "bcsconfig.schema.json" {
+			root := published.generate()
+			assert.Empty(t, compareCRD("spec", crdSpec, root.Properties["spec"], root))
+		}
+	}
+}
+
+// compareCRD lists the differences between the OpenAPI schema of a CRD and a generated schema.
+func compareCRD(path string, crd map[string]interface{}, schema, root *parser.Schema) []string {
+	for schema.Ref != "" {
+		schema = root.Definitions[strings.TrimPrefix(schema.Ref, "#/definitions/")]
+	}
+	var differences []string
+	if crd["type"] != schema.Type[0] {
+		differences = append(differences, fmt.Sprintf("%s: type %v in the CRD, %s in the schema", path, crd["type"], schema.Type[0]))
+	}
+	var crdEnum []string
+	if values, ok := crd["enum"].([]interface{}); ok {
+		for _, value := range values {
+			crdEnum = append(crdEnum, fmt.Sprint(value))
+		}
+	}
+	if !reflect.DeepEqual(crdEnum, schema.Enum) {
+		differences = append(differences, fmt.Sprintf("%s: enum %v in the CRD, %v in the schema", path, crdEnum, schema.Enum))
+	}
+	if schema.Items != nil {
+		items, _ := crd["items"].(map[string]interface{})
+		differences = append(differences, compareCRD(path+"[]", items, schema.Items, root)...)
+	}
+	if values, ok := schema.AdditionalProperties.(*parser.Schema); ok {
+		crdValues, _ := crd["additionalProperties"].(map[string]interface{})
+		differences = append(differences, compareCRD(path+"{}", crdValues, values, root)...)
+	}
+
+	crdProperties, _ := crd["properties"].(map[string]interface{})
+	names := map[string]bool{}
+	for name := range crdProperties {
+		names[name] = true
+	}
+	for name := range schema.Properties {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		crdProperty, inCRD := crdProperties[name].(map[string]interface{})
+		property, inSchema := schema.Properties[name]
+		switch {
+		case !inCRD:
+			differences = append(differences, fmt.Sprintf("%s.%s is missing in the CRD", path, name))
+		case !inSchema:
+			differences = append(differences, fmt.Sprintf("%s.%s is not a field of the Go type", path, name))
+		default:
+			differences = append(differences, compareCRD(path+"."+name, crdProperty, property, root)...)
+		}
+	}
+	return differences
+}
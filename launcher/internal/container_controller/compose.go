@@ -0,0 +1,333 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/resources/nmos"
+	"bcs.pod.launcher.intel/resources_library/utils"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v2"
+)
+
+// ComposeProject is a Docker Compose file creating the same containers and networks as the launcher.
+type ComposeProject struct {
+	Name     string                    `yaml:"name,omitempty"`
+	Services map[string]ComposeService `yaml:"services"`
+	Networks map[string]ComposeNetwork `yaml:"networks,omitempty"`
+	// Warnings list what the compose file cannot express or what has to be done before it is used.
+	Warnings []string `yaml:"-"`
+}
+
+// ComposeService is a service of the compose file. Every field is taken from the container settings
+// ConstructContainerConfig returns, so compose creates the container the launcher would create.
+type ComposeService struct {
+	ContainerName     string                           `yaml:"container_name"`
+	Image             string                           `yaml:"image"`
+	User              string                           `yaml:"user,omitempty"`
+	Command           []string                         `yaml:"command,omitempty"`
+	Environment       []string                         `yaml:"environment,omitempty"`
+	Labels            map[string]string                `yaml:"labels,omitempty"`
+	Expose            []string                         `yaml:"expose,omitempty"`
+	Ports             []ComposePort                    `yaml:"ports,omitempty"`
+	NetworkMode       string                           `yaml:"network_mode,omitempty"`
+	Networks          map[string]ComposeServiceNetwork `yaml:"networks,omitempty"`
+	Volumes           []ComposeVolume                  `yaml:"volumes,omitempty"`
+	Devices           []string                         `yaml:"devices,omitempty"`
+	DeviceCgroupRules []string                         `yaml:"device_cgroup_rules,omitempty"`
+	Ipc               string                           `yaml:"ipc,omitempty"`
+	Privileged        bool                             `yaml:"privileged,omitempty"`
+	CapAdd            []string                         `yaml:"cap_add,omitempty"`
+	CapDrop           []string                         `yaml:"cap_drop,omitempty"`
+	Cpus              float64                          `yaml:"cpus,omitempty"`
+	CPUShares         int64                            `yaml:"cpu_shares,omitempty"`
+	Cpuset            string                           `yaml:"cpuset,omitempty"`
+	MemLimit          int64                            `yaml:"mem_limit,omitempty"`
+	MemReservation    int64                            `yaml:"mem_reservation,omitempty"`
+	Healthcheck       *ComposeHealthcheck              `yaml:"healthcheck,omitempty"`
+	Restart           string                           `yaml:"restart,omitempty"`
+	DependsOn         map[string]ComposeDependency     `yaml:"depends_on,omitempty"`
+}
+
+type ComposePort struct {
+	Target    int    `yaml:"target"`
+	Published string `yaml:"published,omitempty"`
+	HostIP    string `yaml:"host_ip,omitempty"`
+	Protocol  string `yaml:"protocol,omitempty"`
+}
+
+type ComposeServiceNetwork struct {
+	IPv4Address string   `yaml:"ipv4_address,omitempty"`
+	Aliases     []string `yaml:"aliases,omitempty"`
+}
+
+// ComposeVolume is a volume of a service, written in the short syntax when Short is set, e.g. for the binds
+// of MCM Media Proxy, and in the long syntax otherwise.
+type ComposeVolume struct {
+	Short    string `yaml:"-"`
+	Type     string `yaml:"type,omitempty"`
+	Source   string `yaml:"source,omitempty"`
+	Target   string `yaml:"target,omitempty"`
+	ReadOnly bool   `yaml:"read_only,omitempty"`
+}
+
+func (v ComposeVolume) MarshalYAML() (interface{}, error) {
+	if v.Short != "" {
+		return v.Short, nil
+	}
+	type long ComposeVolume
+	return long(v), nil
+}
+
+type ComposeHealthcheck struct {
+	Test        []string `yaml:"test,omitempty"`
+	Interval    string   `yaml:"interval,omitempty"`
+	Timeout     string   `yaml:"timeout,omitempty"`
+	StartPeriod string   `yaml:"start_period,omitempty"`
+	Retries     int      `yaml:"retries,omitempty"`
+	Disable     bool     `yaml:"disable,omitempty"`
+}
+
+// ComposeDependency is an entry of depends_on. A dependency with a health check has to be healthy,
+// which stands in for the readiness gate of the launcher.
+type ComposeDependency struct {
+	Condition string `yaml:"condition"`
+}
+
+type ComposeNetwork struct {
+	Name       string            `yaml:"name"`
+	Driver     string            `yaml:"driver,omitempty"`
+	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
+	Ipam       *ComposeIPAM      `yaml:"ipam,omitempty"`
+}
+
+type ComposeIPAM struct {
+	Driver  string              `yaml:"driver,omitempty"`
+	Config  []ComposeIPAMConfig `yaml:"config,omitempty"`
+	Options map[string]string   `yaml:"options,omitempty"`
+}
+
+type ComposeIPAMConfig struct {
+	Subnet  string `yaml:"subnet,omitempty"`
+	IPRange string `yaml:"ip_range,omitempty"`
+	Gateway string `yaml:"gateway,omitempty"`
+}
+
+// ExportCompose converts the launcher configuration into a compose project. The services are built from the same
+// container settings CreateAndRunContainers uses, including the configuration hash label, so the launcher treats
+// containers created by compose as up to date. Nothing is changed on the host: the NMOS json files are not rewritten,
+// a warning tells when one does not point to its FFmpeg pipeline yet.
+func ExportCompose(log logr.Logger, config *parser.Configuration) (ComposeProject, error) {
+	project := ComposeProject{Name: config.LauncherID, Services: map[string]ComposeService{}}
+	if err := ValidateConfiguration(config, ValidateOptions{}); err != nil {
+		return project, err
+	}
+
+	requests, err := requestedNetworks(config)
+	if err != nil {
+		return project, err
+	}
+	for _, request := range requests {
+		project.addNetwork(request)
+	}
+
+	nodes := startupGraph(config)
+	specs := make([]ContainerSpec, len(nodes))
+	for i, node := range nodes {
+		specs[i], err = newContainerSpec(utils.PreviewContainerConfig(&node.info, config, log))
+		if err != nil {
+			return project, fmt.Errorf("failed to export container %s: %w", node.info.ContainerName, err)
+		}
+		service, warnings := composeService(node.info.ContainerName, specs[i])
+		project.Warnings = append(project.Warnings, warnings...)
+		for _, dependency := range node.dependsOn {
+			if service.DependsOn == nil {
+				service.DependsOn = map[string]ComposeDependency{}
+			}
+			condition := "service_started"
+			if hasHealthcheck(specs[dependency].Config) {
+				condition = "service_healthy"
+			}
+			service.DependsOn[nodes[dependency].info.ContainerName] = ComposeDependency{Condition: condition}
+		}
+		project.Services[node.info.ContainerName] = service
+
+		if node.info.Type == general.BcsPipelineNmosClient {
+			if warning := nmosConfigWarning(config, node.info.Id); warning != "" {
+				project.Warnings = append(project.Warnings, warning)
+			}
+		}
+	}
+	return project, nil
+}
+
+// addNetwork declares a custom network the way EnsureNetworks creates it. Compose creates it when it is missing
+// and uses an existing network of the same name.
+func (p *ComposeProject) addNetwork(request *networkRequest) {
+	options := request.createOptions()
+	composeNetwork := ComposeNetwork{Name: request.name, Driver: options.Driver, DriverOpts: options.Options}
+	if options.IPAM != nil {
+		composeNetwork.Ipam = &ComposeIPAM{Driver: options.IPAM.Driver, Options: options.IPAM.Options}
+		for _, ipamConfig := range options.IPAM.Config {
+			composeNetwork.Ipam.Config = append(composeNetwork.Ipam.Config, ComposeIPAMConfig{Subnet: ipamConfig.Subnet, IPRange: ipamConfig.IPRange, Gateway: ipamConfig.Gateway})
+		}
+	}
+	if p.Networks == nil {
+		p.Networks = map[string]ComposeNetwork{}
+	}
+	p.Networks[request.name] = composeNetwork
+}
+
+// hasHealthcheck tells whether a container gets a health check, its own or the one of its image.
+func hasHealthcheck(config *container.Config) bool {
+	return config.Healthcheck != nil && (len(config.Healthcheck.Test) == 0 || config.Healthcheck.Test[0] != "NONE")
+}
+
+// composeService converts the settings of a container into a compose service. The warnings list the settings
+// compose cannot express.
+func composeService(name string, spec ContainerSpec) (ComposeService, []string) {
+	var warnings []string
+	config, hostConfig := spec.Config, spec.HostConfig
+	service := ComposeService{
+		ContainerName:     name,
+		Image:             config.Image,
+		User:              config.User,
+		Command:           config.Cmd,
+		Environment:       config.Env,
+		Labels:            config.Labels,
+		Ipc:               string(hostConfig.IpcMode),
+		Privileged:        hostConfig.Privileged,
+		CapAdd:            hostConfig.CapAdd,
+		CapDrop:           hostConfig.CapDrop,
+		DeviceCgroupRules: hostConfig.DeviceCgroupRules,
+		Cpus:              float64(hostConfig.NanoCPUs) / 1e9,
+		CPUShares:         hostConfig.CPUShares,
+		Cpuset:            hostConfig.CpusetCpus,
+		MemLimit:          hostConfig.Memory,
+		MemReservation:    hostConfig.MemoryReservation,
+	}
+	if hostConfig.CpusetMems != "" {
+		warnings = append(warnings, fmt.Sprintf("service %s: compose cannot restrict memory nodes, cpusetMems %s is not applied", name, hostConfig.CpusetMems))
+	}
+
+	for port := range config.ExposedPorts {
+		service.Expose = append(service.Expose, string(port))
+	}
+	sort.Strings(service.Expose)
+	for port, bindings := range hostConfig.PortBindings {
+		for _, binding := range bindings {
+			service.Ports = append(service.Ports, ComposePort{Target: port.Int(), Published: binding.HostPort, HostIP: binding.HostIP, Protocol: port.Proto()})
+		}
+	}
+	sort.Slice(service.Ports, func(i, j int) bool { return service.Ports[i].Target < service.Ports[j].Target })
+
+	mode := string(hostConfig.NetworkMode)
+	if endpoints := spec.Networking.EndpointsConfig; len(endpoints) > 0 && !predefinedNetworks[mode] {
+		service.Networks = map[string]ComposeServiceNetwork{}
+		for networkName, endpoint := range endpoints {
+			serviceNetwork := ComposeServiceNetwork{Aliases: endpoint.Aliases}
+			if endpoint.IPAMConfig != nil {
+				serviceNetwork.IPv4Address = endpoint.IPAMConfig.IPv4Address
+			}
+			service.Networks[networkName] = serviceNetwork
+		}
+	} else {
+		service.NetworkMode = mode
+	}
+
+	for _, bind := range hostConfig.Binds {
+		service.Volumes = append(service.Volumes, ComposeVolume{Short: bind})
+	}
+	for _, m := range hostConfig.Mounts {
+		service.Volumes = append(service.Volumes, ComposeVolume{Type: string(m.Type), Source: m.Source, Target: m.Target, ReadOnly: m.ReadOnly})
+	}
+	for _, device := range hostConfig.Devices {
+		mapping := device.PathOnHost + ":" + device.PathInContainer
+		if device.CgroupPermissions != "" {
+			mapping += ":" + device.CgroupPermissions
+		}
+		service.Devices = append(service.Devices, mapping)
+	}
+
+	if healthcheck := config.Healthcheck; healthcheck != nil {
+		service.Healthcheck = &ComposeHealthcheck{Retries: healthcheck.Retries}
+		if len(healthcheck.Test) > 0 && healthcheck.Test[0] == "NONE" {
+			service.Healthcheck.Disable = true
+		} else {
+			service.Healthcheck.Test = healthcheck.Test
+		}
+		service.Healthcheck.Interval = composeDuration(healthcheck.Interval)
+		service.Healthcheck.Timeout = composeDuration(healthcheck.Timeout)
+		service.Healthcheck.StartPeriod = composeDuration(healthcheck.StartPeriod)
+	}
+
+	service.Restart = string(hostConfig.RestartPolicy.Name)
+	if hostConfig.RestartPolicy.Name == container.RestartPolicyOnFailure && hostConfig.RestartPolicy.MaximumRetryCount > 0 {
+		service.Restart += ":" + strconv.Itoa(hostConfig.RestartPolicy.MaximumRetryCount)
+	}
+	return service, warnings
+}
+
+// composeDuration formats a duration of a health check, e.g. 1m30s. Zero keeps the default of Docker.
+func composeDuration(duration time.Duration) string {
+	if duration <= 0 {
+		return ""
+	}
+	return duration.String()
+}
+
+// nmosConfigWarning returns a warning when the NMOS json file of a workload does not point to its FFmpeg pipeline.
+// CreateAndRunContainers rewrites the file, or renders it when it has references, before it creates the NMOS client,
+// compose does not.
+func nmosConfigWarning(config *parser.Configuration, id int) string {
+	path := utils.NmosRenderedFilePath(config, id)
+	pipeline := config.WorkloadToBeRun[id].FfmpegPipeline
+	data, err := os.ReadFile(path)
+	if err != nil && path != utils.NmosJsonFilePath(config, id) {
+		return fmt.Sprintf("NMOS json file %s references environment variables or secret files and is not rendered to %s yet: %v",
+			utils.NmosJsonFilePath(config, id), path, err)
+	}
+	if err != nil {
+		return fmt.Sprintf("NMOS json file %s cannot be read: %v", path, err)
+	}
+	var nmosConfig nmos.Config
+	if err := json.Unmarshal(data, &nmosConfig); err != nil {
+		return fmt.Sprintf("NMOS json file %s cannot be parsed: %v", path, err)
+	}
+	if nmosConfig.FfmpegGrpcServerAddress != pipeline.Network.IP || nmosConfig.FfmpegGrpcServerPort != strconv.Itoa(pipeline.GRPCPort) {
+		return fmt.Sprintf("NMOS json file %s does not point to FFmpeg pipeline %s yet: set ffmpeg_grpc_server_address to %q and ffmpeg_grpc_server_port to %q",
+			path, pipeline.Name, pipeline.Network.IP, strconv.Itoa(pipeline.GRPCPort))
+	}
+	return ""
+}
+
+// WriteYAML writes the compose file, preceded by the warnings as comments.
+func (p ComposeProject) WriteYAML(w io.Writer) error {
+	var b strings.Builder
+	for _, warning := range p.Warnings {
+		fmt.Fprintf(&b, "# WARNING: %s\n", warning)
+	}
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	b.Write(data)
+	_, err = io.WriteString(w, b.String())
+	return err
+}
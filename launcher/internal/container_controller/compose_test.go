@@ -0,0 +1,220 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/utils"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+// serviceContainerSpec converts a compose service back into the container settings compose creates the container with.
+func serviceContainerSpec(t *testing.T, service ComposeService) ContainerSpec {
+	config := &container.Config{
+		Image:  service.Image,
+		User:   service.User,
+		Cmd:    service.Command,
+		Env:    service.Environment,
+		Labels: service.Labels,
+	}
+	if len(service.Expose) > 0 {
+		config.ExposedPorts = nat.PortSet{}
+		for _, port := range service.Expose {
+			config.ExposedPorts[nat.Port(port)] = struct{}{}
+		}
+	}
+	if healthcheck := service.Healthcheck; healthcheck != nil {
+		config.Healthcheck = &container.HealthConfig{Test: healthcheck.Test, Retries: healthcheck.Retries}
+		if healthcheck.Disable {
+			config.Healthcheck.Test = []string{"NONE"}
+		}
+		for _, field := range []struct {
+			value    string
+			duration *time.Duration
+		}{
+			{healthcheck.Interval, &config.Healthcheck.Interval},
+			{healthcheck.Timeout, &config.Healthcheck.Timeout},
+			{healthcheck.StartPeriod, &config.Healthcheck.StartPeriod},
+		} {
+			if field.value != "" {
+				parsed, err := time.ParseDuration(field.value)
+				assert.NoError(t, err)
+				*field.duration = parsed
+			}
+		}
+	}
+
+	hostConfig := &container.HostConfig{
+		NetworkMode: container.NetworkMode(service.NetworkMode),
+		IpcMode:     container.IpcMode(service.Ipc),
+		Privileged:  service.Privileged,
+		CapAdd:      service.CapAdd,
+		CapDrop:     service.CapDrop,
+		Resources: container.Resources{
+			NanoCPUs:          int64(service.Cpus * 1e9),
+			CPUShares:         service.CPUShares,
+			CpusetCpus:        service.Cpuset,
+			Memory:            service.MemLimit,
+			MemoryReservation: service.MemReservation,
+			DeviceCgroupRules: service.DeviceCgroupRules,
+		},
+	}
+	name, count, _ := strings.Cut(service.Restart, ":")
+	hostConfig.RestartPolicy.Name = container.RestartPolicyMode(name)
+	hostConfig.RestartPolicy.MaximumRetryCount, _ = strconv.Atoi(count)
+	for _, port := range service.Ports {
+		if hostConfig.PortBindings == nil {
+			hostConfig.PortBindings = nat.PortMap{}
+		}
+		key := nat.Port(strconv.Itoa(port.Target) + "/" + port.Protocol)
+		hostConfig.PortBindings[key] = append(hostConfig.PortBindings[key], nat.PortBinding{HostIP: port.HostIP, HostPort: port.Published})
+	}
+	for _, volume := range service.Volumes {
+		if volume.Short != "" {
+			hostConfig.Binds = append(hostConfig.Binds, volume.Short)
+		} else {
+			hostConfig.Mounts = append(hostConfig.Mounts, mount.Mount{Type: mount.Type(volume.Type), Source: volume.Source, Target: volume.Target, ReadOnly: volume.ReadOnly})
+		}
+	}
+	for _, device := range service.Devices {
+		parts := strings.Split(device, ":")
+		mapping := container.DeviceMapping{PathOnHost: parts[0], PathInContainer: parts[1]}
+		if len(parts) > 2 {
+			mapping.CgroupPermissions = parts[2]
+		}
+		hostConfig.Devices = append(hostConfig.Devices, mapping)
+	}
+
+	networking := &network.NetworkingConfig{}
+	for networkName, serviceNetwork := range service.Networks {
+		hostConfig.NetworkMode = container.NetworkMode(networkName)
+		networking.EndpointsConfig = map[string]*network.EndpointSettings{networkName: {
+			IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: serviceNetwork.IPv4Address},
+			Aliases:    serviceNetwork.Aliases,
+		}}
+	}
+	return ContainerSpec{Config: config, HostConfig: hostConfig, Networking: networking}
+}
+
+func composeTestConfig(nmosDir string) *parser.Configuration {
+	config := planTestConfig(nmosDir)
+	config.LauncherID = "bcs-launcher"
+	config.RunOnce.MediaProxyAgent.Network = workloads.NetworkConfig{Enable: true, Name: "bcs-net", IP: "10.0.0.5", Subnet: "10.0.0.0/24", Gateway: "10.0.0.1"}
+	config.RunOnce.MediaProxyMcm[0].Volumes = []string{"/dev/vfio:/dev/vfio"}
+	config.RunOnce.MediaProxyMcm[0].Resources.Limits.CPU = "1500m"
+	config.RunOnce.MediaProxyMcm[0].RestartPolicy = workloads.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}
+	config.WorkloadToBeRun[0].FfmpegPipeline.Volumes = workloads.Volumes{Videos: "/videos", Dri: "/dri", Kahawai: "/tmp/kahawai", Devnull: "/dev/null", Imtl: "/imtl", Shm: "/dev/shm"}
+	config.WorkloadToBeRun[0].FfmpegPipeline.Devices = workloads.Devices{Vfio: "/dev/vfio", Dri: "/dev/dri"}
+	config.WorkloadToBeRun[0].FfmpegPipeline.EnvironmentVariables = []string{"VFIO_PORT_TX=0000:ca:11.0"}
+	resources := &config.WorkloadToBeRun[0].FfmpegPipeline.Resources
+	resources.Requests.CPU, resources.Requests.Memory = "2", "2Gi"
+	resources.Limits.CPU, resources.Limits.Memory = "4", "4Gi"
+	resources.CpusetCpus = "2-5"
+	config.WorkloadToBeRun[0].NmosClient.HealthCheck = workloads.HealthCheck{Disable: true}
+	config.WorkloadToBeRun[0].SecurityProfile = "privileged"
+	return config
+}
+
+func TestExportCompose(t *testing.T) {
+	log := logr.Discard()
+	nmosDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(nmosDir, "nmos.json"), []byte(`{"ffmpeg_grpc_server_address": "old-address", "ffmpeg_grpc_server_port": "50051"}`), 0644))
+	config := composeTestConfig(nmosDir)
+
+	project, err := ExportCompose(log, config)
+	assert.NoError(t, err)
+
+	t.Run("Services have the container settings of the launcher", func(t *testing.T) {
+		assert.Len(t, project.Services, len(declaredContainers(config)))
+		for _, containerInfo := range declaredContainers(config) {
+			native, err := desiredContainerSpec(&containerInfo, config, log)
+			assert.NoError(t, err)
+			exported := serviceContainerSpec(t, project.Services[containerInfo.ContainerName])
+
+			assert.Equal(t, containerInfo.ContainerName, project.Services[containerInfo.ContainerName].ContainerName)
+			assert.Empty(t, DiffContainerSpec(exported, native), containerInfo.ContainerName)
+			assert.Empty(t, DiffContainerSpec(native, exported), containerInfo.ContainerName)
+			assert.Equal(t, native.Config.Labels, exported.Config.Labels, "the configuration hash label is kept")
+		}
+	})
+
+	t.Run("Services start in the order of the launcher", func(t *testing.T) {
+		assert.Empty(t, project.Services[MediaProxyAgentContainerName].DependsOn)
+		assert.Equal(t, map[string]ComposeDependency{MediaProxyAgentContainerName: {Condition: "service_healthy"}}, project.Services[MediaProxyContainerName].DependsOn)
+		assert.Equal(t, map[string]ComposeDependency{MediaProxyContainerName: {Condition: "service_started"}}, project.Services["ffmpeg-pipeline"].DependsOn,
+			"MCM Media Proxy has no health check")
+		assert.Equal(t, map[string]ComposeDependency{"ffmpeg-pipeline": {Condition: "service_healthy"}}, project.Services["nmos-client"].DependsOn)
+	})
+
+	t.Run("Networks are declared as the launcher creates them", func(t *testing.T) {
+		assert.Equal(t, map[string]ComposeNetwork{"bcs-net": {
+			Name:   "bcs-net",
+			Driver: "bridge",
+			Ipam:   &ComposeIPAM{Config: []ComposeIPAMConfig{{Subnet: "10.0.0.0/24", Gateway: "10.0.0.1"}}},
+		}}, project.Networks)
+		assert.Equal(t, "host", project.Services[MediaProxyContainerName].NetworkMode)
+		assert.Equal(t, map[string]ComposeServiceNetwork{"bcs-net": {IPv4Address: "10.0.0.2", Aliases: []string{"bcs-net"}}}, project.Services["ffmpeg-pipeline"].Networks)
+	})
+
+	t.Run("Warns about NMOS json files not pointing to their pipeline", func(t *testing.T) {
+		assert.Equal(t, []string{"NMOS json file " + utils.NmosJsonFilePath(config, 0) + ` does not point to FFmpeg pipeline ffmpeg-pipeline yet: set ffmpeg_grpc_server_address to "10.0.0.2" and ffmpeg_grpc_server_port to "50055"`}, project.Warnings)
+	})
+
+	t.Run("Writes a compose file", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, project.WriteYAML(&out))
+		assert.True(t, strings.HasPrefix(out.String(), "# WARNING: NMOS json file"))
+
+		var written map[string]interface{}
+		assert.NoError(t, yaml.Unmarshal(out.Bytes(), &written))
+		assert.Equal(t, "bcs-launcher", written["name"])
+		services := written["services"].(map[interface{}]interface{})
+		mcm := services[MediaProxyContainerName].(map[interface{}]interface{})
+		assert.Equal(t, []interface{}{"/dev/vfio:/dev/vfio"}, mcm["volumes"])
+		assert.Equal(t, "on-failure:3", mcm["restart"])
+		assert.Equal(t, 1.5, mcm["cpus"])
+		pipeline := services["ffmpeg-pipeline"].(map[interface{}]interface{})
+		assert.Equal(t, "2-5", pipeline["cpuset"])
+		assert.Equal(t, true, pipeline["privileged"])
+		assert.Contains(t, pipeline["volumes"], map[interface{}]interface{}{"type": "bind", "source": "/videos", "target": "/videos"})
+		nmosClient := services["nmos-client"].(map[interface{}]interface{})
+		assert.Equal(t, map[interface{}]interface{}{"disable": true}, nmosClient["healthcheck"])
+	})
+
+	t.Run("Refuses invalid settings", func(t *testing.T) {
+		config := composeTestConfig(nmosDir)
+		config.WorkloadToBeRun[0].MediaProxy = "media-proxy-nic1"
+		_, err := ExportCompose(log, config)
+		assert.ErrorContains(t, err, `unknown mediaProxy "media-proxy-nic1"`)
+	})
+}
+
+func TestComposeServiceCpusetMems(t *testing.T) {
+	spec := ContainerSpec{
+		Config:     &container.Config{Image: "ffmpeg-image:latest"},
+		HostConfig: &container.HostConfig{Resources: container.Resources{CpusetCpus: "2-5", CpusetMems: "1"}},
+		Networking: &network.NetworkingConfig{},
+	}
+	service, warnings := composeService("ffmpeg-pipeline", spec)
+	assert.Equal(t, "2-5", service.Cpuset)
+	assert.Equal(t, []string{"service ffmpeg-pipeline: compose cannot restrict memory nodes, cpusetMems 1 is not applied"}, warnings)
+}
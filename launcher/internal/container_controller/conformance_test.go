@@ -0,0 +1,491 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeContainer struct {
+	id         string
+	name       string
+	state      string
+	config     container.Config
+	hostConfig container.HostConfig
+}
+
+// fakeEngine serves the subset of the Docker Engine API used by the launcher. With podman set it answers
+// like the Docker-compatible API of Podman does: it identifies itself as Libpod and reports fully qualified image tags.
+type fakeEngine struct {
+	podman bool
+
+	mu          sync.Mutex
+	images      []string
+	pulls       []string
+	containers  map[string]*fakeContainer
+	networks    map[string]network.Inspect
+	subscribers []chan events.Message
+	nextID      int
+}
+
+var apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+/`)
+
+// startFakeEngine serves a fake engine on a unix socket and returns it with the controller connected to it.
+func startFakeEngine(t *testing.T, runtime Runtime, localImages ...string) (*fakeEngine, ContainerController) {
+	engine := &fakeEngine{podman: runtime == RuntimePodman, containers: map[string]*fakeContainer{}, networks: map[string]network.Inspect{}}
+	for _, ref := range localImages {
+		engine.images = append(engine.images, engine.tag(ref, true))
+	}
+
+	// unix socket paths are limited to about 100 characters, the test directory is too long for them
+	dir, err := os.MkdirTemp("", "bcs-engine")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	listener, err := net.Listen("unix", filepath.Join(dir, "engine.sock"))
+	require.NoError(t, err)
+	server := &http.Server{Handler: engine.handler()}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	cli, err := NewContainerController(runtime, "unix://"+filepath.Join(dir, "engine.sock"))
+	require.NoError(t, err)
+	return engine, cli
+}
+
+// tag returns the tag the engine reports for an image reference.
+func (e *fakeEngine) tag(ref string, local bool) string {
+	if !e.podman {
+		return ref
+	}
+	if local {
+		return "localhost/" + ref
+	}
+	if !strings.Contains(ref, "/") {
+		return "docker.io/library/" + ref
+	}
+	return "docker.io/" + ref
+}
+
+func (e *fakeEngine) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.41")
+		if e.podman {
+			w.Header().Set("Libpod-API-Version", "5.2.2")
+		}
+		w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("GET /images/json", func(w http.ResponseWriter, r *http.Request) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		summaries := []image.Summary{}
+		for _, tag := range e.images {
+			summaries = append(summaries, image.Summary{ID: "sha256:" + tag, RepoTags: []string{tag}})
+		}
+		writeJSON(w, http.StatusOK, summaries)
+	})
+	mux.HandleFunc("POST /images/create", func(w http.ResponseWriter, r *http.Request) {
+		// the client always sends the fully qualified name
+		ref := r.URL.Query().Get("fromImage") + ":" + r.URL.Query().Get("tag")
+		ref = strings.TrimPrefix(strings.TrimPrefix(ref, "docker.io/"), "library/")
+		e.mu.Lock()
+		e.pulls = append(e.pulls, ref)
+		e.images = append(e.images, e.tag(ref, false))
+		e.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]string{"status": "Downloaded newer image for " + ref})
+	})
+	mux.HandleFunc("POST /images/load", func(w http.ResponseWriter, r *http.Request) {
+		archive := tar.NewReader(r.Body)
+		for {
+			header, err := archive.Next()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "no manifest.json in tarball")
+				return
+			}
+			if header.Name != "manifest.json" {
+				continue
+			}
+			var manifest []struct{ RepoTags []string }
+			json.NewDecoder(archive).Decode(&manifest)
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			for _, entry := range manifest {
+				for _, ref := range entry.RepoTags {
+					e.images = append(e.images, e.tag(ref, false))
+					json.NewEncoder(w).Encode(map[string]string{"stream": "Loaded image: " + ref + "\n"})
+				}
+			}
+			return
+		}
+	})
+	mux.HandleFunc("GET /containers/json", func(w http.ResponseWriter, r *http.Request) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		list := []types.Container{}
+		for _, c := range e.containers {
+			list = append(list, types.Container{ID: c.id, Names: []string{"/" + c.name}, Image: c.config.Image, State: c.state, Labels: c.config.Labels})
+		}
+		writeJSON(w, http.StatusOK, list)
+	})
+	mux.HandleFunc("POST /containers/create", func(w http.ResponseWriter, r *http.Request) {
+		var request container.CreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		name := r.URL.Query().Get("name")
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if _, exists := e.containers[name]; exists {
+			writeError(w, http.StatusConflict, fmt.Sprintf("the container name %q is already in use", name))
+			return
+		}
+		e.nextID++
+		c := &fakeContainer{id: fmt.Sprintf("container-%d", e.nextID), name: name, state: "created", config: *request.Config}
+		if request.HostConfig != nil {
+			c.hostConfig = *request.HostConfig
+		}
+		e.containers[name] = c
+		writeJSON(w, http.StatusCreated, container.CreateResponse{ID: c.id})
+	})
+	mux.HandleFunc("POST /containers/{id}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		states := map[string]string{"start": "running", "stop": "exited", "restart": "running"}
+		state, supported := states[r.PathValue("action")]
+		if !supported {
+			writeError(w, http.StatusNotFound, "page not found")
+			return
+		}
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		c := e.find(r.PathValue("id"))
+		if c == nil {
+			writeError(w, http.StatusNotFound, "no such container: "+r.PathValue("id"))
+			return
+		}
+		c.state = state
+		for _, subscriber := range e.subscribers {
+			subscriber <- events.Message{Type: events.ContainerEventType, Action: events.Action(r.PathValue("action")),
+				Actor: events.Actor{ID: c.id, Attributes: map[string]string{"name": c.name}}, Time: time.Now().Unix()}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /containers/{id}/json", func(w http.ResponseWriter, r *http.Request) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		c := e.find(r.PathValue("id"))
+		if c == nil {
+			writeError(w, http.StatusNotFound, "no such container: "+r.PathValue("id"))
+			return
+		}
+		config, hostConfig := c.config, c.hostConfig
+		writeJSON(w, http.StatusOK, container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				ID:         c.id,
+				Name:       "/" + c.name,
+				State:      &container.State{Status: c.state, Running: c.state == "running"},
+				HostConfig: &hostConfig,
+			},
+			Config:          &config,
+			NetworkSettings: &container.NetworkSettings{},
+		})
+	})
+	mux.HandleFunc("DELETE /containers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		c := e.find(r.PathValue("id"))
+		if c == nil {
+			writeError(w, http.StatusNotFound, "no such container: "+r.PathValue("id"))
+			return
+		}
+		if c.state == "running" && r.URL.Query().Get("force") != "1" {
+			writeError(w, http.StatusConflict, "cannot remove running container "+c.name)
+			return
+		}
+		delete(e.containers, c.name)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /networks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		info, exists := e.networks[r.PathValue("id")]
+		if !exists {
+			writeError(w, http.StatusNotFound, "network "+r.PathValue("id")+" not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	})
+	mux.HandleFunc("POST /networks/create", func(w http.ResponseWriter, r *http.Request) {
+		var request network.CreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if _, exists := e.networks[request.Name]; exists {
+			writeError(w, http.StatusConflict, "network with name "+request.Name+" already exists")
+			return
+		}
+		info := network.Inspect{Name: request.Name, ID: "network-" + request.Name, Driver: request.Driver, Options: request.Options}
+		if request.IPAM != nil {
+			info.IPAM = *request.IPAM
+		}
+		e.networks[request.Name] = info
+		writeJSON(w, http.StatusCreated, network.CreateResponse{ID: info.ID})
+	})
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		subscriber := make(chan events.Message, 16)
+		e.mu.Lock()
+		e.subscribers = append(e.subscribers, subscriber)
+		e.mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		encoder := json.NewEncoder(w)
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case message := <-subscriber:
+				encoder.Encode(message)
+				w.(http.Flusher).Flush()
+			}
+		}
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if prefix := apiVersionPrefix.FindString(r.URL.Path); prefix != "" {
+			r.URL.Path = "/" + strings.TrimPrefix(r.URL.Path, prefix)
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// find looks a container up by ID or name, the caller holds the lock.
+func (e *fakeEngine) find(id string) *fakeContainer {
+	for _, c := range e.containers {
+		if c.id == id || c.name == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+// TestContainerControllerConformance runs the same scenarios against every container runtime backend.
+func TestContainerControllerConformance(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+
+	for _, runtime := range []Runtime{RuntimeDocker, RuntimePodman} {
+		t.Run(string(runtime), func(t *testing.T) {
+			t.Run("Finds local images by their short tag", func(t *testing.T) {
+				_, cli := startFakeEngine(t, runtime, "tiber-broadcast-suite:latest", "mcm/media-proxy:latest")
+
+				for _, ref := range []string{"tiber-broadcast-suite:latest", "mcm/media-proxy:latest"} {
+					err, pulled := isImagePulled(ctx, cli, ref)
+					assert.NoError(t, err)
+					assert.True(t, pulled, ref)
+				}
+				err, pulled := isImagePulled(ctx, cli, "ubuntu:22.04")
+				assert.NoError(t, err)
+				assert.False(t, pulled)
+			})
+
+			t.Run("Pulls a missing image once", func(t *testing.T) {
+				engine, cli := startFakeEngine(t, runtime)
+
+				assert.NoError(t, pullImageIfNotExists(ctx, cli, "ubuntu:22.04", log, nil))
+				assert.NoError(t, pullImageIfNotExists(ctx, cli, "ubuntu:22.04", log, nil))
+				assert.Equal(t, []string{"ubuntu:22.04"}, engine.pulls)
+			})
+
+			t.Run("Loads a missing image from a tarball", func(t *testing.T) {
+				engine, cli := startFakeEngine(t, runtime)
+				source := t.TempDir()
+				writeTarball(t, filepath.Join(source, "bcs.tar"), false, "tiber-broadcast-suite:latest")
+				containerInfo := &general.Containers{Type: general.MediaProxyAgent, Image: "tiber-broadcast-suite:latest"}
+
+				assert.NoError(t, ensureImage(ctx, cli, log, containerInfo, &parser.Configuration{ImageSource: source}))
+				err, present := isImagePulled(ctx, cli, "tiber-broadcast-suite:latest")
+				assert.NoError(t, err)
+				assert.True(t, present)
+				assert.Empty(t, engine.pulls)
+			})
+
+			t.Run("Creates, starts, stops and removes a container", func(t *testing.T) {
+				_, cli := startFakeEngine(t, runtime)
+
+				created, err := cli.ContainerCreate(ctx, &container.Config{Image: "ubuntu:22.04", Labels: map[string]string{"app": "bcs"}}, &container.HostConfig{}, &network.NetworkingConfig{}, nil, "bcs-test")
+				require.NoError(t, err)
+				assert.NotEmpty(t, created.ID)
+
+				require.NoError(t, cli.ContainerStart(ctx, created.ID, container.StartOptions{}))
+				err, running := isContainerRunning(ctx, cli, "bcs-test")
+				assert.NoError(t, err)
+				assert.True(t, running)
+				found, err := findContainer(ctx, cli, "bcs-test")
+				assert.NoError(t, err)
+				assert.Equal(t, "bcs", found.Labels["app"])
+
+				info, err := cli.ContainerInspect(ctx, "bcs-test")
+				require.NoError(t, err)
+				assert.True(t, info.State.Running)
+				assert.Equal(t, "ubuntu:22.04", info.Config.Image)
+
+				assert.NoError(t, cli.ContainerRestart(ctx, "bcs-test", container.StopOptions{}))
+				timeout := 1
+				assert.NoError(t, cli.ContainerStop(ctx, "bcs-test", container.StopOptions{Timeout: &timeout}))
+				err, running = isContainerRunning(ctx, cli, "bcs-test")
+				assert.NoError(t, err)
+				assert.False(t, running)
+
+				assert.NoError(t, cli.ContainerRemove(ctx, "bcs-test", container.RemoveOptions{Force: true}))
+				_, err = cli.ContainerInspect(ctx, "bcs-test")
+				assert.True(t, client.IsErrNotFound(err), "unexpected error %v", err)
+			})
+
+			t.Run("Reports name conflicts and missing containers", func(t *testing.T) {
+				_, cli := startFakeEngine(t, runtime)
+
+				_, err := cli.ContainerCreate(ctx, &container.Config{Image: "ubuntu:22.04"}, &container.HostConfig{}, &network.NetworkingConfig{}, nil, "bcs-test")
+				require.NoError(t, err)
+				_, err = cli.ContainerCreate(ctx, &container.Config{Image: "ubuntu:22.04"}, &container.HostConfig{}, &network.NetworkingConfig{}, nil, "bcs-test")
+				assert.True(t, errdefs.IsConflict(err), "unexpected error %v", err)
+
+				err = cli.ContainerStart(ctx, "missing", container.StartOptions{})
+				assert.True(t, client.IsErrNotFound(err), "unexpected error %v", err)
+				err = cli.ContainerRemove(ctx, "missing", container.RemoveOptions{Force: true})
+				assert.True(t, client.IsErrNotFound(err), "unexpected error %v", err)
+			})
+
+			t.Run("Creates a missing network", func(t *testing.T) {
+				_, cli := startFakeEngine(t, runtime)
+				config := &parser.Configuration{WorkloadToBeRun: []workloads.WorkloadConfig{{
+					FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: "ffmpeg-pipeline", Network: st2110Network("192.168.50.10")},
+				}}}
+
+				require.NoError(t, EnsureNetworks(ctx, cli, log, config))
+				info, err := cli.NetworkInspect(ctx, "st2110-net", network.InspectOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, "macvlan", info.Driver)
+				assert.Equal(t, "ens801f0", info.Options["parent"])
+				assert.Equal(t, []network.IPAMConfig{{Subnet: "192.168.50.0/24", Gateway: "192.168.50.1"}}, info.IPAM.Config)
+
+				// the second run finds the network created by the first one
+				assert.NoError(t, EnsureNetworks(ctx, cli, log, config))
+			})
+
+			t.Run("Streams container events", func(t *testing.T) {
+				_, cli := startFakeEngine(t, runtime)
+				created, err := cli.ContainerCreate(ctx, &container.Config{Image: "ubuntu:22.04"}, &container.HostConfig{}, &network.NetworkingConfig{}, nil, "bcs-test")
+				require.NoError(t, err)
+
+				eventsCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				defer cancel()
+				messages, errs := cli.Events(eventsCtx, events.ListOptions{})
+				// the subscription is established once the first event arrives, keep starting until then
+				for {
+					require.NoError(t, cli.ContainerStart(ctx, created.ID, container.StartOptions{}))
+					select {
+					case message := <-messages:
+						assert.Equal(t, events.ActionStart, message.Action)
+						assert.Equal(t, "bcs-test", message.Actor.Attributes["name"])
+						return
+					case err := <-errs:
+						t.Fatalf("Expected an event, got error %v", err)
+					case <-time.After(50 * time.Millisecond):
+					}
+				}
+			})
+
+			t.Run("Runs the launcher configuration idempotently", func(t *testing.T) {
+				engine, cli := startFakeEngine(t, runtime, "mcm/mesh-agent:latest", "mcm/media-proxy:latest")
+				config := &parser.Configuration{RunOnce: parser.RunOnce{
+					MediaProxyAgent: workloads.MediaProxyAgentConfig{ImageAndTag: "mcm/mesh-agent:latest"},
+					MediaProxyMcm:   workloads.MediaProxyMcmInstances{{ImageAndTag: "mcm/media-proxy:latest"}},
+				}}
+
+				report, err := CreateAndRunContainersWithOptions(ctx, cli, log, config, RunOptions{OnFailure: FailurePolicyRollback})
+				require.NoError(t, err)
+				assert.Equal(t, map[string]ContainerResult{MediaProxyAgentContainerName: ResultCreated, MediaProxyContainerName: ResultCreated}, reportResults(report))
+
+				report, err = CreateAndRunContainersWithOptions(ctx, cli, log, config, RunOptions{OnFailure: FailurePolicyRollback})
+				require.NoError(t, err)
+				assert.Equal(t, map[string]ContainerResult{MediaProxyAgentContainerName: ResultUnchanged, MediaProxyContainerName: ResultUnchanged}, reportResults(report))
+				assert.Empty(t, engine.pulls)
+
+				teardown, err := StopAndRemoveContainers(ctx, cli, log, config, DefaultStopTimeout)
+				assert.NoError(t, err)
+				assert.ElementsMatch(t, []string{MediaProxyAgentContainerName, MediaProxyContainerName}, teardown.Removed)
+				assert.Empty(t, engine.containers)
+			})
+		})
+	}
+}
+
+func TestWithShortTags(t *testing.T) {
+	assert.Equal(t, []string{
+		"localhost/tiber-broadcast-suite:latest", "docker.io/library/ubuntu:22.04", "docker.io/mcm/media-proxy:latest", "quay.io/org/image:1.0",
+		"tiber-broadcast-suite:latest", "ubuntu:22.04", "mcm/media-proxy:latest",
+	}, withShortTags([]string{"localhost/tiber-broadcast-suite:latest", "docker.io/library/ubuntu:22.04", "docker.io/mcm/media-proxy:latest", "quay.io/org/image:1.0"}))
+}
+
+func TestNewContainerController(t *testing.T) {
+	t.Run("Selects the Podman socket by default", func(t *testing.T) {
+		t.Setenv("CONTAINER_HOST", "")
+		controller, err := NewContainerController(RuntimePodman, "")
+
+		assert.NoError(t, err)
+		podman, ok := controller.(*PodmanContainerController)
+		assert.True(t, ok)
+		assert.Equal(t, DefaultPodmanHost, podman.cli.DaemonHost())
+	})
+
+	t.Run("Rejects an unknown runtime", func(t *testing.T) {
+		controller, err := NewContainerController("containerd", "")
+
+		assert.EqualError(t, err, `unknown container runtime "containerd"`)
+		assert.Nil(t, controller)
+	})
+}
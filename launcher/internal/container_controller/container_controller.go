@@ -9,20 +9,18 @@ package containercontroller
 import (
 	"context"
 	"errors"
-	"fmt"
 	"io"
-	"os"
 	"reflect"
-	"strconv"
 	"strings"
 	"sync"
 
 	"bcs.pod.launcher.intel/resources_library/parser"
 	"bcs.pod.launcher.intel/resources_library/resources/general"
-	"bcs.pod.launcher.intel/resources_library/utils"
+	"bcs.pod.launcher.intel/resources_library/workloads"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
@@ -34,17 +32,25 @@ var fileMutex sync.Mutex
 
 const (
 	MediaProxyAgentContainerName = "mesh-agent"
-	MediaProxyContainerName      = "media-proxy"
+	MediaProxyContainerName      = workloads.DefaultMediaProxyName
 	BCSPipelineContainerName     = "bcs-ffmpeg-pipeline"
 )
 
 type ContainerController interface {
 	ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error)
 	ImagePull(ctx context.Context, ref string, options image.PullOptions) (io.ReadCloser, error)
+	ImageLoad(ctx context.Context, input io.Reader) (image.LoadResponse, error)
 	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
 	ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error)
 	ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error
 	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *v1.Platform, containerName string) (container.CreateResponse, error)
+	ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error
+	ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+	NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error)
+	NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error)
 }
 
 type DockerContainerController struct {
@@ -58,6 +64,9 @@ func (d *DockerContainerController) ImageList(ctx context.Context, options image
 func (d *DockerContainerController) ImagePull(ctx context.Context, ref string, options image.PullOptions) (io.ReadCloser, error) {
 	return d.cli.ImagePull(ctx, ref, options)
 }
+func (d *DockerContainerController) ImageLoad(ctx context.Context, input io.Reader) (image.LoadResponse, error) {
+	return d.cli.ImageLoad(ctx, input, client.ImageLoadWithQuiet(true))
+}
 func (d *DockerContainerController) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
 	return d.cli.ContainerList(ctx, options)
 }
@@ -70,6 +79,27 @@ func (d *DockerContainerController) ContainerStart(ctx context.Context, containe
 func (d *DockerContainerController) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
 	return d.cli.ContainerRemove(ctx, containerID, options)
 }
+func (d *DockerContainerController) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	return d.cli.ContainerStop(ctx, containerID, options)
+}
+func (d *DockerContainerController) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	return d.cli.ContainerInspect(ctx, containerID)
+}
+func (d *DockerContainerController) ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error {
+	return d.cli.ContainerRestart(ctx, containerID, options)
+}
+func (d *DockerContainerController) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+	return d.cli.ContainerLogs(ctx, containerID, options)
+}
+func (d *DockerContainerController) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	return d.cli.Events(ctx, options)
+}
+func (d *DockerContainerController) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error) {
+	return d.cli.NetworkInspect(ctx, networkID, options)
+}
+func (d *DockerContainerController) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	return d.cli.NetworkCreate(ctx, name, options)
+}
 
 func NewDockerContainerController() (*DockerContainerController, error) {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
@@ -85,172 +115,160 @@ func IsEmptyStruct(s interface{}) bool {
 
 // Use case covers running containers on single host
 // CreateAndRunContainers creates and runs Docker containers based on the provided launcher configuration.
-// It parses the launcher configuration file, checks for the presence of specific container configurations,
+// It checks for the presence of specific container configurations
 // and creates and runs the containers accordingly.
 //
 // Parameters:
 //   - ctx: The context for managing the lifecycle of the container creation process.
-//   - launcherConfigName: The name of the launcher configuration file to be parsed.
+//   - cli: The Docker client used to manage the containers.
 //   - log: The logger for logging errors and information.
+//   - config: The parsed launcher configuration.
 //
 // Returns:
 //   - error: An error if any step in the container creation process fails, otherwise nil.
 //
 // The function performs the following steps:
-//   1. Parses the launcher configuration file.
-//   2. Checks if the configuration is empty and logs an error if it is.
-//   3. Creates and runs the MCM MediaProxy Agent container if its configuration is provided.
-//   4. Creates and runs the MCM MediaProxy container if its configuration is provided.
-//   5. Creates and runs the BCS NMOS client container if its configuration is provided.
-//   6. Creates and runs the BCS FFmpeg pipeline container with predefined settings.
+//   1. Checks which components are provided in the configuration and logs the omitted ones.
+//   2. Creates and runs the MCM MediaProxy Agent container if its configuration is provided.
+//   3. Creates and runs the MCM MediaProxy container if its configuration is provided.
+//   4. Creates and runs the BCS FFmpeg pipeline container of every workload in parallel.
+//   5. Creates and runs the BCS NMOS client container of every workload once its FFmpeg pipeline is running.
+//
+// Readiness gates are disabled and the containers started before a failure are kept;
+// use CreateAndRunContainersWithOptions to wait for services to listen or to roll back failed runs.
 
 func CreateAndRunContainers(ctx context.Context, cli ContainerController, log logr.Logger, config *parser.Configuration) error {
+	_, err := CreateAndRunContainersWithOptions(ctx, cli, log, config, RunOptions{})
+	return err
+}
 
-	//pass the yaml configuration to the Container struct
+// declaredContainers lists every container the launcher configuration declares,
+// in the order CreateAndRunContainers starts them: MCM MediaProxy Agent, the MCM MediaProxy instances,
+// then the FFmpeg pipeline and NMOS client of each workload.
+func declaredContainers(config *parser.Configuration) []general.Containers {
+	var declared []general.Containers
 	if !IsEmptyStruct(config.RunOnce.MediaProxyAgent) {
-
-		mcmAgentContainer := general.Containers{}
-		mcmAgentContainer.Type = general.MediaProxyAgent
-		mcmAgentContainer.ContainerName = MediaProxyAgentContainerName
-		mcmAgentContainer.Image = config.RunOnce.MediaProxyAgent.ImageAndTag
-		if !config.RunOnce.MediaProxyAgent.Network.Enable {
-			config.RunOnce.MediaProxyAgent.Network.Name = "host"
-			// Note - When you use the host network mode in Docker, the container shares
-			// the host machine's network stack.
-		}
-		err := createAndRunContainer(ctx, cli, log, &mcmAgentContainer, config)
-		if err != nil {
-			log.Error(err, "Failed to create container MCM MediaProxy Agent!")
-			return err
-		}
-	} else {
-		log.Info("No information about MCM MediaProxy Agent provided. Omitting creation of MCM MediaProxy Agent container")
+		declared = append(declared, general.Containers{
+			Type:          general.MediaProxyAgent,
+			ContainerName: MediaProxyAgentContainerName,
+			Image:         config.RunOnce.MediaProxyAgent.ImageAndTag,
+		})
 	}
-
-	if !IsEmptyStruct(config.RunOnce.MediaProxyMcm) {
-		mediaProxyContainer := general.Containers{}
-		mediaProxyContainer.Type = general.MediaProxyMCM
-		mediaProxyContainer.ContainerName = MediaProxyContainerName
-		mediaProxyContainer.Image = config.RunOnce.MediaProxyMcm.ImageAndTag
-		if !config.RunOnce.MediaProxyMcm.Network.Enable {
-			config.RunOnce.MediaProxyMcm.Network.Name = "host"
-			// Note - When you use the host network mode in Docker, the container shares
-			// the host machine's network stack.
-		}
-		err := createAndRunContainer(ctx, cli, log, &mediaProxyContainer, config)
-		if err != nil {
-			log.Error(err, "Failed to create container MCM MediaProxy!")
-			return err
+	for n, instance := range config.RunOnce.MediaProxyMcm {
+		if !IsEmptyStruct(instance) {
+			declared = append(declared, general.Containers{
+				Type:          general.MediaProxyMCM,
+				ContainerName: instance.ContainerName(),
+				Image:         instance.ImageAndTag,
+				Id:            n,
+			})
 		}
-	} else {
-		log.Info("No information about MCM MediaProxy provided. Omitting creation of MCM MediaProxy container")
-	}
-
-	if IsEmptyStruct(config.WorkloadToBeRun) || len(config.WorkloadToBeRun) == 0 {
-		log.Info("No workloads provided under workloadToBeRun. Omitting creation of BCS pipeline and NMOS node containers")
 	}
-
 	for n, instance := range config.WorkloadToBeRun {
-		if IsEmptyStruct(instance.FfmpegPipeline) || IsEmptyStruct(instance.NmosClient) {
-			return fmt.Errorf("no information about BCS pipeline provided. Either FfmpegPipeline or NmosClient is empty for instance Ffmpeg: %s; Nmos: %s", instance.FfmpegPipeline.Name, instance.NmosClient.Name)
-		}
-		bcsPipelinesContainer := general.Containers{}
-		bcsPipelinesContainer.Type = general.BcsPipelineFfmpeg
-		bcsPipelinesContainer.ContainerName = instance.FfmpegPipeline.Name
-		bcsPipelinesContainer.Image = instance.FfmpegPipeline.ImageAndTag
-		bcsPipelinesContainer.Id = n // use the index of the instance as the ID for the container
-
-		if !instance.FfmpegPipeline.Network.Enable {
-			instance.FfmpegPipeline.Network.Name = "host"
-			// Note - When you use the host network mode in Docker, the container shares
-			// the host machine's network stack.
+		if !IsEmptyStruct(instance.FfmpegPipeline) {
+			declared = append(declared, general.Containers{
+				Type:          general.BcsPipelineFfmpeg,
+				ContainerName: instance.FfmpegPipeline.Name,
+				Image:         instance.FfmpegPipeline.ImageAndTag,
+				Id:            n,
+			})
 		}
-		err := createAndRunContainer(ctx, cli, log, &bcsPipelinesContainer, config)
-		if err != nil {
-			log.Error(err, "Failed to create container for FFMPEG pipeline instance %d!", n)
-			return err
-		}
-		bcsNmosContainer := general.Containers{}
-		bcsNmosContainer.Type = general.BcsPipelineNmosClient
-		bcsNmosContainer.ContainerName = instance.NmosClient.Name
-		bcsNmosContainer.Image = instance.NmosClient.ImageAndTag
-		bcsNmosContainer.Id = n // use the index of the instance as the ID for the container
-
-		if !instance.NmosClient.Network.Enable {
-			// do not forget to set the network ip address despite disabling the custom network!
-			instance.NmosClient.Network.IP = "host"
-			// Note - When you use the host network mode in Docker, the container shares
-			// the host machine's network stack.
-		}
-		instance.NmosClient.FfmpegConnectionAddress = instance.FfmpegPipeline.Network.IP
-		instance.NmosClient.FfmpegConnectionPort = strconv.Itoa(instance.FfmpegPipeline.GRPCPort)
-		err = createAndRunContainer(ctx, cli, log, &bcsNmosContainer, config)
-		if err != nil {
-			log.Error(err, "Failed to create container!")
-			return err
+		if !IsEmptyStruct(instance.NmosClient) {
+			declared = append(declared, general.Containers{
+				Type:          general.BcsPipelineNmosClient,
+				ContainerName: instance.NmosClient.Name,
+				Image:         instance.NmosClient.ImageAndTag,
+				Id:            n,
+			})
 		}
-
 	}
-	return nil
+	return declared
 }
 
 func createAndRunContainer(ctx context.Context, cli ContainerController, log logr.Logger, containerInfo *general.Containers, config *parser.Configuration) error {
+	_, err := ensureContainer(ctx, cli, log, containerInfo, config)
+	return err
+}
+
+// ensureContainer creates and starts the container unless it is already running with the desired configuration.
+// The returned result tells whether a container has been created, also when starting it failed afterwards,
+// so the caller can roll it back.
+func ensureContainer(ctx context.Context, cli ContainerController, log logr.Logger, containerInfo *general.Containers, config *parser.Configuration) (ContainerResult, error) {
+	result := ResultCreated
 	err, isRunning := isContainerRunning(ctx, cli, containerInfo.ContainerName)
 	if err != nil {
 		log.Error(err, "Failed to parse launcher configuration file")
-		return err
+		return ResultFailed, err
 	}
 
 	if isRunning {
-		log.Info("Container ", containerInfo.ContainerName, " is running. Omitting this container creation.")
-		return nil
-	}
-
-	err, exists := doesContainerExist(ctx, cli, containerInfo.ContainerName)
-	if err != nil {
-		log.Error(err, "Failed to read container status (if it exists)")
-		return err
-	}
-
-	if exists {
-		log.Info("Removing container to re-create and re-run because container with a such name exists but with status exited:", "container", containerInfo.ContainerName)
+		// compared without side effects, the NMOS json file is only rewritten for a container that is created
+		spec, err := previewContainerSpec(containerInfo, config, log)
+		if err != nil {
+			return ResultFailed, err
+		}
+		drifted, err := hasConfigurationDrifted(ctx, cli, log, containerInfo.ContainerName, spec)
+		if err != nil {
+			log.Error(err, "Failed to compare running container with its configuration", "container", containerInfo.ContainerName)
+			return ResultFailed, err
+		}
+		if !drifted {
+			log.Info("Container ", containerInfo.ContainerName, " is running. Omitting this container creation.")
+			return ResultUnchanged, nil
+		}
+		log.Info("Removing running container to re-create it because its configuration has changed", "container", containerInfo.ContainerName)
+		result = ResultRecreated
 		err = removeContainer(ctx, cli, containerInfo.ContainerName)
 		if err != nil {
 			log.Error(err, "Failed to remove container")
-			return err
+			return ResultFailed, err
+		}
+	} else {
+		err, exists := doesContainerExist(ctx, cli, containerInfo.ContainerName)
+		if err != nil {
+			log.Error(err, "Failed to read container status (if it exists)")
+			return ResultFailed, err
 		}
 
+		if exists {
+			log.Info("Removing container to re-create and re-run because container with a such name exists but with status exited:", "container", containerInfo.ContainerName)
+			result = ResultRecreated
+			err = removeContainer(ctx, cli, containerInfo.ContainerName)
+			if err != nil {
+				log.Error(err, "Failed to remove container")
+				return ResultFailed, err
+			}
+
+		}
 	}
 
-	err = pullImageIfNotExists(ctx, cli, containerInfo.Image, log)
+	err = ensureImage(ctx, cli, log, containerInfo, config)
 	if err != nil {
 		log.Error(err, "Error pulling image for container")
-		return err
+		return ResultFailed, err
 	}
 	// Define the container configuration
-	containerConfig, hostConfig, networkConfig := utils.ConstructContainerConfig(containerInfo, config, log)
-
-	if containerConfig == nil || hostConfig == nil || networkConfig == nil {
-		// log.Error(errors.New("container configuration is nil"), "Failed to construct container configuration")
-		return errors.New("container configuration is nil")
+	desired, err := desiredContainerSpec(containerInfo, config, log)
+	if err != nil {
+		return ResultFailed, err
 	}
 	// Create the container
-	resp, err := cli.ContainerCreate(ctx, containerConfig, hostConfig, networkConfig, nil, containerInfo.ContainerName)
+	resp, err := cli.ContainerCreate(ctx, desired.Config, desired.HostConfig, desired.Networking, nil, containerInfo.ContainerName)
 
 	if err != nil {
 		log.Error(err, "Error creating container")
-		return err
+		return ResultFailed, err
 	}
 
 	// Start the container
 	err = cli.ContainerStart(ctx, resp.ID, container.StartOptions{})
 	if err != nil {
 		log.Error(err, "Error starting container")
-		return err
+		return result, err
 	}
 
 	log.Info("Container is created and started successfully", "name", containerInfo.ContainerName, "container id: ", resp.ID)
-	return nil
+	return result, nil
 }
 
 func isImagePulled(ctx context.Context, cli ContainerController, imageName string) (error, bool) {
@@ -259,18 +277,10 @@ func isImagePulled(ctx context.Context, cli ContainerController, imageName strin
 		return err, false
 	}
 
-	imageMap := make(map[string]bool)
-	for _, image := range images {
-		for _, tag := range image.RepoTags {
-			imageMap[tag] = true
-		}
-	}
-
-	_, isPulled := imageMap[imageName]
-	return nil, isPulled
+	return nil, findImage(images, imageName) != nil
 }
 
-func pullImageIfNotExists(ctx context.Context, cli ContainerController, imageName string, log logr.Logger) error {
+func pullImageIfNotExists(ctx context.Context, cli ContainerController, imageName string, log logr.Logger, config *parser.Configuration) error {
 
 	// Check if the Docker client is nil
 	if cli == nil {
@@ -295,19 +305,7 @@ func pullImageIfNotExists(ctx context.Context, cli ContainerController, imageNam
 
 	// Pull the image if it is not already pulled
 	if !pulled {
-		reader, err := cli.ImagePull(ctx, imageName, image.PullOptions{})
-		if err != nil {
-			log.Error(err, "Error pulling image")
-			return err
-		}
-		defer reader.Close()
-
-		_, err = io.Copy(os.Stdout, reader)
-		if err != nil {
-			log.Error(err, "Error reading output")
-			return err
-		}
-		log.Info("Image pulled successfully", "image", imageName)
+		return pullImage(ctx, cli, log, imageName, config)
 	}
 
 	return nil
@@ -334,6 +332,22 @@ func doesContainerExist(ctx context.Context, cli ContainerController, containerN
 	return nil, state == "exited"
 }
 
+// findContainer returns the container with the given name or nil if it does not exist.
+func findContainer(ctx context.Context, cli ContainerController, containerName string) (*types.Container, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	for i := range containers {
+		for _, name := range containers[i].Names {
+			if name == "/"+containerName {
+				return &containers[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
 func isContainerRunning(ctx context.Context, cli ContainerController, containerName string) (error, bool) {
 	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
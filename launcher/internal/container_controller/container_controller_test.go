@@ -22,6 +22,7 @@ import (
 	"bcs.pod.launcher.intel/resources_library/workloads"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
 
@@ -52,6 +53,11 @@ func (m *MockContainerController) ImagePull(ctx context.Context, ref string, opt
 	return args.Get(0).(io.ReadCloser), args.Error(1)
 }
 
+func (m *MockContainerController) ImageLoad(ctx context.Context, input io.Reader) (image.LoadResponse, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(image.LoadResponse), args.Error(1)
+}
+
 func (m *MockContainerController) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
 	args := m.Called(ctx, containerID, options)
 	return args.Error(0)
@@ -73,6 +79,41 @@ func (m *MockContainerController) ContainerCreate(ctx context.Context, config *c
 	return args.Get(0).(container.CreateResponse), args.Error(1)
 }
 
+func (m *MockContainerController) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	args := m.Called(ctx, containerID, options)
+	return args.Error(0)
+}
+
+func (m *MockContainerController) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	args := m.Called(ctx, containerID)
+	return args.Get(0).(container.InspectResponse), args.Error(1)
+}
+
+func (m *MockContainerController) ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error {
+	args := m.Called(ctx, containerID, options)
+	return args.Error(0)
+}
+
+func (m *MockContainerController) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(<-chan events.Message), args.Get(1).(<-chan error)
+}
+
+func (m *MockContainerController) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+	args := m.Called(ctx, containerID, options)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockContainerController) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error) {
+	args := m.Called(ctx, networkID, options)
+	return args.Get(0).(network.Inspect), args.Error(1)
+}
+
+func (m *MockContainerController) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	args := m.Called(ctx, name, options)
+	return args.Get(0).(network.CreateResponse), args.Error(1)
+}
+
 func TestIsContainerRunning(t *testing.T) {
 	ctx := context.Background()
 
@@ -429,7 +470,7 @@ func TestPullImageIfNotExists(t *testing.T) {
 			{RepoTags: []string{imageName}},
 		}, nil)
 
-		err := pullImageIfNotExists(ctx, mockController, imageName, log)
+		err := pullImageIfNotExists(ctx, mockController, imageName, log, nil)
 		mockController.AssertExpectations(t)
 
 		if err != nil {
@@ -442,9 +483,9 @@ func TestPullImageIfNotExists(t *testing.T) {
 		imageName := "test-image"
 
 		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{}, nil)
-		mockController.On("ImagePull", ctx, imageName, image.PullOptions{}).Return(io.NopCloser(strings.NewReader("pulled")), nil)
+		mockController.On("ImagePull", ctx, imageName, image.PullOptions{}).Return(io.NopCloser(strings.NewReader(`{"status":"Pulling from library/test-image","id":"latest"}`+"\n"+`{"status":"Status: Downloaded newer image for test-image:latest"}`)), nil)
 
-		err := pullImageIfNotExists(ctx, mockController, imageName, log)
+		err := pullImageIfNotExists(ctx, mockController, imageName, log, nil)
 		mockController.AssertExpectations(t)
 
 		if err != nil {
@@ -460,7 +501,7 @@ func TestPullImageIfNotExists(t *testing.T) {
 		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{}, nil)
 		mockController.On("ImagePull", ctx, imageName, image.PullOptions{}).Return(io.NopCloser(&errorReader{err: expectedError}), nil)
 
-		err := pullImageIfNotExists(ctx, mockController, imageName, log)
+		err := pullImageIfNotExists(ctx, mockController, imageName, log, nil)
 		mockController.AssertExpectations(t)
 
 		if err == nil {
@@ -685,13 +726,13 @@ func TestCreateAndRunContainers(t *testing.T) {
 						Name:   "test-network",
 					},
 				},
-				MediaProxyMcm: workloads.MediaProxyMcmConfig{
+				MediaProxyMcm: workloads.MediaProxyMcmInstances{{
 					ImageAndTag: "mcm-image:latest",
 					Network: workloads.NetworkConfig{
 						Enable: true,
 						Name:   "test-network",
 					},
-				},
+				}},
 			},
 			WorkloadToBeRun: []workloads.WorkloadConfig{
 				{
@@ -718,6 +759,10 @@ func TestCreateAndRunContainers(t *testing.T) {
 			},
 		}
 
+		mockController.On("NetworkInspect", ctx, "test-network", network.InspectOptions{}).Return(network.Inspect{
+			Name: "test-network",
+			IPAM: network.IPAM{Config: []network.IPAMConfig{{Subnet: "192.168.1.0/24"}}},
+		}, nil)
 		mockController.On("ContainerList", ctx, container.ListOptions{All: true}).Return([]types.Container{}, nil).Times(8)
 		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{}, nil).Times(4)
 		mockController.On("ImagePull", ctx, mock.Anything, image.PullOptions{}).Return(io.NopCloser(strings.NewReader("")), nil).Times(4)
@@ -744,13 +789,13 @@ func TestCreateAndRunContainers(t *testing.T) {
 						Name:   "test-network",
 					},
 				},
-				MediaProxyMcm: workloads.MediaProxyMcmConfig{
+				MediaProxyMcm: workloads.MediaProxyMcmInstances{{
 					ImageAndTag: "mcm-image:latest",
 					Network: workloads.NetworkConfig{
 						Enable: true,
 						Name:   "test-network",
 					},
-				},
+				}},
 			},
 			WorkloadToBeRun: []workloads.WorkloadConfig{
 				{
@@ -777,6 +822,10 @@ func TestCreateAndRunContainers(t *testing.T) {
 			},
 		}
 
+		mockController.On("NetworkInspect", ctx, "test-network", network.InspectOptions{}).Return(network.Inspect{
+			Name: "test-network",
+			IPAM: network.IPAM{Config: []network.IPAMConfig{{Subnet: "192.168.1.0/24"}}},
+		}, nil)
 		mockController.On("ContainerList", ctx, container.ListOptions{All: true}).Return([]types.Container{}, nil)
 		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{}, nil)
 		mockController.On("ImagePull", ctx, "agent-image:latest", image.PullOptions{}).Return(io.NopCloser(strings.NewReader("")), nil)
@@ -0,0 +1,219 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/utils"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/go-logr/logr"
+)
+
+// ConfigHashLabel holds the hash of the container configuration the container has been created with.
+const ConfigHashLabel = general.LabelConfigHash
+
+// ContainerSpec is the complete desired state of one container as passed to ContainerCreate.
+type ContainerSpec struct {
+	Config     *container.Config         `json:"config"`
+	HostConfig *container.HostConfig     `json:"hostConfig"`
+	Networking *network.NetworkingConfig `json:"networkingConfig"`
+}
+
+// FieldDiff describes a single field whose value in the running container differs from the configuration.
+type FieldDiff struct {
+	Field   string `json:"field"`
+	Current string `json:"current"`
+	Desired string `json:"desired"`
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %s -> %s", d.Field, d.Current, d.Desired)
+}
+
+// ConfigHash returns a stable hash of the container specification.
+// Labels are left out, so stamping the hash into the labels does not change it.
+func ConfigHash(spec ContainerSpec) (string, error) {
+	hashed := spec
+	if spec.Config != nil {
+		configCopy := *spec.Config
+		configCopy.Labels = nil
+		hashed.Config = &configCopy
+	}
+	data, err := json.Marshal(hashed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// desiredContainerSpec builds the container specification from the launcher configuration
+// and stamps it with the configuration hash label.
+func desiredContainerSpec(containerInfo *general.Containers, config *parser.Configuration, log logr.Logger) (ContainerSpec, error) {
+	return newContainerSpec(utils.ConstructContainerConfig(containerInfo, config, log))
+}
+
+// previewContainerSpec builds the same specification as desiredContainerSpec without rewriting the NMOS json file.
+func previewContainerSpec(containerInfo *general.Containers, config *parser.Configuration, log logr.Logger) (ContainerSpec, error) {
+	return newContainerSpec(utils.PreviewContainerConfig(containerInfo, config, log))
+}
+
+// newContainerSpec wraps the constructed container configuration and stamps it with the configuration hash label.
+func newContainerSpec(containerConfig *container.Config, hostConfig *container.HostConfig, networkConfig *network.NetworkingConfig) (ContainerSpec, error) {
+	if containerConfig == nil || hostConfig == nil || networkConfig == nil {
+		return ContainerSpec{}, fmt.Errorf("container configuration is nil")
+	}
+	spec := ContainerSpec{Config: containerConfig, HostConfig: hostConfig, Networking: networkConfig}
+	hash, err := ConfigHash(spec)
+	if err != nil {
+		return ContainerSpec{}, err
+	}
+	if spec.Config.Labels == nil {
+		spec.Config.Labels = map[string]string{}
+	}
+	spec.Config.Labels[ConfigHashLabel] = hash
+	return spec, nil
+}
+
+// hasConfigurationDrifted compares the configuration hash label of the running container with the hash
+// of the desired specification. Containers without the label (created by an older launcher) are
+// treated as up to date, so upgrading the launcher does not restart running pipelines.
+func hasConfigurationDrifted(ctx context.Context, cli ContainerController, log logr.Logger, containerName string, desired ContainerSpec) (bool, error) {
+	current, err := findContainer(ctx, cli, containerName)
+	if err != nil || current == nil {
+		return false, err
+	}
+	currentHash, labeled := current.Labels[ConfigHashLabel]
+	if !labeled {
+		log.Info("Container has no configuration hash label. Drift detection is skipped for it", "container", containerName)
+		return false, nil
+	}
+	if currentHash == desired.Config.Labels[ConfigHashLabel] {
+		return false, nil
+	}
+
+	info, err := cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return false, err
+	}
+	diffs := DiffContainerSpec(inspectedContainerSpec(info), desired)
+	if len(diffs) == 0 {
+		log.Info("Configuration hash of container changed", "container", containerName, "current", currentHash, "desired", desired.Config.Labels[ConfigHashLabel])
+	}
+	for _, d := range diffs {
+		log.Info("Configuration drift detected", "container", containerName, "field", d.Field, "current", d.Current, "desired", d.Desired)
+	}
+	return true, nil
+}
+
+// inspectedContainerSpec converts the inspect response of a container into a ContainerSpec comparable with the desired one.
+func inspectedContainerSpec(info container.InspectResponse) ContainerSpec {
+	spec := ContainerSpec{Config: info.Config, Networking: &network.NetworkingConfig{}}
+	if info.ContainerJSONBase != nil {
+		spec.HostConfig = info.HostConfig
+	}
+	if info.NetworkSettings != nil {
+		spec.Networking.EndpointsConfig = info.NetworkSettings.Networks
+	}
+	return spec
+}
+
+// DiffContainerSpec lists the fields set in the desired specification that have a different value in the current one.
+// Fields left empty in the desired specification are not compared, because Docker fills them with defaults.
+// Environment variables and network aliases are compared as sets, since the image and Docker add entries to them.
+func DiffContainerSpec(current, desired ContainerSpec) []FieldDiff {
+	var diffs []FieldDiff
+	diffValue("Config", reflect.ValueOf(current.Config), reflect.ValueOf(desired.Config), &diffs)
+	diffValue("HostConfig", reflect.ValueOf(current.HostConfig), reflect.ValueOf(desired.HostConfig), &diffs)
+	diffValue("NetworkingConfig", reflect.ValueOf(current.Networking), reflect.ValueOf(desired.Networking), &diffs)
+	return diffs
+}
+
+func diffValue(path string, current, desired reflect.Value, diffs *[]FieldDiff) {
+	if !desired.IsValid() || desired.IsZero() || strings.HasSuffix(path, ".Labels") {
+		return
+	}
+	if !current.IsValid() {
+		*diffs = append(*diffs, FieldDiff{Field: path, Current: "<none>", Desired: formatValue(desired)})
+		return
+	}
+
+	switch desired.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if current.IsNil() {
+			*diffs = append(*diffs, FieldDiff{Field: path, Current: "<none>", Desired: formatValue(desired.Elem())})
+			return
+		}
+		diffValue(path, current.Elem(), desired.Elem(), diffs)
+	case reflect.Struct:
+		for i := 0; i < desired.NumField(); i++ {
+			field := desired.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			diffValue(path+"."+field.Name, current.Field(i), desired.Field(i), diffs)
+		}
+	case reflect.Map:
+		keys := desired.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			keyPath := fmt.Sprintf("%s[%v]", path, key)
+			currentValue := reflect.Value{}
+			if !current.IsNil() {
+				currentValue = current.MapIndex(key)
+			}
+			if desired.Type().Elem().Size() == 0 {
+				// set semantics, e.g. nat.PortSet
+				if !currentValue.IsValid() {
+					*diffs = append(*diffs, FieldDiff{Field: keyPath, Current: "<none>", Desired: "present"})
+				}
+				continue
+			}
+			diffValue(keyPath, currentValue, desired.MapIndex(key), diffs)
+		}
+	case reflect.Slice:
+		if strings.HasSuffix(path, ".Env") || strings.HasSuffix(path, ".Aliases") {
+			present := map[string]bool{}
+			for i := 0; i < current.Len(); i++ {
+				present[current.Index(i).String()] = true
+			}
+			for i := 0; i < desired.Len(); i++ {
+				entry := desired.Index(i).String()
+				if !present[entry] {
+					*diffs = append(*diffs, FieldDiff{Field: path, Current: "<missing>", Desired: entry})
+				}
+			}
+			return
+		}
+		if !reflect.DeepEqual(current.Interface(), desired.Interface()) {
+			*diffs = append(*diffs, FieldDiff{Field: path, Current: formatValue(current), Desired: formatValue(desired)})
+		}
+	default:
+		if !reflect.DeepEqual(current.Interface(), desired.Interface()) {
+			*diffs = append(*diffs, FieldDiff{Field: path, Current: formatValue(current), Desired: formatValue(desired)})
+		}
+	}
+}
+
+func formatValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<none>"
+	}
+	return fmt.Sprintf("%+v", v.Interface())
+}
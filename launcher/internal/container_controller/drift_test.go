@@ -0,0 +1,187 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/utils"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func driftTestConfig() *parser.Configuration {
+	return &parser.Configuration{
+		WorkloadToBeRun: []workloads.WorkloadConfig{
+			{
+				FfmpegPipeline: workloads.FfmpegPipelineConfig{
+					Name:                 "test-container",
+					ImageAndTag:          "ffmpegpipeline:latest",
+					GRPCPort:             50051,
+					EnvironmentVariables: []string{"http_proxy="},
+					Network: workloads.NetworkConfig{
+						Enable: true,
+						Name:   "test-network",
+						IP:     "192.168.1.102",
+					},
+					Volumes: workloads.Volumes{Videos: "/host/videos"},
+				},
+			},
+		},
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	spec := func(env ...string) ContainerSpec {
+		return ContainerSpec{
+			Config:     &container.Config{Image: "ffmpeg:latest", Env: env},
+			HostConfig: &container.HostConfig{NetworkMode: "host"},
+			Networking: &network.NetworkingConfig{},
+		}
+	}
+
+	hash, err := ConfigHash(spec("A=1"))
+	assert.NoError(t, err)
+	sameHash, _ := ConfigHash(spec("A=1"))
+	assert.Equal(t, hash, sameHash)
+
+	labeled := spec("A=1")
+	labeled.Config.Labels = map[string]string{ConfigHashLabel: hash}
+	labeledHash, _ := ConfigHash(labeled)
+	assert.Equal(t, hash, labeledHash)
+	assert.Equal(t, hash, labeled.Config.Labels[ConfigHashLabel], "hashing must not modify the spec")
+
+	otherHash, _ := ConfigHash(spec("A=2"))
+	assert.NotEqual(t, hash, otherHash)
+}
+
+func TestDiffContainerSpec(t *testing.T) {
+	desired := ContainerSpec{
+		Config: &container.Config{Image: "ffmpeg:2.0", Env: []string{"http_proxy=", "VFIO_PORT_TX=0000:ca:11.0"}, Labels: map[string]string{ConfigHashLabel: "new"}},
+		HostConfig: &container.HostConfig{
+			NetworkMode: "bcs-net",
+			Mounts:      []mount.Mount{{Type: mount.TypeBind, Source: "/videos/new", Target: "/videos"}},
+		},
+		Networking: &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{
+			"bcs-net": {IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: "10.0.0.2"}},
+		}},
+	}
+	current := ContainerSpec{
+		Config: &container.Config{Image: "ffmpeg:1.0", Env: []string{"PATH=/usr/bin", "http_proxy="}, Labels: map[string]string{ConfigHashLabel: "old"}},
+		HostConfig: &container.HostConfig{
+			NetworkMode: "bcs-net",
+			Mounts:      []mount.Mount{{Type: mount.TypeBind, Source: "/videos/old", Target: "/videos"}},
+			ShmSize:     67108864,
+		},
+		Networking: &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{
+			"bcs-net": {IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: "10.0.0.1"}, IPAddress: "10.0.0.1"},
+		}},
+	}
+
+	fields := []string{}
+	for _, d := range DiffContainerSpec(current, desired) {
+		fields = append(fields, d.Field)
+	}
+	assert.Equal(t, []string{
+		"Config.Env",
+		"Config.Image",
+		"HostConfig.Mounts",
+		"NetworkingConfig.EndpointsConfig[bcs-net].IPAMConfig.IPv4Address",
+	}, fields)
+
+	assert.Empty(t, DiffContainerSpec(desired, desired))
+}
+
+func TestCreateAndRunContainerDrift(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+	containerInfo := &general.Containers{ContainerName: "test-container", Image: "ffmpegpipeline:latest", Id: 0, Type: general.BcsPipelineFfmpeg}
+
+	desired, err := desiredContainerSpec(containerInfo, driftTestConfig(), log)
+	assert.NoError(t, err)
+
+	t.Run("Running container with unchanged configuration is kept", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("ContainerList", ctx, container.ListOptions{All: true}).Return([]types.Container{
+			{Names: []string{"/test-container"}, State: "running", Labels: map[string]string{ConfigHashLabel: desired.Config.Labels[ConfigHashLabel]}},
+		}, nil)
+
+		err := createAndRunContainer(ctx, mockController, log, containerInfo, driftTestConfig())
+		mockController.AssertExpectations(t)
+
+		assert.NoError(t, err)
+		mockController.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Running container with changed configuration is re-created", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("ContainerList", ctx, container.ListOptions{All: true}).Return([]types.Container{
+			{Names: []string{"/test-container"}, State: "running", Labels: map[string]string{ConfigHashLabel: "outdated"}},
+		}, nil)
+		mockController.On("ContainerInspect", ctx, "test-container").Return(container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{HostConfig: &container.HostConfig{}},
+			Config:            &container.Config{Image: "ffmpegpipeline:old"},
+		}, nil)
+		mockController.On("ContainerRemove", ctx, "test-container", container.RemoveOptions{Force: true}).Return(nil)
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{{RepoTags: []string{"ffmpegpipeline:latest"}}}, nil)
+		mockController.On("ContainerCreate", ctx, mock.MatchedBy(func(c *container.Config) bool {
+			return c.Labels[ConfigHashLabel] == desired.Config.Labels[ConfigHashLabel]
+		}), mock.Anything, mock.Anything, nil, "test-container").Return(container.CreateResponse{ID: "test-id"}, nil)
+		mockController.On("ContainerStart", ctx, "test-id", container.StartOptions{}).Return(nil)
+
+		err := createAndRunContainer(ctx, mockController, log, containerInfo, driftTestConfig())
+		mockController.AssertExpectations(t)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Running container without configuration hash label is kept", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("ContainerList", ctx, container.ListOptions{All: true}).Return([]types.Container{
+			{Names: []string{"/test-container"}, State: "running"},
+		}, nil)
+
+		err := createAndRunContainer(ctx, mockController, log, containerInfo, driftTestConfig())
+		mockController.AssertExpectations(t)
+
+		assert.NoError(t, err)
+		mockController.AssertNotCalled(t, "ContainerRemove", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestCreateAndRunContainerDriftKeepsNmosJsonFile(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+	nmosDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(nmosDir, "nmos.json"), []byte(`{"ffmpeg_grpc_server_port": "50051"}`), 0644))
+	config := planTestConfig(nmosDir)
+	containerInfo := &general.Containers{ContainerName: "nmos-client", Image: "nmos-image:latest", Id: 0, Type: general.BcsPipelineNmosClient}
+	desired, err := previewContainerSpec(containerInfo, config, log)
+	assert.NoError(t, err)
+
+	mockController := new(MockContainerController)
+	mockController.On("ContainerList", ctx, container.ListOptions{All: true}).Return([]types.Container{
+		{Names: []string{"/nmos-client"}, State: "running", Labels: map[string]string{ConfigHashLabel: desired.Config.Labels[ConfigHashLabel]}},
+	}, nil)
+
+	assert.NoError(t, createAndRunContainer(ctx, mockController, log, containerInfo, config))
+	onDisk, err := os.ReadFile(utils.NmosRenderedFilePath(config, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"ffmpeg_grpc_server_port": "50051"}`, string(onDisk), "the NMOS json file of an unchanged container is not rewritten")
+}
@@ -0,0 +1,225 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/go-logr/logr"
+)
+
+// errImageNotInSource tells that no tarball of the image source contains the image, so it has to be pulled.
+var errImageNotInSource = errors.New("image not found in image source")
+
+// tarballExtensions are the files of an image source directory that are read as docker save tarballs.
+var tarballExtensions = []string{".tar", ".tar.gz", ".tgz"}
+
+// imageSource returns the image source of a container: the one of its workload, otherwise the global one.
+func imageSource(containerInfo *general.Containers, config *parser.Configuration) string {
+	if config == nil {
+		return ""
+	}
+	if containerInfo.Type == general.BcsPipelineFfmpeg || containerInfo.Type == general.BcsPipelineNmosClient {
+		if source := config.WorkloadToBeRun[containerInfo.Id].ImageSource; source != "" {
+			return source
+		}
+	}
+	return config.ImageSource
+}
+
+// ensureImage makes the image of a container available locally. A missing image is loaded from the image source
+// of the container when a tarball of the source contains it and pulled otherwise. A missing image pinned by digest
+// fails, docker save tarballs only carry the tags of their images and pulling would fail on a host without a registry.
+func ensureImage(ctx context.Context, cli ContainerController, log logr.Logger, containerInfo *general.Containers, config *parser.Configuration) error {
+	if source := imageSource(containerInfo, config); source != "" {
+		err, present := isImagePulled(ctx, cli, containerInfo.Image)
+		if err != nil {
+			log.Error(err, "Error checking if image is pulled")
+			return err
+		}
+		if present {
+			return nil
+		}
+		if named, err := reference.ParseNormalizedNamed(containerInfo.Image); err == nil {
+			if _, digested := named.(reference.Digested); digested {
+				return fmt.Errorf("image %s is pinned by digest, digest pins are not supported for image sources: "+
+					"tarballs in %s only carry image tags, reference the image by tag and pin it with a lockfile", containerInfo.Image, source)
+			}
+		}
+		err = loadImage(ctx, cli, log, containerInfo.Image, source)
+		if !errors.Is(err, errImageNotInSource) {
+			return err
+		}
+		log.Info("Image is not in the image source, pulling it", "image", containerInfo.Image, "source", source)
+	}
+	return pullImageIfNotExists(ctx, cli, containerInfo.Image, log, config)
+}
+
+// sourceTarballs lists the tarballs of an image source, which is either a single tarball or a directory.
+func sourceTarballs(source string) ([]string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image source: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{source}, nil
+	}
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image source: %w", err)
+	}
+	var tarballs []string
+	for _, entry := range entries {
+		for _, extension := range tarballExtensions {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), extension) {
+				tarballs = append(tarballs, filepath.Join(source, entry.Name()))
+				break
+			}
+		}
+	}
+	sort.Strings(tarballs)
+	return tarballs, nil
+}
+
+// openTarball opens a tarball, decompressing it when it is gzipped.
+func openTarball(path string) (io.Reader, io.Closer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		decompressed, err := gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return decompressed, file, nil
+	}
+	return buffered, file, nil
+}
+
+// tarballTags returns the tags of the images in a docker save tarball, read from its manifest.json.
+func tarballTags(path string) ([]string, error) {
+	reader, closer, err := openTarball(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("tarball %s has no manifest.json, it is not a docker save archive", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tarball %s: %w", path, err)
+		}
+		if filepath.Clean(header.Name) != "manifest.json" {
+			continue
+		}
+		var manifest []struct {
+			RepoTags []string `json:"RepoTags"`
+		}
+		if err := json.NewDecoder(archive).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest.json in tarball %s: %w", path, err)
+		}
+		var tags []string
+		for _, entry := range manifest {
+			tags = append(tags, entry.RepoTags...)
+		}
+		return tags, nil
+	}
+}
+
+// loadImage loads the tarball of the image source containing the image and verifies the image is then present
+// under its configured reference. It returns errImageNotInSource when no tarball contains the image.
+func loadImage(ctx context.Context, cli ContainerController, log logr.Logger, imageName, source string) error {
+	tarballs, err := sourceTarballs(source)
+	if err != nil {
+		return err
+	}
+	for _, tarball := range tarballs {
+		tags, err := tarballTags(tarball)
+		if err != nil {
+			return err
+		}
+		if findImage([]image.Summary{{RepoTags: tags}}, imageName) == nil {
+			continue
+		}
+
+		log.Info("Loading image from tarball", "image", imageName, "tarball", tarball)
+		if err := loadTarball(ctx, cli, log, tarball); err != nil {
+			return err
+		}
+		err, present := isImagePulled(ctx, cli, imageName)
+		if err != nil {
+			return err
+		}
+		if !present {
+			return fmt.Errorf("image %s is not present after loading tarball %s with tags %v", imageName, tarball, tags)
+		}
+		log.Info("Image loaded successfully", "image", imageName, "tarball", tarball)
+		return nil
+	}
+	return fmt.Errorf("%w: no tarball in %s contains %s", errImageNotInSource, source, imageName)
+}
+
+// loadTarball sends a tarball to the image-load API and fails on an error reported in its response.
+func loadTarball(ctx context.Context, cli ContainerController, log logr.Logger, tarball string) error {
+	file, err := os.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	response, err := cli.ImageLoad(ctx, file)
+	if err != nil {
+		return fmt.Errorf("failed to load tarball %s: %w", tarball, err)
+	}
+	defer response.Body.Close()
+	if !response.JSON {
+		_, err := io.Copy(io.Discard, response.Body)
+		return err
+	}
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var message jsonmessage.JSONMessage
+		err := decoder.Decode(&message)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read response of loading tarball %s: %w", tarball, err)
+		}
+		if message.Error != nil {
+			return fmt.Errorf("failed to load tarball %s: %w", tarball, message.Error)
+		}
+		if stream := strings.TrimSpace(message.Stream); stream != "" {
+			log.Info("Loading image", "tarball", tarball, "status", stream)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types/image"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// writeTarball writes a minimal docker save tarball whose manifest.json lists tags.
+func writeTarball(t *testing.T, path string, compress bool, tags ...string) {
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	var w io.Writer = file
+	if compress {
+		gz := gzip.NewWriter(file)
+		defer gz.Close()
+		w = gz
+	}
+	archive := tar.NewWriter(w)
+	defer archive.Close()
+	manifest, _ := json.Marshal([]map[string]interface{}{{"Config": "config.json", "RepoTags": tags, "Layers": []string{}}})
+	for name, content := range map[string][]byte{"manifest.json": manifest} {
+		assert.NoError(t, archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err := archive.Write(content)
+		assert.NoError(t, err)
+	}
+}
+
+func loadResponse(messages ...string) image.LoadResponse {
+	return image.LoadResponse{Body: io.NopCloser(strings.NewReader(strings.Join(messages, "\n"))), JSON: true}
+}
+
+func TestImageSource(t *testing.T) {
+	config := &parser.Configuration{
+		ImageSource: "/opt/bcs/images",
+		WorkloadToBeRun: []workloads.WorkloadConfig{
+			{ImageSource: "/opt/bcs/workload-0.tar"},
+			{},
+		},
+	}
+
+	assert.Equal(t, "/opt/bcs/images", imageSource(&general.Containers{Type: general.MediaProxyAgent}, config))
+	assert.Equal(t, "/opt/bcs/workload-0.tar", imageSource(&general.Containers{Type: general.BcsPipelineNmosClient, Id: 0}, config))
+	assert.Equal(t, "/opt/bcs/images", imageSource(&general.Containers{Type: general.BcsPipelineFfmpeg, Id: 1}, config))
+	assert.Equal(t, "", imageSource(&general.Containers{Type: general.MediaProxyAgent}, nil))
+}
+
+func TestEnsureImage(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	source := t.TempDir()
+	writeTarball(t, filepath.Join(source, "mcm.tar"), false, "mcm/media-proxy:latest", "mcm/mesh-agent:latest")
+	writeTarball(t, filepath.Join(source, "bcs.tar.gz"), true, "tiber-broadcast-suite:latest")
+	assert.NoError(t, os.WriteFile(filepath.Join(source, "README"), []byte("not a tarball"), 0644))
+	config := &parser.Configuration{ImageSource: source}
+	containerInfo := &general.Containers{Type: general.MediaProxyAgent, ContainerName: MediaProxyAgentContainerName, Image: "tiber-broadcast-suite:latest"}
+
+	t.Run("Loads a missing image from the tarball containing it", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{}, nil).Once()
+		mockController.On("ImageLoad", ctx, mock.Anything).Return(loadResponse(`{"stream":"Loaded image: tiber-broadcast-suite:latest\n"}`), nil).Once()
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{{RepoTags: []string{"tiber-broadcast-suite:latest"}}}, nil).Once()
+
+		err := ensureImage(ctx, mockController, log, containerInfo, config)
+		mockController.AssertExpectations(t)
+		assert.NoError(t, err)
+		mockController.AssertNotCalled(t, "ImagePull", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Pulls an image that is not in the image source", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{}, nil)
+		mockController.On("ImagePull", ctx, "ubuntu:22.04", image.PullOptions{}).Return(pullStream(), nil)
+
+		err := ensureImage(ctx, mockController, log, &general.Containers{Type: general.MediaProxyAgent, Image: "ubuntu:22.04"}, config)
+		mockController.AssertExpectations(t)
+		assert.NoError(t, err)
+		mockController.AssertNotCalled(t, "ImageLoad", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Fails when the loaded tarball does not provide the image", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{}, nil)
+		mockController.On("ImageLoad", ctx, mock.Anything).Return(loadResponse(`{"stream":"Loaded image ID: sha256:abc\n"}`), nil)
+
+		err := ensureImage(ctx, mockController, log, containerInfo, config)
+		assert.EqualError(t, err, "image tiber-broadcast-suite:latest is not present after loading tarball "+filepath.Join(source, "bcs.tar.gz")+" with tags [tiber-broadcast-suite:latest]")
+	})
+
+	t.Run("Fails on an error reported while loading", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{}, nil)
+		mockController.On("ImageLoad", ctx, mock.Anything).Return(loadResponse(`{"errorDetail":{"message":"unexpected EOF"},"error":"unexpected EOF"}`), nil)
+
+		err := ensureImage(ctx, mockController, log, &general.Containers{Type: general.MediaProxyMCM, Image: "mcm/media-proxy:latest"}, config)
+		assert.EqualError(t, err, "failed to load tarball "+filepath.Join(source, "mcm.tar")+": unexpected EOF")
+	})
+
+	t.Run("Fails for a missing image pinned by digest", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{}, nil)
+		pinned := "tiber-broadcast-suite:latest@sha256:" + strings.Repeat("a", 64)
+
+		err := ensureImage(ctx, mockController, log, &general.Containers{Type: general.MediaProxyAgent, Image: pinned}, config)
+		assert.EqualError(t, err, "image "+pinned+" is pinned by digest, digest pins are not supported for image sources: "+
+			"tarballs in "+source+" only carry image tags, reference the image by tag and pin it with a lockfile")
+		mockController.AssertNotCalled(t, "ImageLoad", mock.Anything, mock.Anything)
+		mockController.AssertNotCalled(t, "ImagePull", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Skips the image source for present images", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{{RepoTags: []string{"tiber-broadcast-suite:latest"}}}, nil)
+
+		assert.NoError(t, ensureImage(ctx, mockController, log, containerInfo, config))
+		mockController.AssertNotCalled(t, "ImageLoad", mock.Anything, mock.Anything)
+	})
+}
+
+func TestTarballTags(t *testing.T) {
+	dir := t.TempDir()
+	writeTarball(t, filepath.Join(dir, "image.tgz"), true, "a:1", "b:2")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.tar"), []byte("not a tarball"), 0644))
+
+	tags, err := tarballTags(filepath.Join(dir, "image.tgz"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a:1", "b:2"}, tags)
+
+	_, err = tarballTags(filepath.Join(dir, "broken.tar"))
+	assert.Error(t, err)
+}
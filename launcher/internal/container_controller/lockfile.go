@@ -0,0 +1,175 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/image"
+	"github.com/go-logr/logr"
+)
+
+// LockedImage identifies the image an image reference of the launcher configuration resolved to.
+type LockedImage struct {
+	Digest string `json:"digest,omitempty"` // manifest digest in the registry, empty for images built locally
+	ID     string `json:"id"`
+}
+
+// ImageLock is the content of the lockfile: the resolved image of every image reference of the launcher configuration.
+type ImageLock struct {
+	Images map[string]LockedImage `json:"images"`
+}
+
+// findImage returns the local image an image reference points to, or nil. References carrying a digest,
+// e.g. tiber-broadcast-suite:latest@sha256:..., match the RepoDigests of the image, the tag is ignored then.
+// Other references match its RepoTags.
+func findImage(images []image.Summary, ref string) *image.Summary {
+	for i := range images {
+		if slices.Contains(images[i].RepoTags, ref) {
+			return &images[i]
+		}
+	}
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		for i := range images {
+			for _, repoDigest := range images[i].RepoDigests {
+				candidate, err := reference.ParseNormalizedNamed(repoDigest)
+				if err != nil {
+					continue
+				}
+				if candidate, ok := candidate.(reference.Canonical); ok && candidate.Name() == named.Name() && candidate.Digest() == digested.Digest() {
+					return &images[i]
+				}
+			}
+		}
+		return nil
+	}
+	tagged := reference.TagNameOnly(named).String()
+	for i := range images {
+		for _, repoTag := range images[i].RepoTags {
+			if candidate, err := reference.ParseNormalizedNamed(repoTag); err == nil && candidate.String() == tagged {
+				return &images[i]
+			}
+		}
+	}
+	return nil
+}
+
+// manifestDigests returns the registry digests of a local image in the repository of ref. There are none
+// for an image that has never been pulled from or pushed to that repository.
+func manifestDigests(summary image.Summary, ref string) []string {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil
+	}
+	var digests []string
+	for _, repoDigest := range summary.RepoDigests {
+		candidate, err := reference.ParseNormalizedNamed(repoDigest)
+		if err != nil {
+			continue
+		}
+		if candidate, ok := candidate.(reference.Canonical); ok && candidate.Name() == named.Name() {
+			digests = append(digests, candidate.Digest().String())
+		}
+	}
+	return digests
+}
+
+// resolveImageLock resolves every image reference of the declared containers to its local image.
+// The digests of every image are returned along with the lock.
+func resolveImageLock(images []image.Summary, config *parser.Configuration) (ImageLock, map[string][]string, error) {
+	lock := ImageLock{Images: map[string]LockedImage{}}
+	digests := map[string][]string{}
+	for _, containerInfo := range declaredContainers(config) {
+		summary := findImage(images, containerInfo.Image)
+		if summary == nil {
+			return lock, nil, fmt.Errorf("image %s of container %s is not present locally", containerInfo.Image, containerInfo.ContainerName)
+		}
+		locked := LockedImage{ID: summary.ID}
+		digests[containerInfo.Image] = manifestDigests(*summary, containerInfo.Image)
+		if len(digests[containerInfo.Image]) > 0 {
+			locked.Digest = digests[containerInfo.Image][0]
+		}
+		lock.Images[containerInfo.Image] = locked
+	}
+	return lock, digests, nil
+}
+
+// LockImages makes sure the containers run the images recorded in the lockfile at path. Missing images are loaded
+// or pulled first. When the lockfile does not exist yet, the resolved images are recorded in it. Otherwise every local image
+// has to match its record: the registry digest when one was recorded, the image ID for images built locally.
+func LockImages(ctx context.Context, cli ContainerController, log logr.Logger, config *parser.Configuration, path string) error {
+	for _, containerInfo := range declaredContainers(config) {
+		if err := ensureImage(ctx, cli, log, &containerInfo, config); err != nil {
+			return err
+		}
+	}
+	images, err := cli.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return err
+	}
+	current, digests, err := resolveImageLock(images, config)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = json.MarshalIndent(current, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write lockfile: %w", err)
+		}
+		log.Info("Recorded image digests in lockfile", "lockfile", path, "images", len(current.Images))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read lockfile: %w", err)
+	}
+	var locked ImageLock
+	if err := json.Unmarshal(data, &locked); err != nil {
+		return fmt.Errorf("failed to parse lockfile %s: %w", path, err)
+	}
+
+	var errs []error
+	checked := map[string]bool{}
+	for _, containerInfo := range declaredContainers(config) {
+		ref := containerInfo.Image
+		if checked[ref] {
+			continue
+		}
+		checked[ref] = true
+		want, ok := locked.Images[ref]
+		got := current.Images[ref]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("image %s is not recorded in lockfile %s", ref, path))
+		case want.Digest != "" && !slices.Contains(digests[ref], want.Digest):
+			errs = append(errs, fmt.Errorf("image %s has digests %v, but lockfile %s requires %s", ref, digests[ref], path, want.Digest))
+		case want.Digest == "" && got.ID != want.ID:
+			errs = append(errs, fmt.Errorf("image %s has ID %s, but lockfile %s requires %s", ref, got.ID, path, want.ID))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	log.Info("Images match lockfile", "lockfile", path)
+	return nil
+}
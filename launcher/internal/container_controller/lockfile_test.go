@@ -0,0 +1,124 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types/image"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	ubuntuDigest = "sha256:3d1556a8a18cf5307b121e0a98e93f1ddf1f3f8e092f1fddfd941254785b95d7"
+	otherDigest  = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+func TestFindImage(t *testing.T) {
+	images := []image.Summary{
+		{ID: "sha256:ubuntu", RepoTags: []string{"ubuntu:22.04"}, RepoDigests: []string{"ubuntu@" + ubuntuDigest}},
+		{ID: "sha256:podman", RepoTags: []string{"docker.io/library/debian:12"}, RepoDigests: []string{"docker.io/library/debian@" + otherDigest}},
+		{ID: "sha256:local", RepoTags: []string{"tiber-broadcast-suite:latest"}},
+	}
+	tests := []struct {
+		ref string
+		id  string
+	}{
+		{ref: "ubuntu:22.04", id: "sha256:ubuntu"},
+		{ref: "docker.io/library/ubuntu:22.04", id: "sha256:ubuntu"},
+		{ref: "ubuntu@" + ubuntuDigest, id: "sha256:ubuntu"},
+		{ref: "ubuntu:24.04@" + ubuntuDigest, id: "sha256:ubuntu"},
+		{ref: "ubuntu@" + otherDigest},
+		{ref: "debian:12", id: "sha256:podman"},
+		{ref: "debian@" + otherDigest, id: "sha256:podman"},
+		{ref: "tiber-broadcast-suite:latest", id: "sha256:local"},
+		{ref: "tiber-broadcast-suite", id: "sha256:local"},
+		{ref: "tiber-broadcast-suite:v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			found := findImage(images, tt.ref)
+			if tt.id == "" {
+				assert.Nil(t, found)
+			} else if assert.NotNil(t, found) {
+				assert.Equal(t, tt.id, found.ID)
+			}
+		})
+	}
+}
+
+func TestLockImages(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+	config := &parser.Configuration{
+		RunOnce: parser.RunOnce{MediaProxyAgent: workloads.MediaProxyAgentConfig{ImageAndTag: "ubuntu:22.04"}},
+		WorkloadToBeRun: []workloads.WorkloadConfig{{
+			FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: "ffmpeg-pipeline", ImageAndTag: "tiber-broadcast-suite:latest"},
+			NmosClient:     workloads.NmosClientConfig{Name: "nmos-client", ImageAndTag: "tiber-broadcast-suite:latest"},
+		}},
+	}
+	pinned := []image.Summary{
+		{ID: "sha256:ubuntu", RepoTags: []string{"ubuntu:22.04"}, RepoDigests: []string{"ubuntu@" + ubuntuDigest}},
+		{ID: "sha256:local", RepoTags: []string{"tiber-broadcast-suite:latest"}},
+	}
+
+	t.Run("Records the images on the first run", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bcs.lock")
+		mockController := new(MockContainerController)
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return(pinned, nil)
+
+		assert.NoError(t, LockImages(ctx, mockController, log, config, path))
+
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		var lock ImageLock
+		assert.NoError(t, json.Unmarshal(data, &lock))
+		assert.Equal(t, ImageLock{Images: map[string]LockedImage{
+			"ubuntu:22.04":                 {Digest: ubuntuDigest, ID: "sha256:ubuntu"},
+			"tiber-broadcast-suite:latest": {ID: "sha256:local"},
+		}}, lock)
+
+		// the next run finds the same images
+		assert.NoError(t, LockImages(ctx, mockController, log, config, path))
+	})
+
+	t.Run("Refuses images not matching the lockfile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bcs.lock")
+		firstRun := new(MockContainerController)
+		firstRun.On("ImageList", ctx, image.ListOptions{}).Return(pinned, nil)
+		assert.NoError(t, LockImages(ctx, firstRun, log, config, path))
+
+		// both tags have been moved to other images since the lockfile was written
+		mockController := new(MockContainerController)
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{
+			{ID: "sha256:ubuntu-new", RepoTags: []string{"ubuntu:22.04"}, RepoDigests: []string{"ubuntu@" + otherDigest}},
+			{ID: "sha256:local-new", RepoTags: []string{"tiber-broadcast-suite:latest"}},
+		}, nil)
+
+		err := LockImages(ctx, mockController, log, config, path)
+		assert.EqualError(t, err, ""+
+			"image ubuntu:22.04 has digests ["+otherDigest+"], but lockfile "+path+" requires "+ubuntuDigest+"\n"+
+			"image tiber-broadcast-suite:latest has ID sha256:local-new, but lockfile "+path+" requires sha256:local")
+	})
+
+	t.Run("Refuses images missing in the lockfile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bcs.lock")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"images": {"ubuntu:22.04": {"digest": "`+ubuntuDigest+`", "id": "sha256:ubuntu"}}}`), 0644))
+		mockController := new(MockContainerController)
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return(pinned, nil)
+
+		err := LockImages(ctx, mockController, log, config, path)
+		assert.EqualError(t, err, "image tiber-broadcast-suite:latest is not recorded in lockfile "+path)
+	})
+}
@@ -0,0 +1,295 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/go-logr/logr"
+)
+
+// LogOptions controls where the LogCollector writes the logs of the containers and how long they are kept.
+type LogOptions struct {
+	Dir      string        // directory of the log files, the archives are written to its archive subdirectory
+	MaxSize  int64         // size in bytes a log file is rotated at, 0 disables the rotation
+	MaxAge   time.Duration // age rotated log files and archives are removed at, 0 keeps them
+	MaxFiles int           // rotated log files and archives kept per container, 0 keeps all of them
+}
+
+// DefaultLogOptions returns the limits used by the launcher when no flags override them.
+func DefaultLogOptions() LogOptions {
+	return LogOptions{
+		MaxSize:  10 << 20,
+		MaxAge:   7 * 24 * time.Hour,
+		MaxFiles: 5,
+	}
+}
+
+// logRotationTimeFormat is the suffix of rotated log files and archives. It sorts in time order.
+const logRotationTimeFormat = "20060102T150405.000000000"
+
+var (
+	// logPollInterval is the pause between two collections of the logs of a container that is not running.
+	logPollInterval = 2 * time.Second
+	// logPruneInterval is the pause between two removals of expired log files.
+	logPruneInterval = time.Hour
+)
+
+// rotatingFile is the log file of a container. It is renamed with a timestamp suffix when it reaches MaxSize,
+// and the rotated files of the container exceeding MaxAge or MaxFiles are removed then.
+type rotatingFile struct {
+	dir, name string
+	opts      LogOptions
+	file      *os.File
+	size      int64
+}
+
+func openRotatingFile(dir, name string, opts LogOptions) (*rotatingFile, error) {
+	r := &rotatingFile{dir: dir, name: name, opts: opts}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) path() string {
+	return filepath.Join(r.dir, r.name+".log")
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.opts.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.opts.MaxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(r.path(), r.path()+"."+time.Now().UTC().Format(logRotationTimeFormat)); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	return pruneLogFiles(filepath.Join(r.dir, r.name+".log.*"), r.opts)
+}
+
+func (r *rotatingFile) Close() error {
+	return r.file.Close()
+}
+
+// pruneLogFiles removes the files matching pattern that are older than MaxAge, then the oldest ones
+// exceeding MaxFiles. The files are ordered by their names, which end with the time they were written.
+func pruneLogFiles(pattern string, opts LogOptions) error {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	var kept []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if opts.MaxAge > 0 && time.Since(info.ModTime()) > opts.MaxAge {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		kept = append(kept, path)
+	}
+	if opts.MaxFiles > 0 && len(kept) > opts.MaxFiles {
+		for _, path := range kept[:len(kept)-opts.MaxFiles] {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LogCollector writes the stdout and stderr of the containers declared in the launcher configuration
+// to rotating files in LogOptions.Dir. It wraps the container controller of the launcher, so the logs of every
+// container removed through it are archived first, also when the collector is not running.
+type LogCollector struct {
+	ContainerController
+	log    logr.Logger
+	config *parser.Configuration
+	opts   LogOptions
+}
+
+func NewLogCollector(cli ContainerController, log logr.Logger, config *parser.Configuration, opts LogOptions) *LogCollector {
+	return &LogCollector{ContainerController: cli, log: log, config: config, opts: opts}
+}
+
+// Run follows the logs of every declared container until ctx is cancelled. Logs are appended to
+// <dir>/<container>.log. A container that is not running is checked again every logPollInterval,
+// so the logs of its next run are collected too.
+func (c *LogCollector) Run(ctx context.Context) error {
+	if err := os.MkdirAll(c.opts.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	var wg sync.WaitGroup
+	for _, declared := range declaredContainers(c.config) {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			c.follow(ctx, name)
+		}(declared.ContainerName)
+	}
+	c.log.Info("Collecting container logs", "dir", c.opts.Dir, "maxSize", c.opts.MaxSize, "maxAge", c.opts.MaxAge, "maxFiles", c.opts.MaxFiles)
+
+	ticker := time.NewTicker(logPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case <-ticker.C:
+			c.prune()
+		}
+	}
+}
+
+func (c *LogCollector) follow(ctx context.Context, name string) {
+	for {
+		if err := c.collect(ctx, name); err != nil && ctx.Err() == nil {
+			c.log.Error(err, "Failed to collect container logs", "container", name)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+// collect appends the logs a container has written since the last change of its log file, following them while
+// the container is running. A container that does not exist is skipped.
+func (c *LogCollector) collect(ctx context.Context, name string) error {
+	info, err := c.ContainerInspect(ctx, name)
+	if client.IsErrNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var since time.Time
+	if stat, err := os.Stat(filepath.Join(c.opts.Dir, name+".log")); err == nil {
+		since = stat.ModTime()
+	}
+	file, err := openRotatingFile(c.opts.Dir, name, c.opts)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	running := info.State != nil && info.State.Running
+	tty := info.Config != nil && info.Config.Tty
+	return c.copyLogs(ctx, name, since, running, tty, file)
+}
+
+// copyLogs copies the stdout and stderr of a container written after since to w, each line prefixed with its timestamp.
+func (c *LogCollector) copyLogs(ctx context.Context, name string, since time.Time, follow, tty bool, w io.Writer) error {
+	options := container.LogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true, Follow: follow}
+	if !since.IsZero() {
+		options.Since = fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond())
+	}
+	stream, err := c.ContainerController.ContainerLogs(ctx, name, options)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	if tty {
+		_, err = io.Copy(w, stream)
+	} else {
+		_, err = stdcopy.StdCopy(w, w, stream)
+	}
+	return err
+}
+
+// Archive writes all logs of a container to <dir>/archive/<container>.<time>.log.gz.
+func (c *LogCollector) Archive(ctx context.Context, name string) error {
+	info, err := c.ContainerInspect(ctx, name)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(c.opts.Dir, "archive")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name+"."+time.Now().UTC().Format(logRotationTimeFormat)+".log.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	archive := gzip.NewWriter(file)
+	if err := c.copyLogs(ctx, name, time.Time{}, false, info.Config != nil && info.Config.Tty, archive); err != nil {
+		archive.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	c.log.Info("Archived container logs", "container", name, "archive", path)
+	return pruneLogFiles(filepath.Join(dir, name+".*.log.gz"), c.opts)
+}
+
+// ContainerRemove archives the logs of a container before it removes it. A failed archive does not
+// prevent the removal.
+func (c *LogCollector) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	if err := c.Archive(ctx, containerID); err != nil && !client.IsErrNotFound(err) {
+		c.log.Error(err, "Failed to archive container logs", "container", containerID)
+	}
+	return c.ContainerController.ContainerRemove(ctx, containerID, options)
+}
+
+// prune removes the rotated log files and archives older than MaxAge.
+func (c *LogCollector) prune() {
+	for _, pattern := range []string{"*.log.*", filepath.Join("archive", "*.log.gz")} {
+		if err := pruneLogFiles(filepath.Join(c.opts.Dir, pattern), LogOptions{MaxAge: c.opts.MaxAge}); err != nil {
+			c.log.Error(err, "Failed to remove expired log files", "dir", c.opts.Dir)
+		}
+	}
+}
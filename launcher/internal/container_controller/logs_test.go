@@ -0,0 +1,128 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// logStream returns a multiplexed log stream as Docker sends it for containers without a TTY.
+func logStream(stdout, stderr string) io.ReadCloser {
+	var buf bytes.Buffer
+	stdcopy.NewStdWriter(&buf, stdcopy.Stdout).Write([]byte(stdout))
+	stdcopy.NewStdWriter(&buf, stdcopy.Stderr).Write([]byte(stderr))
+	return io.NopCloser(&buf)
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	file, err := openRotatingFile(dir, "ffmpeg-pipeline", LogOptions{MaxSize: 10, MaxFiles: 2})
+	assert.NoError(t, err)
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		_, err := file.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, file.Close())
+
+	current, err := os.ReadFile(filepath.Join(dir, "ffmpeg-pipeline.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, "line 4\n", string(current))
+	rotated, _ := filepath.Glob(filepath.Join(dir, "ffmpeg-pipeline.log.*"))
+	assert.Len(t, rotated, 2, "only MaxFiles rotated files are kept")
+	oldest, err := os.ReadFile(rotated[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "line 2\n", string(oldest))
+}
+
+func TestPruneLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"nmos-client.log.1", "nmos-client.log.2", "nmos-client.log"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("log"), 0644))
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "nmos-client.log.1"), old, old))
+
+	assert.NoError(t, pruneLogFiles(filepath.Join(dir, "nmos-client.log.*"), LogOptions{MaxAge: 24 * time.Hour}))
+
+	remaining, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Equal(t, []string{filepath.Join(dir, "nmos-client.log"), filepath.Join(dir, "nmos-client.log.2")}, remaining)
+}
+
+func TestLogCollectorCollect(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	stopped := container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{State: &container.State{Running: false}}, Config: &container.Config{}}
+
+	mockController := new(MockContainerController)
+	mockController.On("ContainerInspect", ctx, "ffmpeg-pipeline").Return(stopped, nil)
+	mockController.On("ContainerLogs", ctx, "ffmpeg-pipeline", container.LogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true}).
+		Return(logStream("2026-10-18T10:00:00Z started\n", "2026-10-18T10:00:01Z warning\n"), nil).Once()
+	mockController.On("ContainerLogs", ctx, "ffmpeg-pipeline", mock.MatchedBy(func(options container.LogsOptions) bool {
+		return options.Since != "" && !options.Follow
+	})).Return(logStream("2026-10-18T10:05:00Z restarted\n", ""), nil).Once()
+	collector := NewLogCollector(mockController, logr.Discard(), &parser.Configuration{}, LogOptions{Dir: dir})
+
+	assert.NoError(t, collector.collect(ctx, "ffmpeg-pipeline"))
+	// the next collection only asks for the logs written since the last one
+	assert.NoError(t, collector.collect(ctx, "ffmpeg-pipeline"))
+	mockController.AssertExpectations(t)
+
+	content, err := os.ReadFile(filepath.Join(dir, "ffmpeg-pipeline.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-10-18T10:00:00Z started\n2026-10-18T10:00:01Z warning\n2026-10-18T10:05:00Z restarted\n", string(content))
+
+	mockController.On("ContainerInspect", ctx, "nmos-client").Return(container.InspectResponse{}, errdefs.NotFound(errors.New("no such container")))
+	assert.NoError(t, collector.collect(ctx, "nmos-client"), "missing containers are skipped")
+}
+
+func TestLogCollectorContainerRemove(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	running := container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{State: &container.State{Running: true}}, Config: &container.Config{}}
+
+	mockController := new(MockContainerController)
+	mockController.On("ContainerInspect", ctx, "ffmpeg-pipeline").Return(running, nil)
+	mockController.On("ContainerLogs", ctx, "ffmpeg-pipeline", container.LogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true}).
+		Return(logStream("2026-10-18T10:00:00Z last words\n", ""), nil)
+	mockController.On("ContainerRemove", ctx, "ffmpeg-pipeline", container.RemoveOptions{Force: true}).Return(nil)
+	mockController.On("ContainerInspect", ctx, "nmos-client").Return(container.InspectResponse{}, errdefs.NotFound(errors.New("no such container")))
+	mockController.On("ContainerRemove", ctx, "nmos-client", container.RemoveOptions{Force: true}).Return(errdefs.NotFound(errors.New("no such container")))
+	collector := NewLogCollector(mockController, logr.Discard(), &parser.Configuration{}, LogOptions{Dir: dir})
+
+	assert.NoError(t, removeContainer(ctx, collector, "ffmpeg-pipeline"))
+	assert.Error(t, removeContainer(ctx, collector, "nmos-client"))
+	mockController.AssertExpectations(t)
+
+	archives, _ := filepath.Glob(filepath.Join(dir, "archive", "*"))
+	if assert.Len(t, archives, 1) {
+		assert.Regexp(t, `ffmpeg-pipeline\.\d{8}T\d{6}\.\d{9}\.log\.gz$`, archives[0])
+		file, err := os.Open(archives[0])
+		assert.NoError(t, err)
+		defer file.Close()
+		archive, err := gzip.NewReader(file)
+		assert.NoError(t, err)
+		content, err := io.ReadAll(archive)
+		assert.NoError(t, err)
+		assert.Equal(t, "2026-10-18T10:00:00Z last words\n", string(content))
+	}
+}
@@ -0,0 +1,246 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/go-logr/logr"
+)
+
+// predefinedNetworks exist on every Docker host and are never created by the launcher.
+var predefinedNetworks = map[string]bool{"host": true, "bridge": true, "none": true}
+
+// networkRequest is what the containers of the launcher configuration need from one custom network.
+type networkRequest struct {
+	name       string
+	definition workloads.NetworkConfig // network definition (driver, subnet, ...) shared by all containers using the network
+	definedBy  string                  // container whose configuration provides the definition
+	ips        map[string]string       // static IP -> container name
+	ipOrder    []string
+}
+
+// containerNetworkConfig returns the custom network configuration of a declared container.
+func containerNetworkConfig(containerInfo general.Containers, config *parser.Configuration) workloads.NetworkConfig {
+	switch containerInfo.Type {
+	case general.MediaProxyAgent:
+		return config.RunOnce.MediaProxyAgent.Network
+	case general.MediaProxyMCM:
+		return config.RunOnce.MediaProxyMcm[containerInfo.Id].Network
+	case general.BcsPipelineFfmpeg:
+		return config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.Network
+	case general.BcsPipelineNmosClient:
+		return config.WorkloadToBeRun[containerInfo.Id].NmosClient.Network
+	}
+	return workloads.NetworkConfig{}
+}
+
+// networkDefinition strips the container specific fields from the network configuration.
+func networkDefinition(networkConfig workloads.NetworkConfig) workloads.NetworkConfig {
+	networkConfig.Enable, networkConfig.Name, networkConfig.IP = false, "", ""
+	return networkConfig
+}
+
+// requestedNetworks collects the custom networks used by the declared containers in declaration order.
+// All containers defining the same network have to define it identically and must not share a static IP.
+func requestedNetworks(config *parser.Configuration) ([]*networkRequest, error) {
+	var requests []*networkRequest
+	byName := map[string]*networkRequest{}
+	for _, containerInfo := range declaredContainers(config) {
+		networkConfig := containerNetworkConfig(containerInfo, config)
+		if !networkConfig.Enable || networkConfig.Name == "" || predefinedNetworks[networkConfig.Name] {
+			continue
+		}
+		request, ok := byName[networkConfig.Name]
+		if !ok {
+			request = &networkRequest{name: networkConfig.Name, ips: map[string]string{}}
+			byName[networkConfig.Name] = request
+			requests = append(requests, request)
+		}
+
+		definition := networkDefinition(networkConfig)
+		if !IsEmptyStruct(definition) {
+			if request.definedBy == "" {
+				request.definition, request.definedBy = definition, containerInfo.ContainerName
+			} else if !reflect.DeepEqual(request.definition, definition) {
+				return nil, fmt.Errorf("network %s is defined differently by containers %s and %s", request.name, request.definedBy, containerInfo.ContainerName)
+			}
+		}
+
+		if networkConfig.IP != "" {
+			if other, taken := request.ips[networkConfig.IP]; taken {
+				return nil, fmt.Errorf("IP %s in network %s is requested by containers %s and %s", networkConfig.IP, request.name, other, containerInfo.ContainerName)
+			}
+			request.ips[networkConfig.IP] = containerInfo.ContainerName
+			request.ipOrder = append(request.ipOrder, networkConfig.IP)
+		}
+	}
+	return requests, nil
+}
+
+// validate checks the network definition and that every static IP lies within its subnet.
+func (r *networkRequest) validate() error {
+	definition := r.definition
+	if definition.Parent != "" && definition.Driver != "macvlan" && definition.Driver != "ipvlan" {
+		return fmt.Errorf("network %s: parent interface is supported only by macvlan and ipvlan drivers, not by %q", r.name, definition.Driver)
+	}
+	if definition.Subnet == "" {
+		if definition.Gateway != "" || definition.IPRange != "" {
+			return fmt.Errorf("network %s: gateway and ipRange require a subnet", r.name)
+		}
+		return nil
+	}
+
+	_, subnet, err := net.ParseCIDR(definition.Subnet)
+	if err != nil {
+		return fmt.Errorf("network %s: invalid subnet: %w", r.name, err)
+	}
+	if definition.Gateway != "" {
+		gateway := net.ParseIP(definition.Gateway)
+		if gateway == nil || !subnet.Contains(gateway) {
+			return fmt.Errorf("network %s: gateway %s is not within subnet %s", r.name, definition.Gateway, definition.Subnet)
+		}
+	}
+	if definition.IPRange != "" {
+		rangeIP, ipRange, err := net.ParseCIDR(definition.IPRange)
+		if err != nil {
+			return fmt.Errorf("network %s: invalid ipRange: %w", r.name, err)
+		}
+		rangeSize, _ := ipRange.Mask.Size()
+		subnetSize, _ := subnet.Mask.Size()
+		if !subnet.Contains(rangeIP) || rangeSize < subnetSize {
+			return fmt.Errorf("network %s: ipRange %s is not within subnet %s", r.name, definition.IPRange, definition.Subnet)
+		}
+	}
+	for _, ip := range r.ipOrder {
+		if ip == definition.Gateway {
+			return fmt.Errorf("network %s: IP %s of container %s is the gateway address", r.name, ip, r.ips[ip])
+		}
+	}
+	return r.checkIPs([]string{definition.Subnet})
+}
+
+// checkIPs verifies that every static IP requested in the network lies within one of the subnets.
+func (r *networkRequest) checkIPs(subnets []string) error {
+	var parsed []*net.IPNet
+	for _, s := range subnets {
+		_, subnet, err := net.ParseCIDR(s)
+		if err == nil {
+			parsed = append(parsed, subnet)
+		}
+	}
+	for _, ip := range r.ipOrder {
+		address := net.ParseIP(ip)
+		if address == nil {
+			return fmt.Errorf("network %s: invalid IP %s of container %s", r.name, ip, r.ips[ip])
+		}
+		contained := false
+		for _, subnet := range parsed {
+			contained = contained || subnet.Contains(address)
+		}
+		if !contained {
+			return fmt.Errorf("network %s: IP %s of container %s is not within subnets %v", r.name, ip, r.ips[ip], subnets)
+		}
+	}
+	return nil
+}
+
+// createOptions translates the network definition into the options of the Docker network.
+func (r *networkRequest) createOptions() network.CreateOptions {
+	definition := r.definition
+	options := network.CreateOptions{Driver: definition.Driver}
+	if options.Driver == "" {
+		options.Driver = "bridge"
+	}
+	if len(definition.DriverOptions) > 0 || definition.Parent != "" {
+		options.Options = map[string]string{}
+		for key, value := range definition.DriverOptions {
+			options.Options[key] = value
+		}
+		if definition.Parent != "" {
+			options.Options["parent"] = definition.Parent
+		}
+	}
+	if definition.Subnet != "" || definition.IPAM.Driver != "" || len(definition.IPAM.Options) > 0 {
+		options.IPAM = &network.IPAM{Driver: definition.IPAM.Driver, Options: definition.IPAM.Options}
+		if definition.Subnet != "" {
+			options.IPAM.Config = []network.IPAMConfig{{Subnet: definition.Subnet, IPRange: definition.IPRange, Gateway: definition.Gateway}}
+		}
+	}
+	return options
+}
+
+// EnsureNetworks creates the custom networks used by the launcher configuration when they are missing
+// and checks that existing networks match their definition and contain the requested static IPs.
+func EnsureNetworks(ctx context.Context, cli ContainerController, log logr.Logger, config *parser.Configuration) error {
+	requests, err := requestedNetworks(config)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, request := range requests {
+		if err := request.validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := ensureNetwork(ctx, cli, log, request); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func ensureNetwork(ctx context.Context, cli ContainerController, log logr.Logger, request *networkRequest) error {
+	info, err := cli.NetworkInspect(ctx, request.name, network.InspectOptions{})
+	if client.IsErrNotFound(err) {
+		if len(request.ips) > 0 && request.definition.Subnet == "" {
+			return fmt.Errorf("network %s does not exist and static IPs require a subnet: set custom_network.subnet or create the network in advance", request.name)
+		}
+		options := request.createOptions()
+		log.Info("Creating network", "network", request.name, "driver", options.Driver, "subnet", request.definition.Subnet, "parent", request.definition.Parent)
+		_, err = cli.NetworkCreate(ctx, request.name, options)
+		if errdefs.IsConflict(err) {
+			// created in the meantime by someone else, validate it like any other existing network
+			return ensureNetwork(ctx, cli, log, request)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create network %s: %w", request.name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect network %s: %w", request.name, err)
+	}
+
+	if request.definition.Driver != "" && info.Driver != request.definition.Driver {
+		return fmt.Errorf("network %s exists with driver %s, but driver %s is configured", request.name, info.Driver, request.definition.Driver)
+	}
+	var subnets []string
+	for _, ipamConfig := range info.IPAM.Config {
+		subnets = append(subnets, ipamConfig.Subnet)
+	}
+	if request.definition.Subnet != "" && !slices.Contains(subnets, request.definition.Subnet) {
+		return fmt.Errorf("network %s exists with subnets %v, but subnet %s is configured", request.name, subnets, request.definition.Subnet)
+	}
+	if err := request.checkIPs(subnets); err != nil {
+		return err
+	}
+	log.Info("Network exists", "network", request.name, "driver", info.Driver, "subnets", subnets)
+	return nil
+}
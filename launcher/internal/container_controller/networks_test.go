@@ -0,0 +1,190 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func st2110Network(ip string) workloads.NetworkConfig {
+	return workloads.NetworkConfig{
+		Enable:        true,
+		Name:          "st2110-net",
+		IP:            ip,
+		Driver:        "macvlan",
+		Subnet:        "192.168.50.0/24",
+		Gateway:       "192.168.50.1",
+		Parent:        "ens801f0",
+		DriverOptions: map[string]string{"macvlan_mode": "bridge"},
+	}
+}
+
+func networksTestConfig() *parser.Configuration {
+	config := startupTestConfig(2)
+	config.RunOnce = parser.RunOnce{}
+	config.WorkloadToBeRun[0].FfmpegPipeline.Network = st2110Network("192.168.50.10")
+	config.WorkloadToBeRun[1].FfmpegPipeline.Network = st2110Network("192.168.50.11")
+	// the NMOS client only refers to the network defined by the pipelines
+	config.WorkloadToBeRun[0].NmosClient.Network = workloads.NetworkConfig{Enable: true, Name: "st2110-net", IP: "192.168.50.20"}
+	return config
+}
+
+func TestRequestedNetworks(t *testing.T) {
+	t.Run("Merges containers using the same network", func(t *testing.T) {
+		requests, err := requestedNetworks(networksTestConfig())
+
+		assert.NoError(t, err)
+		assert.Len(t, requests, 1)
+		assert.Equal(t, "ffmpeg-pipeline-0", requests[0].definedBy)
+		assert.Equal(t, []string{"192.168.50.10", "192.168.50.20", "192.168.50.11"}, requests[0].ipOrder)
+		assert.NoError(t, requests[0].validate())
+	})
+
+	t.Run("Rejects different definitions of the same network", func(t *testing.T) {
+		config := networksTestConfig()
+		config.WorkloadToBeRun[1].FfmpegPipeline.Network.Subnet = "192.168.60.0/24"
+
+		_, err := requestedNetworks(config)
+		assert.EqualError(t, err, "network st2110-net is defined differently by containers ffmpeg-pipeline-0 and ffmpeg-pipeline-1")
+	})
+
+	t.Run("Rejects the same static IP used twice", func(t *testing.T) {
+		config := networksTestConfig()
+		config.WorkloadToBeRun[1].FfmpegPipeline.Network.IP = "192.168.50.10"
+
+		_, err := requestedNetworks(config)
+		assert.EqualError(t, err, "IP 192.168.50.10 in network st2110-net is requested by containers ffmpeg-pipeline-0 and ffmpeg-pipeline-1")
+	})
+
+	t.Run("Ignores disabled and predefined networks", func(t *testing.T) {
+		config := networksTestConfig()
+		config.WorkloadToBeRun[0].FfmpegPipeline.Network.Enable = false
+		config.WorkloadToBeRun[0].NmosClient.Network.Name = "host"
+		config.WorkloadToBeRun[1].FfmpegPipeline.Network.Enable = false
+
+		requests, err := requestedNetworks(config)
+		assert.NoError(t, err)
+		assert.Empty(t, requests)
+	})
+}
+
+func TestNetworkRequestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition workloads.NetworkConfig
+		ip         string
+		err        string
+	}{
+		{name: "valid macvlan", definition: st2110Network(""), ip: "192.168.50.10"},
+		{name: "IP outside subnet", definition: st2110Network(""), ip: "10.0.0.1", err: "network st2110-net: IP 10.0.0.1 of container c is not within subnets [192.168.50.0/24]"},
+		{name: "IP is gateway", definition: st2110Network(""), ip: "192.168.50.1", err: "network st2110-net: IP 192.168.50.1 of container c is the gateway address"},
+		{name: "gateway outside subnet", definition: workloads.NetworkConfig{Subnet: "192.168.50.0/24", Gateway: "192.168.51.1"}, err: "network st2110-net: gateway 192.168.51.1 is not within subnet 192.168.50.0/24"},
+		{name: "ipRange outside subnet", definition: workloads.NetworkConfig{Subnet: "192.168.50.0/24", IPRange: "192.168.0.0/16"}, err: "network st2110-net: ipRange 192.168.0.0/16 is not within subnet 192.168.50.0/24"},
+		{name: "parent with bridge driver", definition: workloads.NetworkConfig{Parent: "ens801f0"}, err: "network st2110-net: parent interface is supported only by macvlan and ipvlan drivers, not by \"\""},
+		{name: "gateway without subnet", definition: workloads.NetworkConfig{Gateway: "192.168.50.1"}, err: "network st2110-net: gateway and ipRange require a subnet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &networkRequest{name: "st2110-net", definition: networkDefinition(tt.definition), ips: map[string]string{}}
+			if tt.ip != "" {
+				request.ips[tt.ip] = "c"
+				request.ipOrder = []string{tt.ip}
+			}
+			err := request.validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEnsureNetworks(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+	notFound := errdefs.NotFound(errors.New("network st2110-net not found"))
+
+	t.Run("Creates a missing macvlan network", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("NetworkInspect", ctx, "st2110-net", network.InspectOptions{}).Return(network.Inspect{}, notFound)
+		mockController.On("NetworkCreate", ctx, "st2110-net", network.CreateOptions{
+			Driver:  "macvlan",
+			Options: map[string]string{"macvlan_mode": "bridge", "parent": "ens801f0"},
+			IPAM:    &network.IPAM{Config: []network.IPAMConfig{{Subnet: "192.168.50.0/24", Gateway: "192.168.50.1"}}},
+		}).Return(network.CreateResponse{ID: "net-id"}, nil)
+
+		err := EnsureNetworks(ctx, mockController, log, networksTestConfig())
+		mockController.AssertExpectations(t)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Keeps a matching existing network", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("NetworkInspect", ctx, "st2110-net", network.InspectOptions{}).Return(network.Inspect{
+			Name:   "st2110-net",
+			Driver: "macvlan",
+			IPAM:   network.IPAM{Config: []network.IPAMConfig{{Subnet: "192.168.50.0/24"}}},
+		}, nil)
+
+		err := EnsureNetworks(ctx, mockController, log, networksTestConfig())
+		mockController.AssertExpectations(t)
+		assert.NoError(t, err)
+		mockController.AssertNotCalled(t, "NetworkCreate", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Rejects an existing network with a different subnet", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("NetworkInspect", ctx, "st2110-net", network.InspectOptions{}).Return(network.Inspect{
+			Name:   "st2110-net",
+			Driver: "macvlan",
+			IPAM:   network.IPAM{Config: []network.IPAMConfig{{Subnet: "10.10.0.0/16"}}},
+		}, nil)
+
+		err := EnsureNetworks(ctx, mockController, log, networksTestConfig())
+		assert.EqualError(t, err, "network st2110-net exists with subnets [10.10.0.0/16], but subnet 192.168.50.0/24 is configured")
+	})
+
+	t.Run("Rejects static IPs outside an existing network defined only by name", func(t *testing.T) {
+		config := startupTestConfig(1)
+		config.RunOnce = parser.RunOnce{}
+		config.WorkloadToBeRun[0].FfmpegPipeline.Network = workloads.NetworkConfig{Enable: true, Name: "bcs-net", IP: "10.0.0.2"}
+
+		mockController := new(MockContainerController)
+		mockController.On("NetworkInspect", ctx, "bcs-net", network.InspectOptions{}).Return(network.Inspect{
+			Name:   "bcs-net",
+			Driver: "bridge",
+			IPAM:   network.IPAM{Config: []network.IPAMConfig{{Subnet: "172.18.0.0/16"}}},
+		}, nil)
+
+		err := EnsureNetworks(ctx, mockController, log, config)
+		assert.EqualError(t, err, "network bcs-net: IP 10.0.0.2 of container ffmpeg-pipeline-0 is not within subnets [172.18.0.0/16]")
+	})
+
+	t.Run("Refuses to create a network for static IPs without a subnet", func(t *testing.T) {
+		config := startupTestConfig(1)
+		config.RunOnce = parser.RunOnce{}
+		config.WorkloadToBeRun[0].FfmpegPipeline.Network = workloads.NetworkConfig{Enable: true, Name: "bcs-net", IP: "10.0.0.2"}
+
+		mockController := new(MockContainerController)
+		mockController.On("NetworkInspect", ctx, "bcs-net", network.InspectOptions{}).Return(network.Inspect{}, errdefs.NotFound(errors.New("not found")))
+
+		err := EnsureNetworks(ctx, mockController, log, config)
+		assert.ErrorContains(t, err, "network bcs-net does not exist and static IPs require a subnet")
+		mockController.AssertNotCalled(t, "NetworkCreate", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,183 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/utils"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+
+	"github.com/go-logr/logr"
+)
+
+// vfioEnvironmentVariables are the environment variables of a workload carrying the PCI address of its VF.
+var vfioEnvironmentVariables = []string{"VFIO_PORT_TX", "VFIO_PORT_RX"}
+
+// CPUAllocation are the cores allocated to an FFmpeg pipeline on the NUMA node of its VF.
+type CPUAllocation struct {
+	Workload   int // index of the workload in workloadToBeRun
+	Pipeline   string
+	PCIAddress string
+	NUMANode   int   // -1 when the host does not report the NUMA node of the VF
+	CPUs       []int // allocated cores
+	Isolated   bool  // the cores are isolated from the scheduler of the host (isolcpus)
+}
+
+// formatCPUList formats CPU IDs as a kernel CPU list, e.g. 0-3,8.
+func formatCPUList(cpus []int) string {
+	sorted := slices.Clone(cpus)
+	slices.Sort(sorted)
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(sorted[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// vfPCIAddress returns the PCI address of the VF used by a workload: the one of its CPU pinning, otherwise
+// VFIO_PORT_TX or VFIO_PORT_RX of the FFmpeg pipeline or the NMOS client.
+func vfPCIAddress(workload workloads.WorkloadConfig) string {
+	if workload.FfmpegPipeline.CPUPinning.PCIAddress != "" {
+		return workload.FfmpegPipeline.CPUPinning.PCIAddress
+	}
+	for _, environment := range [][]string{workload.FfmpegPipeline.EnvironmentVariables, workload.NmosClient.EnvironmentVariables} {
+		for _, variable := range environment {
+			name, value, _ := strings.Cut(variable, "=")
+			if slices.Contains(vfioEnvironmentVariables, name) && value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// readSysfsList reads a CPU or node list from sysfs below root. A missing file is an empty list.
+func readSysfsList(root, path string) ([]int, error) {
+	content, err := os.ReadFile(filepath.Join(root, "sys", path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseCPUList(string(content))
+}
+
+// deviceLocality reads the NUMA node and the CPUs local to a PCI device from sysfs below root.
+func deviceLocality(root, pciAddress string) (int, []int, error) {
+	device := filepath.Join("bus/pci/devices", pciAddress)
+	content, err := os.ReadFile(filepath.Join(root, "sys", device, "numa_node"))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read NUMA node of PCI device %s: %w", pciAddress, err)
+	}
+	node, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid NUMA node of PCI device %s: %q", pciAddress, strings.TrimSpace(string(content)))
+	}
+	cpus, err := readSysfsList(root, filepath.Join(device, "local_cpulist"))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read local CPUs of PCI device %s: %w", pciAddress, err)
+	}
+	if len(cpus) == 0 {
+		return 0, nil, fmt.Errorf("PCI device %s reports no local CPUs", pciAddress)
+	}
+	return node, cpus, nil
+}
+
+// AllocatePipelineCPUs allocates cores to every FFmpeg pipeline with CPU pinning, in the order of the workloads.
+// The cores are taken from the CPUs local to the VF of the pipeline, read from sysfs below root. When the host
+// isolates cores (isolcpus), only isolated cores are allocated. No core is allocated twice, nor is a core any
+// container is pinned to with cpusetCpus. Pipelines with their own cpusetCpus are not allocated cores.
+func AllocatePipelineCPUs(config *parser.Configuration, root string) ([]CPUAllocation, error) {
+	if root == "" {
+		root = "/"
+	}
+	var pinned []int
+	for _, containerInfo := range declaredContainers(config) {
+		if cpuset := utils.WorkloadResources(&containerInfo, config).CpusetCpus; cpuset != "" {
+			cpus, err := parseCPUList(cpuset)
+			if err != nil {
+				return nil, fmt.Errorf("container %s: invalid cpusetCpus: %w", containerInfo.ContainerName, err)
+			}
+			pinned = append(pinned, cpus...)
+		}
+	}
+	isolated, err := readSysfsList(root, "devices/system/cpu/isolated")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read isolated CPUs: %w", err)
+	}
+
+	var allocations []CPUAllocation
+	for n, workload := range config.WorkloadToBeRun {
+		pipeline := workload.FfmpegPipeline
+		if pipeline.CPUPinning.Cores <= 0 || pipeline.Resources.CpusetCpus != "" || IsEmptyStruct(pipeline) {
+			continue
+		}
+		pciAddress := vfPCIAddress(workload)
+		if pciAddress == "" {
+			return nil, fmt.Errorf("pipeline %s: no VF to pin to, set cpuPinning.pciAddress or VFIO_PORT_TX/VFIO_PORT_RX", pipeline.Name)
+		}
+		node, local, err := deviceLocality(root, pciAddress)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %s: %w", pipeline.Name, err)
+		}
+
+		allocation := CPUAllocation{Workload: n, Pipeline: pipeline.Name, PCIAddress: pciAddress, NUMANode: node, Isolated: len(isolated) > 0}
+		var free []int
+		for _, cpu := range local {
+			if (!allocation.Isolated || slices.Contains(isolated, cpu)) && !slices.Contains(pinned, cpu) {
+				free = append(free, cpu)
+			}
+		}
+		if len(free) < pipeline.CPUPinning.Cores {
+			return nil, fmt.Errorf("pipeline %s needs %d cores local to VF %s on NUMA node %d, but only %d are free: [%s]",
+				pipeline.Name, pipeline.CPUPinning.Cores, pciAddress, node, len(free), formatCPUList(free))
+		}
+		allocation.CPUs = free[:pipeline.CPUPinning.Cores]
+		pinned = append(pinned, allocation.CPUs...)
+		allocations = append(allocations, allocation)
+	}
+	return allocations, nil
+}
+
+// PinPipelines allocates cores to the FFmpeg pipelines with CPU pinning and records the allocation as the
+// cpusetCpus of their resources, and the NUMA node of the VF as their cpusetMems unless it is set already.
+func PinPipelines(log logr.Logger, config *parser.Configuration, root string) error {
+	allocations, err := AllocatePipelineCPUs(config, root)
+	if err != nil {
+		return err
+	}
+	for _, allocation := range allocations {
+		resources := &config.WorkloadToBeRun[allocation.Workload].FfmpegPipeline.Resources
+		resources.CpusetCpus = formatCPUList(allocation.CPUs)
+		if resources.CpusetMems == "" && allocation.NUMANode >= 0 {
+			resources.CpusetMems = strconv.Itoa(allocation.NUMANode)
+		}
+		if !allocation.Isolated {
+			log.Info("Host isolates no cores, pipeline is pinned to cores shared with the host", "container", allocation.Pipeline)
+		}
+		log.Info("Pinned pipeline to cores local to its VF", "container", allocation.Pipeline, "pciAddress", allocation.PCIAddress,
+			"numaNode", allocation.NUMANode, "cpus", resources.CpusetCpus, "cpusetMems", resources.CpusetMems)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+// numaHost is a host with two NUMA nodes of 8 CPUs each, a VF on each node and cores 2-7 and 10-15 isolated.
+var numaHost = map[string]string{
+	"sys/devices/system/cpu/online":                  "0-15\n",
+	"sys/devices/system/node/online":                 "0-1\n",
+	"sys/devices/system/cpu/isolated":                "2-7,10-15\n",
+	"sys/bus/pci/devices/0000:31:01.0/numa_node":     "0\n",
+	"sys/bus/pci/devices/0000:31:01.0/local_cpulist": "0-7\n",
+	"sys/bus/pci/devices/0000:ca:11.0/numa_node":     "1\n",
+	"sys/bus/pci/devices/0000:ca:11.0/local_cpulist": "8-15\n",
+	"proc/meminfo": "MemTotal:       16384000 kB\n",
+}
+
+// pinnedWorkload declares a workload whose FFmpeg pipeline is pinned to cores cores of the VF in its NMOS client environment.
+func pinnedWorkload(name, vf string, cores int) workloads.WorkloadConfig {
+	return workloads.WorkloadConfig{
+		FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: name, ImageAndTag: "tiber-broadcast-suite:latest", CPUPinning: workloads.CPUPinning{Cores: cores}},
+		NmosClient:     workloads.NmosClientConfig{Name: name + "-nmos", ImageAndTag: "tiber-broadcast-suite-nmos-node:latest", EnvironmentVariables: []string{"http_proxy=", "VFIO_PORT_TX=" + vf}},
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	assert.Equal(t, "0-3,8,10-11", formatCPUList([]int{8, 0, 1, 2, 3, 10, 11}))
+	assert.Equal(t, "", formatCPUList(nil))
+}
+
+func TestVFPCIAddress(t *testing.T) {
+	workload := pinnedWorkload("pipeline-0", "0000:31:01.0", 2)
+	assert.Equal(t, "0000:31:01.0", vfPCIAddress(workload))
+
+	workload.FfmpegPipeline.EnvironmentVariables = []string{"VFIO_PORT_RX=0000:31:01.1"}
+	assert.Equal(t, "0000:31:01.1", vfPCIAddress(workload))
+
+	workload.FfmpegPipeline.CPUPinning.PCIAddress = "0000:ca:11.0"
+	assert.Equal(t, "0000:ca:11.0", vfPCIAddress(workload))
+
+	assert.Equal(t, "", vfPCIAddress(workloads.WorkloadConfig{}))
+}
+
+func TestAllocatePipelineCPUs(t *testing.T) {
+	root := writeHostFiles(t, numaHost)
+
+	t.Run("Allocates non-overlapping isolated cores local to the VF", func(t *testing.T) {
+		config := &parser.Configuration{WorkloadToBeRun: []workloads.WorkloadConfig{
+			pinnedWorkload("pipeline-0", "0000:31:01.0", 2),
+			pinnedWorkload("pipeline-1", "0000:ca:11.0", 4),
+			pinnedWorkload("pipeline-2", "0000:31:01.0", 3),
+			pinnedWorkload("unpinned", "0000:31:01.0", 0),
+		}}
+		// the MCM Media Proxy is pinned to core 4 explicitly
+		config.RunOnce.MediaProxyMcm = workloads.MediaProxyMcmInstances{{ImageAndTag: "mcm/media-proxy:latest"}}
+		config.RunOnce.MediaProxyMcm[0].Resources.CpusetCpus = "4"
+
+		allocations, err := AllocatePipelineCPUs(config, root)
+		assert.NoError(t, err)
+		assert.Equal(t, []CPUAllocation{
+			{Workload: 0, Pipeline: "pipeline-0", PCIAddress: "0000:31:01.0", NUMANode: 0, CPUs: []int{2, 3}, Isolated: true},
+			{Workload: 1, Pipeline: "pipeline-1", PCIAddress: "0000:ca:11.0", NUMANode: 1, CPUs: []int{10, 11, 12, 13}, Isolated: true},
+			{Workload: 2, Pipeline: "pipeline-2", PCIAddress: "0000:31:01.0", NUMANode: 0, CPUs: []int{5, 6, 7}, Isolated: true},
+		}, allocations)
+	})
+
+	t.Run("Fails when the NUMA node has not enough free cores", func(t *testing.T) {
+		config := &parser.Configuration{WorkloadToBeRun: []workloads.WorkloadConfig{
+			pinnedWorkload("pipeline-0", "0000:31:01.0", 4),
+			pinnedWorkload("pipeline-1", "0000:31:01.0", 4),
+		}}
+
+		_, err := AllocatePipelineCPUs(config, root)
+		assert.EqualError(t, err, "pipeline pipeline-1 needs 4 cores local to VF 0000:31:01.0 on NUMA node 0, but only 2 are free: [6-7]")
+	})
+
+	t.Run("Fails without a VF or with an unknown one", func(t *testing.T) {
+		workload := pinnedWorkload("pipeline-0", "", 2)
+		_, err := AllocatePipelineCPUs(&parser.Configuration{WorkloadToBeRun: []workloads.WorkloadConfig{workload}}, root)
+		assert.EqualError(t, err, "pipeline pipeline-0: no VF to pin to, set cpuPinning.pciAddress or VFIO_PORT_TX/VFIO_PORT_RX")
+
+		workload = pinnedWorkload("pipeline-0", "0000:00:00.0", 2)
+		_, err = AllocatePipelineCPUs(&parser.Configuration{WorkloadToBeRun: []workloads.WorkloadConfig{workload}}, root)
+		assert.ErrorContains(t, err, "pipeline pipeline-0: failed to read NUMA node of PCI device 0000:00:00.0")
+	})
+
+	t.Run("Uses all local cores when the host isolates none", func(t *testing.T) {
+		host := map[string]string{}
+		for path, content := range numaHost {
+			host[path] = content
+		}
+		delete(host, "sys/devices/system/cpu/isolated")
+		host["sys/bus/pci/devices/0000:31:01.0/numa_node"] = "-1\n"
+
+		allocations, err := AllocatePipelineCPUs(&parser.Configuration{WorkloadToBeRun: []workloads.WorkloadConfig{pinnedWorkload("pipeline-0", "0000:31:01.0", 2)}}, writeHostFiles(t, host))
+		assert.NoError(t, err)
+		assert.Equal(t, []CPUAllocation{{Workload: 0, Pipeline: "pipeline-0", PCIAddress: "0000:31:01.0", NUMANode: -1, CPUs: []int{0, 1}}}, allocations)
+	})
+}
+
+func TestPinPipelines(t *testing.T) {
+	root := writeHostFiles(t, numaHost)
+	config := &parser.Configuration{WorkloadToBeRun: []workloads.WorkloadConfig{
+		pinnedWorkload("pipeline-0", "0000:ca:11.0", 2),
+		pinnedWorkload("pipeline-1", "0000:ca:11.0", 2),
+	}}
+	// explicit cpusets are kept
+	config.WorkloadToBeRun[1].FfmpegPipeline.Resources.CpusetCpus = "10-11"
+
+	assert.NoError(t, PinPipelines(logr.Discard(), config, root))
+	assert.Equal(t, "12-13", config.WorkloadToBeRun[0].FfmpegPipeline.Resources.CpusetCpus)
+	assert.Equal(t, "1", config.WorkloadToBeRun[0].FfmpegPipeline.Resources.CpusetMems)
+	assert.Equal(t, "10-11", config.WorkloadToBeRun[1].FfmpegPipeline.Resources.CpusetCpus)
+	assert.Equal(t, "", config.WorkloadToBeRun[1].FfmpegPipeline.Resources.CpusetMems)
+
+	// the allocation passes the validation of the resources against the host
+	assert.NoError(t, checkHostResources(config, root))
+}
@@ -0,0 +1,197 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/utils"
+
+	"github.com/go-logr/logr"
+)
+
+type PlanAction string
+
+const (
+	PlanActionCreate   PlanAction = "create"
+	PlanActionRecreate PlanAction = "recreate"
+	PlanActionSkip     PlanAction = "skip"
+)
+
+// ContainerPlan describes what CreateAndRunContainers would do with a single container.
+type ContainerPlan struct {
+	Name         string        `json:"name"`
+	Type         string        `json:"type"`
+	Image        string        `json:"image"`
+	PullImage    bool          `json:"pullImage"`
+	CurrentState string        `json:"currentState,omitempty"`
+	Action       PlanAction    `json:"action"`
+	Reason       string        `json:"reason"`
+	Changes      []FieldDiff   `json:"changes,omitempty"`
+	Spec         ContainerSpec `json:"spec"`
+	// NmosConfigPath and NmosConfig hold the NMOS json file of an NMOS client as it would be written. Its string values
+	// keep their references to environment variables and secret files, so printing the plan does not disclose secrets.
+	NmosConfigPath string          `json:"nmosConfigPath,omitempty"`
+	NmosConfig     json.RawMessage `json:"nmosConfig,omitempty"`
+	Warnings       []string        `json:"warnings,omitempty"`
+}
+
+// Plan lists the containers declared in the launcher configuration in the order they are started.
+type Plan struct {
+	Containers []ContainerPlan `json:"containers"`
+}
+
+// PlanContainers resolves the launcher configuration against the current Docker state without changing
+// anything: no image is pulled, no container is created or removed and the NMOS json files are not rewritten.
+func PlanContainers(ctx context.Context, cli ContainerController, log logr.Logger, config *parser.Configuration) (Plan, error) {
+	plan := Plan{Containers: []ContainerPlan{}}
+	for _, instance := range config.WorkloadToBeRun {
+		if IsEmptyStruct(instance.FfmpegPipeline) || IsEmptyStruct(instance.NmosClient) {
+			return plan, fmt.Errorf("no information about BCS pipeline provided. Either FfmpegPipeline or NmosClient is empty for instance Ffmpeg: %s; Nmos: %s", instance.FfmpegPipeline.Name, instance.NmosClient.Name)
+		}
+	}
+
+	for _, containerInfo := range declaredContainers(config) {
+		containerPlan, err := planContainer(ctx, cli, log, &containerInfo, config)
+		if err != nil {
+			return plan, fmt.Errorf("failed to plan container %s: %w", containerInfo.ContainerName, err)
+		}
+		plan.Containers = append(plan.Containers, containerPlan)
+	}
+	return plan, nil
+}
+
+func planContainer(ctx context.Context, cli ContainerController, log logr.Logger, containerInfo *general.Containers, config *parser.Configuration) (ContainerPlan, error) {
+	containerPlan := ContainerPlan{
+		Name:  containerInfo.ContainerName,
+		Type:  containerInfo.Type.String(),
+		Image: containerInfo.Image,
+	}
+
+	spec, err := newContainerSpec(utils.PreviewContainerConfig(containerInfo, config, log))
+	if err != nil {
+		return containerPlan, err
+	}
+	containerPlan.Spec = spec
+
+	if containerInfo.Type == general.BcsPipelineNmosClient {
+		workload := config.WorkloadToBeRun[containerInfo.Id]
+		containerPlan.NmosConfigPath = utils.NmosRenderedFilePath(config, containerInfo.Id)
+		nmosConfig, err := utils.PreviewNmosJsonFile(utils.NmosJsonFilePath(config, containerInfo.Id), workload.FfmpegPipeline.Network.IP, strconv.Itoa(workload.FfmpegPipeline.GRPCPort))
+		if err != nil {
+			containerPlan.Warnings = append(containerPlan.Warnings, fmt.Sprintf("NMOS json file cannot be rendered: %v", err))
+		} else {
+			containerPlan.NmosConfig = nmosConfig
+		}
+	}
+
+	err, pulled := isImagePulled(ctx, cli, containerInfo.Image)
+	if err != nil {
+		return containerPlan, err
+	}
+	containerPlan.PullImage = !pulled
+
+	current, err := findContainer(ctx, cli, containerInfo.ContainerName)
+	if err != nil {
+		return containerPlan, err
+	}
+	if current == nil {
+		containerPlan.Action = PlanActionCreate
+		containerPlan.Reason = "container does not exist"
+		return containerPlan, nil
+	}
+	containerPlan.CurrentState = strings.ToLower(current.State)
+
+	switch containerPlan.CurrentState {
+	case "running":
+		currentHash, labeled := current.Labels[ConfigHashLabel]
+		switch {
+		case !labeled:
+			containerPlan.Action = PlanActionSkip
+			containerPlan.Reason = "container is running and has no configuration hash label"
+		case currentHash == spec.Config.Labels[ConfigHashLabel]:
+			containerPlan.Action = PlanActionSkip
+			containerPlan.Reason = "container is running with unchanged configuration"
+		default:
+			info, err := cli.ContainerInspect(ctx, containerInfo.ContainerName)
+			if err != nil {
+				return containerPlan, err
+			}
+			containerPlan.Action = PlanActionRecreate
+			containerPlan.Reason = "container is running with changed configuration"
+			containerPlan.Changes = DiffContainerSpec(inspectedContainerSpec(info), spec)
+		}
+	case "exited":
+		containerPlan.Action = PlanActionRecreate
+		containerPlan.Reason = "container exists with status exited"
+	default:
+		containerPlan.Action = PlanActionCreate
+		containerPlan.Reason = fmt.Sprintf("container exists with status %s", containerPlan.CurrentState)
+		containerPlan.Warnings = append(containerPlan.Warnings, "a container with this name already exists and is not removed, creation may fail")
+	}
+	return containerPlan, nil
+}
+
+// Count returns the number of containers planned with the given action.
+func (p Plan) Count(action PlanAction) int {
+	count := 0
+	for _, c := range p.Containers {
+		if c.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p Plan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// WriteText writes the plan in a human-readable form.
+func (p Plan) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, c := range p.Containers {
+		fmt.Fprintf(&b, "%s %s (%s)\n", strings.ToUpper(string(c.Action)), c.Name, c.Type)
+		fmt.Fprintf(&b, "  reason: %s\n", c.Reason)
+		image := c.Image
+		if c.PullImage {
+			image += " (will be pulled)"
+		}
+		fmt.Fprintf(&b, "  image: %s\n", image)
+		for _, warning := range c.Warnings {
+			fmt.Fprintf(&b, "  warning: %s\n", warning)
+		}
+		if len(c.Changes) > 0 {
+			fmt.Fprintf(&b, "  changes:\n")
+			for _, change := range c.Changes {
+				fmt.Fprintf(&b, "    %s\n", change)
+			}
+		}
+		spec, err := json.MarshalIndent(c.Spec, "    ", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "  spec:\n    %s\n", spec)
+		if c.NmosConfig != nil {
+			fmt.Fprintf(&b, "  nmos config (%s):\n    %s\n", c.NmosConfigPath, strings.ReplaceAll(string(c.NmosConfig), "\n", "\n    "))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Plan: %d to create, %d to recreate, %d unchanged.\n", p.Count(PlanActionCreate), p.Count(PlanActionRecreate), p.Count(PlanActionSkip))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
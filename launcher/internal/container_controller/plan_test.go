@@ -0,0 +1,132 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/utils"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func planTestConfig(nmosDir string) *parser.Configuration {
+	return &parser.Configuration{
+		RunOnce: parser.RunOnce{
+			MediaProxyAgent: workloads.MediaProxyAgentConfig{ImageAndTag: "agent-image:latest", RestPort: "8100", GRPCPort: "50051"},
+			MediaProxyMcm:   workloads.MediaProxyMcmInstances{{ImageAndTag: "mcm-image:latest", InterfaceName: "eth0"}},
+		},
+		WorkloadToBeRun: []workloads.WorkloadConfig{
+			{
+				FfmpegPipeline: workloads.FfmpegPipelineConfig{
+					Name:        "ffmpeg-pipeline",
+					ImageAndTag: "ffmpeg-image:latest",
+					GRPCPort:    50055,
+					Network:     workloads.NetworkConfig{Enable: true, Name: "bcs-net", IP: "10.0.0.2"},
+				},
+				NmosClient: workloads.NmosClientConfig{
+					Name:               "nmos-client",
+					ImageAndTag:        "nmos-image:latest",
+					NmosConfigPath:     nmosDir,
+					NmosConfigFileName: "nmos.json",
+					NmosPort:           5004,
+				},
+			},
+		},
+	}
+}
+
+func TestPlanContainers(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+	nmosDir := t.TempDir()
+	nmosJson := `{"ffmpeg_grpc_server_address": "old-address", "ffmpeg_grpc_server_port": "50051", "label": "${file:label.secret}"}`
+	assert.NoError(t, os.WriteFile(filepath.Join(nmosDir, "nmos.json"), []byte(nmosJson), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(nmosDir, "label.secret"), []byte("s3cr3t"), 0600))
+
+	config := planTestConfig(nmosDir)
+	agentSpec, err := newContainerSpec(utils.PreviewContainerConfig(&general.Containers{Type: general.MediaProxyAgent}, config, log))
+	assert.NoError(t, err)
+
+	mockController := new(MockContainerController)
+	mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{{RepoTags: []string{"agent-image:latest", "mcm-image:latest", "nmos-image:latest"}}}, nil)
+	mockController.On("ContainerList", ctx, container.ListOptions{All: true}).Return([]types.Container{
+		{Names: []string{"/" + MediaProxyAgentContainerName}, State: "running", Labels: map[string]string{ConfigHashLabel: agentSpec.Config.Labels[ConfigHashLabel]}},
+		{Names: []string{"/" + MediaProxyContainerName}, State: "exited"},
+		{Names: []string{"/ffmpeg-pipeline"}, State: "running", Labels: map[string]string{ConfigHashLabel: "outdated"}},
+	}, nil)
+	mockController.On("ContainerInspect", ctx, "ffmpeg-pipeline").Return(container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{HostConfig: &container.HostConfig{}},
+		Config:            &container.Config{Image: "ffmpeg-image:old"},
+	}, nil)
+
+	plan, err := PlanContainers(ctx, mockController, log, config)
+	mockController.AssertExpectations(t)
+	assert.NoError(t, err)
+
+	actions := map[string]PlanAction{}
+	for _, c := range plan.Containers {
+		actions[c.Name] = c.Action
+	}
+	assert.Equal(t, map[string]PlanAction{
+		MediaProxyAgentContainerName: PlanActionSkip,
+		MediaProxyContainerName:      PlanActionRecreate,
+		"ffmpeg-pipeline":            PlanActionRecreate,
+		"nmos-client":                PlanActionCreate,
+	}, actions)
+
+	pipeline := plan.Containers[2]
+	assert.True(t, pipeline.PullImage)
+	assert.Contains(t, pipeline.Changes, FieldDiff{Field: "Config.Image", Current: "ffmpeg-image:old", Desired: "ffmpeg-image:latest"})
+
+	nmos := plan.Containers[3]
+	assert.False(t, nmos.PullImage)
+	assert.Contains(t, string(nmos.NmosConfig), `"ffmpeg_grpc_server_address": "10.0.0.2"`)
+	assert.Contains(t, string(nmos.NmosConfig), `"ffmpeg_grpc_server_port": "50055"`)
+	assert.Contains(t, string(nmos.NmosConfig), `"label": "${file:label.secret}"`)
+	assert.NotContains(t, string(nmos.NmosConfig), "s3cr3t", "the plan does not disclose secrets")
+
+	onDisk, err := os.ReadFile(filepath.Join(nmosDir, "nmos.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, nmosJson, string(onDisk), "planning must not rewrite the NMOS json file")
+	mockController.AssertNotCalled(t, "ImagePull", mock.Anything, mock.Anything, mock.Anything)
+	mockController.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockController.AssertNotCalled(t, "ContainerRemove", mock.Anything, mock.Anything, mock.Anything)
+
+	var text bytes.Buffer
+	assert.NoError(t, plan.WriteText(&text))
+	assert.Contains(t, text.String(), "RECREATE ffmpeg-pipeline (BcsPipelineFfmpeg)")
+	assert.Contains(t, text.String(), "Config.Image: ffmpeg-image:old -> ffmpeg-image:latest")
+	assert.Contains(t, text.String(), "Plan: 1 to create, 2 to recreate, 1 unchanged.")
+
+	var encoded bytes.Buffer
+	assert.NoError(t, plan.WriteJSON(&encoded))
+	var decoded Plan
+	assert.NoError(t, json.Unmarshal(encoded.Bytes(), &decoded))
+	assert.Equal(t, plan.Containers[3].NmosConfigPath, decoded.Containers[3].NmosConfigPath)
+	assert.Equal(t, PlanActionRecreate, decoded.Containers[2].Action)
+}
+
+func TestPlanContainersRejectsIncompleteWorkload(t *testing.T) {
+	config := planTestConfig(t.TempDir())
+	config.WorkloadToBeRun[0].NmosClient = workloads.NmosClientConfig{}
+
+	_, err := PlanContainers(context.Background(), new(MockContainerController), logr.Discard(), config)
+	assert.Error(t, err)
+}
@@ -0,0 +1,108 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+// Runtime is the container engine managing the containers in docker mode.
+type Runtime string
+
+const (
+	RuntimeDocker Runtime = "docker"
+	RuntimePodman Runtime = "podman"
+)
+
+// DefaultPodmanHost is the socket of the rootful Podman API service started by the podman.socket systemd unit.
+const DefaultPodmanHost = "unix:///run/podman/podman.sock"
+
+// PodmanContainerController manages containers through the Docker-compatible REST API of Podman.
+// It differs from DockerContainerController only where Podman does not behave like Docker.
+type PodmanContainerController struct {
+	DockerContainerController
+}
+
+// ImageList lists the images like Docker does. Podman reports fully qualified tags, e.g. docker.io/library/ubuntu:22.04
+// for pulled and localhost/tiber-broadcast-suite:latest for locally built images, so the short tags used
+// in the launcher configuration are added to every image.
+func (p *PodmanContainerController) ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error) {
+	images, err := p.cli.ImageList(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range images {
+		images[i].RepoTags = withShortTags(images[i].RepoTags)
+	}
+	return images, nil
+}
+
+// withShortTags appends the Docker short form of every fully qualified tag.
+func withShortTags(tags []string) []string {
+	result := slices.Clone(tags)
+	for _, tag := range tags {
+		short := tag
+		for _, prefix := range []string{"localhost/", "docker.io/library/", "docker.io/"} {
+			if strings.HasPrefix(tag, prefix) {
+				short = strings.TrimPrefix(tag, prefix)
+				break
+			}
+		}
+		if !slices.Contains(result, short) {
+			result = append(result, short)
+		}
+	}
+	return result
+}
+
+// NewPodmanContainerController connects to the Podman API service listening on host.
+// An empty host selects CONTAINER_HOST, like the podman remote client does, or DefaultPodmanHost.
+func NewPodmanContainerController(host string) (*PodmanContainerController, error) {
+	if host == "" {
+		host = os.Getenv("CONTAINER_HOST")
+	}
+	if host == "" {
+		host = DefaultPodmanHost
+	}
+	cli, err := client.NewClientWithOpts(client.WithHost(host), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	return &PodmanContainerController{DockerContainerController{cli: cli}}, nil
+}
+
+// NewContainerController returns the controller of the container runtime listening on host.
+// An empty host selects the default of the runtime: DOCKER_HOST or the Docker socket for Docker,
+// CONTAINER_HOST or the rootful Podman socket for Podman.
+func NewContainerController(runtime Runtime, host string) (ContainerController, error) {
+	switch runtime {
+	case RuntimeDocker:
+		opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+		if host != "" {
+			opts = append(opts, client.WithHost(host))
+		}
+		cli, err := client.NewClientWithOpts(opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &DockerContainerController{cli: cli}, nil
+	case RuntimePodman:
+		controller, err := NewPodmanContainerController(host)
+		if err != nil {
+			return nil, err
+		}
+		return controller, nil
+	}
+	return nil, fmt.Errorf("unknown container runtime %q", runtime)
+}
@@ -0,0 +1,64 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/go-logr/logr"
+)
+
+// PruneContainers stops and removes the containers owned by the launcher instance (label bcs.intel.launcher.id
+// equal to config.LauncherID) that are no longer declared in the launcher configuration, e.g. pipelines removed
+// from workloadToBeRun. Containers without the label, or owned by another launcher instance, are never touched.
+func PruneContainers(ctx context.Context, cli ContainerController, log logr.Logger, config *parser.Configuration, stopTimeout time.Duration) (TeardownReport, error) {
+	report := TeardownReport{}
+	if config.LauncherID == "" {
+		return report, errors.New("launcher ID is not set, owned containers cannot be identified")
+	}
+
+	owned, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", general.LabelLauncherID+"="+config.LauncherID)),
+	})
+	if err != nil {
+		return report, fmt.Errorf("list containers owned by launcher %s: %w", config.LauncherID, err)
+	}
+
+	declared := map[string]bool{}
+	for _, containerInfo := range declaredContainers(config) {
+		declared[containerInfo.ContainerName] = true
+	}
+
+	var errs []error
+	for _, c := range owned {
+		if len(c.Names) == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(c.Names[0], "/")
+		if declared[name] {
+			continue
+		}
+		log.Info("Pruning container no longer declared in the configuration", "container", name,
+			"role", c.Labels[general.LabelRole], "configFile", c.Labels[general.LabelConfigFile])
+		if err := stopAndRemoveContainer(ctx, cli, log, name, stopTimeout, &report); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	log.Info("Prune finished", "removed", strings.Join(report.Removed, ","))
+	return report, errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPruneContainers(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+
+	t.Run("Removes owned containers no longer declared", func(t *testing.T) {
+		config := teardownTestConfig()
+		config.LauncherID = "studio-a"
+		timeoutSeconds := 5
+
+		mockController := new(MockContainerController)
+		mockController.On("ContainerList", ctx, container.ListOptions{
+			All:     true,
+			Filters: filters.NewArgs(filters.Arg("label", general.LabelLauncherID+"=studio-a")),
+		}).Return([]types.Container{
+			{Names: []string{"/ffmpeg-pipeline"}, Labels: map[string]string{general.LabelLauncherID: "studio-a"}},
+			{Names: []string{"/old-ffmpeg-pipeline"}, Labels: map[string]string{general.LabelLauncherID: "studio-a"}},
+			{Names: []string{"/old-nmos-client"}, Labels: map[string]string{general.LabelLauncherID: "studio-a"}},
+		}, nil)
+		mockController.On("ContainerInspect", ctx, "old-ffmpeg-pipeline").Return(runningState(true), nil)
+		mockController.On("ContainerInspect", ctx, "old-nmos-client").Return(runningState(false), nil)
+		mockController.On("ContainerStop", ctx, "old-ffmpeg-pipeline", container.StopOptions{Timeout: &timeoutSeconds}).Return(nil)
+		mockController.On("ContainerRemove", ctx, "old-ffmpeg-pipeline", container.RemoveOptions{Force: true}).Return(nil)
+		mockController.On("ContainerRemove", ctx, "old-nmos-client", container.RemoveOptions{Force: true}).Return(nil)
+
+		report, err := PruneContainers(ctx, mockController, log, config, 5*time.Second)
+		mockController.AssertExpectations(t)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"old-ffmpeg-pipeline", "old-nmos-client"}, report.Removed)
+		assert.Equal(t, []string{"old-ffmpeg-pipeline"}, report.Stopped)
+		mockController.AssertNotCalled(t, "ContainerRemove", ctx, "ffmpeg-pipeline", mock.Anything)
+	})
+
+	t.Run("Requires a launcher ID", func(t *testing.T) {
+		mockController := new(MockContainerController)
+
+		_, err := PruneContainers(ctx, mockController, log, teardownTestConfig(), time.Second)
+
+		assert.Error(t, err)
+		mockController.AssertNotCalled(t, "ContainerList", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,113 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/go-logr/logr"
+)
+
+// pullProgressStep is the share of a layer download or extraction, in percent, between two progress logs.
+const pullProgressStep = 25
+
+type layerProgress struct {
+	status string
+	step   int64
+}
+
+// pullProgress turns the JSON message stream of an image pull into log entries: one for every status change
+// of a layer and one for every pullProgressStep percent of its download and extraction.
+type pullProgress struct {
+	log    logr.Logger
+	image  string
+	layers map[string]*layerProgress
+}
+
+func (p *pullProgress) handle(message jsonmessage.JSONMessage) {
+	// "Pulling from <repository>" carries the tag as its ID, it is not a layer
+	if message.ID == "" || message.Status == "" || strings.HasPrefix(message.Status, "Pulling from") {
+		if message.Status != "" {
+			p.log.Info("Pulling image", "image", p.image, "status", message.Status)
+		}
+		return
+	}
+	layer, ok := p.layers[message.ID]
+	if !ok {
+		layer = &layerProgress{}
+		p.layers[message.ID] = layer
+	}
+	var current, total int64
+	if message.Progress != nil {
+		current, total = message.Progress.Current, message.Progress.Total
+	}
+	if message.Status != layer.status {
+		layer.status, layer.step = message.Status, 0
+		p.log.Info("Image layer progress", "image", p.image, "layer", message.ID, "status", message.Status, "current", current, "total", total)
+		return
+	}
+	if total <= 0 {
+		return
+	}
+	if step := current * 100 / total / pullProgressStep; step > layer.step {
+		layer.step = step
+		p.log.Info("Image layer progress", "image", p.image, "layer", message.ID, "status", message.Status, "current", current, "total", total)
+	}
+}
+
+// pullImage pulls an image with the registry credentials of the launcher configuration or of the Docker config.json
+// file and logs its progress. An error reported in the message stream, e.g. a missing manifest or a failed
+// layer download, fails the pull.
+func pullImage(ctx context.Context, cli ContainerController, log logr.Logger, imageName string, config *parser.Configuration) error {
+	auth, err := registryAuth(ctx, log, imageName, config)
+	if err != nil {
+		log.Error(err, "Error resolving registry credentials", "image", imageName)
+		return err
+	}
+	reader, err := cli.ImagePull(ctx, imageName, image.PullOptions{RegistryAuth: auth})
+	if err != nil {
+		log.Error(err, "Error pulling image")
+		return err
+	}
+	defer reader.Close()
+
+	progress := &pullProgress{log: log, image: imageName, layers: map[string]*layerProgress{}}
+	decoder := json.NewDecoder(reader)
+	for {
+		var message jsonmessage.JSONMessage
+		err := decoder.Decode(&message)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Error(err, "Error reading output")
+			return err
+		}
+		if message.Error != nil {
+			err := fmt.Errorf("failed to pull image %s: %w", imageName, message.Error)
+			log.Error(err, "Error pulling image")
+			return err
+		}
+		if message.ErrorMessage != "" {
+			err := fmt.Errorf("failed to pull image %s: %s", imageName, message.ErrorMessage)
+			log.Error(err, "Error pulling image")
+			return err
+		}
+		progress.handle(message)
+	}
+	log.Info("Image pulled successfully", "image", imageName)
+	return nil
+}
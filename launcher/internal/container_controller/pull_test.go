@@ -0,0 +1,87 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingLogger returns a logger appending every log entry to lines.
+func recordingLogger(lines *[]string) logr.Logger {
+	return funcr.New(func(prefix, args string) { *lines = append(*lines, args) }, funcr.Options{})
+}
+
+func pullStream(messages ...string) io.ReadCloser {
+	return io.NopCloser(strings.NewReader(strings.Join(messages, "\n")))
+}
+
+func TestPullImage(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	t.Run("Logs status changes and progress of every layer", func(t *testing.T) {
+		var lines []string
+		mockController := new(MockContainerController)
+		mockController.On("ImagePull", ctx, "ubuntu:22.04", image.PullOptions{}).Return(pullStream(
+			`{"status":"Pulling from library/ubuntu","id":"22.04"}`,
+			`{"status":"Pulling fs layer","progressDetail":{},"id":"a1"}`,
+			`{"status":"Downloading","progressDetail":{"current":10,"total":100},"id":"a1"}`,
+			`{"status":"Downloading","progressDetail":{"current":20,"total":100},"id":"a1"}`,
+			`{"status":"Downloading","progressDetail":{"current":55,"total":100},"id":"a1"}`,
+			`{"status":"Download complete","progressDetail":{},"id":"a1"}`,
+			`{"status":"Digest: sha256:abc"}`,
+		), nil)
+
+		err := pullImage(ctx, mockController, recordingLogger(&lines), "ubuntu:22.04", nil)
+		mockController.AssertExpectations(t)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			`"level"=0 "msg"="Pulling image" "image"="ubuntu:22.04" "status"="Pulling from library/ubuntu"`,
+			`"level"=0 "msg"="Image layer progress" "image"="ubuntu:22.04" "layer"="a1" "status"="Pulling fs layer" "current"=0 "total"=0`,
+			`"level"=0 "msg"="Image layer progress" "image"="ubuntu:22.04" "layer"="a1" "status"="Downloading" "current"=10 "total"=100`,
+			`"level"=0 "msg"="Image layer progress" "image"="ubuntu:22.04" "layer"="a1" "status"="Downloading" "current"=55 "total"=100`,
+			`"level"=0 "msg"="Image layer progress" "image"="ubuntu:22.04" "layer"="a1" "status"="Download complete" "current"=0 "total"=0`,
+			`"level"=0 "msg"="Pulling image" "image"="ubuntu:22.04" "status"="Digest: sha256:abc"`,
+			`"level"=0 "msg"="Image pulled successfully" "image"="ubuntu:22.04"`,
+		}, lines)
+	})
+
+	t.Run("Fails on an error in the message stream", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("ImagePull", ctx, "registry.example.com/bcs/ffmpeg:1.0", image.PullOptions{}).Return(pullStream(
+			`{"status":"Pulling from bcs/ffmpeg","id":"1.0"}`,
+			`{"errorDetail":{"message":"manifest for registry.example.com/bcs/ffmpeg:1.0 not found"},"error":"manifest for registry.example.com/bcs/ffmpeg:1.0 not found"}`,
+		), nil)
+
+		err := pullImage(ctx, mockController, logr.Discard(), "registry.example.com/bcs/ffmpeg:1.0", nil)
+
+		assert.EqualError(t, err, "failed to pull image registry.example.com/bcs/ffmpeg:1.0: manifest for registry.example.com/bcs/ffmpeg:1.0 not found")
+	})
+
+	t.Run("Passes the registry credentials of the launcher configuration", func(t *testing.T) {
+		config := &parser.Configuration{Registries: []parser.RegistryCredentials{{Server: "registry.example.com", Username: "bcs", Password: "secret"}}}
+		auth, _ := registry.EncodeAuthConfig(registry.AuthConfig{Username: "bcs", Password: "secret", ServerAddress: "registry.example.com"})
+		mockController := new(MockContainerController)
+		mockController.On("ImagePull", ctx, "registry.example.com/bcs/ffmpeg:1.0", image.PullOptions{RegistryAuth: auth}).Return(pullStream(), nil)
+
+		err := pullImage(ctx, mockController, logr.Discard(), "registry.example.com/bcs/ffmpeg:1.0", config)
+		mockController.AssertExpectations(t)
+
+		assert.NoError(t, err)
+	})
+}
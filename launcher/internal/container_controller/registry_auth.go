@@ -0,0 +1,208 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/go-logr/logr"
+)
+
+// dockerHubServer is the key of Docker Hub in the Docker config.json file and for credential helpers.
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// dockerConfigFile is the part of the Docker config.json file holding registry credentials.
+type dockerConfigFile struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// registryHost returns the registry an image is pulled from, e.g. docker.io for ubuntu:22.04.
+func registryHost(imageName string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %s: %w", imageName, err)
+	}
+	return reference.Domain(named), nil
+}
+
+// normalizeRegistry strips the scheme and path of a registry address, so https://index.docker.io/v1/
+// and registry.example.com:5000 compare with the host of an image reference.
+func normalizeRegistry(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	server, _, _ = strings.Cut(server, "/")
+	if server == "index.docker.io" || server == "registry-1.docker.io" {
+		return "docker.io"
+	}
+	return server
+}
+
+// registryAuth returns the encoded credentials for pulling imageName, or an empty string when there are none.
+// Credentials in the launcher configuration take precedence over the ones in the Docker config.json file.
+func registryAuth(ctx context.Context, log logr.Logger, imageName string, config *parser.Configuration) (string, error) {
+	host, err := registryHost(imageName)
+	if err != nil {
+		return "", err
+	}
+	var authConfig *registry.AuthConfig
+	if config != nil {
+		for _, credentials := range config.Registries {
+			if normalizeRegistry(credentials.Server) == host {
+				authConfig = &registry.AuthConfig{
+					Username:      credentials.Username,
+					Password:      credentials.Password,
+					IdentityToken: credentials.IdentityToken,
+					ServerAddress: credentials.Server,
+				}
+				break
+			}
+		}
+	}
+	if authConfig == nil {
+		dockerConfig := ""
+		if config != nil {
+			dockerConfig = config.DockerConfig
+		}
+		authConfig, err = dockerConfigAuth(ctx, log, dockerConfigPath(dockerConfig), host)
+		if err != nil {
+			return "", err
+		}
+	}
+	if authConfig == nil {
+		return "", nil
+	}
+	return registry.EncodeAuthConfig(*authConfig)
+}
+
+// dockerConfigPath returns the path of the Docker config.json file the same way the docker CLI finds it.
+func dockerConfigPath(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// dockerConfigAuth looks the credentials of a registry up in the Docker config.json file: first in the credential
+// helper configured for the registry or in the credential store, then in the auths section.
+// A missing file means there are no credentials, and so does a credential helper that is not installed, as for the docker CLI.
+func dockerConfigAuth(ctx context.Context, log logr.Logger, path, host string) (*registry.AuthConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Docker config file: %w", err)
+	}
+	var dockerConfig dockerConfigFile
+	if err := json.Unmarshal(data, &dockerConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse Docker config file %s: %w", path, err)
+	}
+
+	server := host
+	if host == "docker.io" {
+		server = dockerHubServer
+	}
+	helper := dockerConfig.CredsStore
+	for key, keyHelper := range dockerConfig.CredHelpers {
+		if normalizeRegistry(key) == host {
+			server, helper = key, keyHelper
+		}
+	}
+	for key := range dockerConfig.Auths {
+		if normalizeRegistry(key) == host {
+			server = key
+		}
+	}
+
+	if helper != "" {
+		authConfig, err := credentialHelperAuth(ctx, helper, server)
+		if errors.Is(err, exec.ErrNotFound) {
+			log.Info("Credential helper of the Docker config file is not installed. Continuing without its credentials",
+				"helper", "docker-credential-"+helper, "registry", server, "config", path)
+		} else if err != nil || authConfig != nil {
+			return authConfig, err
+		}
+	}
+	entry, ok := dockerConfig.Auths[server]
+	if !ok {
+		return nil, nil
+	}
+	authConfig := &registry.AuthConfig{Username: entry.Username, Password: entry.Password, IdentityToken: entry.IdentityToken, ServerAddress: server}
+	if entry.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth of registry %s in Docker config file: %w", server, err)
+		}
+		username, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid auth of registry %s in Docker config file: expected username:password", server)
+		}
+		authConfig.Username, authConfig.Password = username, password
+	}
+	if authConfig.Username == "" && authConfig.Password == "" && authConfig.IdentityToken == "" {
+		return nil, nil
+	}
+	return authConfig, nil
+}
+
+// credentialHelperAuth asks the docker-credential-<helper> program for the credentials of server.
+// It returns nil when the helper does not know the server.
+func credentialHelperAuth(ctx context.Context, helper, server string) (*registry.AuthConfig, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stdout.String() + stderr.String())
+		if strings.Contains(message, "credentials not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("credential helper docker-credential-%s failed for registry %s: %w: %s", helper, server, err, message)
+	}
+	var credentials struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &credentials); err != nil {
+		return nil, fmt.Errorf("invalid output of credential helper docker-credential-%s: %w", helper, err)
+	}
+	authConfig := &registry.AuthConfig{Username: credentials.Username, Password: credentials.Secret, ServerAddress: server}
+	// helpers return identity tokens with this placeholder as the username
+	if credentials.Username == "<token>" {
+		authConfig = &registry.AuthConfig{IdentityToken: credentials.Secret, ServerAddress: server}
+	}
+	return authConfig, nil
+}
@@ -0,0 +1,116 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeDockerConfig writes a Docker config.json file into a new directory selected with DOCKER_CONFIG.
+func writeDockerConfig(t *testing.T, content string) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(content), 0600))
+	t.Setenv("DOCKER_CONFIG", dir)
+}
+
+// decodedAuth decodes the credentials returned by registryAuth.
+func decodedAuth(t *testing.T, auth string) registry.AuthConfig {
+	authConfig, err := registry.DecodeAuthConfig(auth)
+	assert.NoError(t, err)
+	return *authConfig
+}
+
+func TestRegistryAuth(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Returns no credentials without a Docker config file", func(t *testing.T) {
+		t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+		auth, err := registryAuth(ctx, logr.Discard(), "ubuntu:22.04", &parser.Configuration{})
+		assert.NoError(t, err)
+		assert.Empty(t, auth)
+	})
+
+	t.Run("Reads the auths section of the Docker config file", func(t *testing.T) {
+		// YmNzOnNlY3JldA== is bcs:secret
+		writeDockerConfig(t, `{"auths": {"https://index.docker.io/v1/": {"auth": "YmNzOnNlY3JldA=="}, "registry.example.com:5000": {"identitytoken": "token"}}}`)
+
+		auth, err := registryAuth(ctx, logr.Discard(), "ubuntu:22.04", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, registry.AuthConfig{Username: "bcs", Password: "secret", ServerAddress: dockerHubServer}, decodedAuth(t, auth))
+
+		auth, err = registryAuth(ctx, logr.Discard(), "registry.example.com:5000/bcs/ffmpeg:1.0", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, registry.AuthConfig{IdentityToken: "token", ServerAddress: "registry.example.com:5000"}, decodedAuth(t, auth))
+
+		auth, err = registryAuth(ctx, logr.Discard(), "quay.io/bcs/nmos:1.0", nil)
+		assert.NoError(t, err)
+		assert.Empty(t, auth)
+	})
+
+	t.Run("Prefers the credentials of the launcher configuration", func(t *testing.T) {
+		writeDockerConfig(t, `{"auths": {"registry.example.com": {"auth": "YmNzOnNlY3JldA=="}}}`)
+		config := &parser.Configuration{Registries: []parser.RegistryCredentials{{Server: "https://registry.example.com", Username: "launcher", Password: "pass"}}}
+
+		auth, err := registryAuth(ctx, logr.Discard(), "registry.example.com/bcs/ffmpeg:1.0", config)
+		assert.NoError(t, err)
+		assert.Equal(t, registry.AuthConfig{Username: "launcher", Password: "pass", ServerAddress: "https://registry.example.com"}, decodedAuth(t, auth))
+	})
+
+	t.Run("Asks the credential helper of the registry", func(t *testing.T) {
+		helperDir := t.TempDir()
+		helper := `#!/bin/sh
+read server
+if [ "$server" = "registry.example.com" ]; then
+  echo '{"ServerURL":"registry.example.com","Username":"<token>","Secret":"helper-token"}'
+else
+  echo "credentials not found in native keychain"
+  exit 1
+fi
+`
+		assert.NoError(t, os.WriteFile(filepath.Join(helperDir, "docker-credential-test"), []byte(helper), 0755))
+		t.Setenv("PATH", helperDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+		writeDockerConfig(t, `{"credsStore": "test", "auths": {"https://index.docker.io/v1/": {"auth": "YmNzOnNlY3JldA=="}}}`)
+
+		auth, err := registryAuth(ctx, logr.Discard(), "registry.example.com/bcs/ffmpeg:1.0", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, registry.AuthConfig{IdentityToken: "helper-token", ServerAddress: "registry.example.com"}, decodedAuth(t, auth))
+
+		// the helper does not know Docker Hub, the auths section is used instead
+		auth, err = registryAuth(ctx, logr.Discard(), "ubuntu:22.04", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "bcs", decodedAuth(t, auth).Username)
+	})
+
+	t.Run("Continues without a credential helper that is not installed", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		writeDockerConfig(t, `{"credsStore": "desktop", "credHelpers": {"gcr.io": "gcloud"}, "auths": {"https://index.docker.io/v1/": {"auth": "YmNzOnNlY3JldA=="}}}`)
+
+		auth, err := registryAuth(ctx, logr.Discard(), "gcr.io/bcs/ffmpeg:1.0", nil)
+		assert.NoError(t, err)
+		assert.Empty(t, auth, "public images are pulled anonymously")
+
+		auth, err = registryAuth(ctx, logr.Discard(), "ubuntu:22.04", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "bcs", decodedAuth(t, auth).Username, "the auths section is used instead")
+	})
+
+	t.Run("Fails on a broken Docker config file", func(t *testing.T) {
+		writeDockerConfig(t, `{"auths": `)
+
+		_, err := registryAuth(ctx, logr.Discard(), "ubuntu:22.04", nil)
+		assert.ErrorContains(t, err, "failed to parse Docker config file")
+	})
+}
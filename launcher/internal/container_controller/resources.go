@@ -0,0 +1,260 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/utils"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// hugepageSizes maps the hugepages resource names to the sysfs directory of the page size.
+var hugepageSizes = map[string]string{
+	"hugepages-2Mi": "hugepages-2048kB",
+	"hugepages-1Gi": "hugepages-1048576kB",
+}
+
+// HostCapacity is the hardware of the host the resources of the containers are validated against.
+type HostCapacity struct {
+	CPUs      []int            // online CPUs
+	NUMANodes []int            // online NUMA nodes
+	Memory    int64            // total memory in bytes
+	Hugepages map[string]int64 // bytes of hugepages reserved on the host per resource name, e.g. hugepages-2Mi
+}
+
+// parseCPUList parses a kernel CPU or node list, e.g. 0-3,8,10-11.
+func parseCPUList(list string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid list %q", list)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil || to < from {
+				return nil, fmt.Errorf("invalid list %q", list)
+			}
+		}
+		for id := from; id <= to; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// ReadHostCapacity reads the capacity of the host from the proc and sys filesystems mounted below root, / for the host itself.
+func ReadHostCapacity(root string) (HostCapacity, error) {
+	capacity := HostCapacity{Hugepages: map[string]int64{}}
+
+	online, err := os.ReadFile(filepath.Join(root, "sys/devices/system/cpu/online"))
+	if err != nil {
+		return capacity, fmt.Errorf("failed to read online CPUs: %w", err)
+	}
+	if capacity.CPUs, err = parseCPUList(string(online)); err != nil {
+		return capacity, fmt.Errorf("failed to read online CPUs: %w", err)
+	}
+
+	// hosts without NUMA support have a single node 0
+	capacity.NUMANodes = []int{0}
+	if nodes, err := os.ReadFile(filepath.Join(root, "sys/devices/system/node/online")); err == nil {
+		if capacity.NUMANodes, err = parseCPUList(string(nodes)); err != nil {
+			return capacity, fmt.Errorf("failed to read online NUMA nodes: %w", err)
+		}
+	}
+
+	meminfo, err := os.Open(filepath.Join(root, "proc/meminfo"))
+	if err != nil {
+		return capacity, fmt.Errorf("failed to read host memory: %w", err)
+	}
+	defer meminfo.Close()
+	scanner := bufio.NewScanner(meminfo)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kilobytes, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return capacity, fmt.Errorf("failed to read host memory: invalid MemTotal %q", fields[1])
+			}
+			capacity.Memory = kilobytes * 1024
+		}
+	}
+
+	for name, dir := range hugepageSizes {
+		pages, err := os.ReadFile(filepath.Join(root, "sys/kernel/mm/hugepages", dir, "nr_hugepages"))
+		if err != nil {
+			// the page size is not supported by the host
+			continue
+		}
+		count, err := strconv.ParseInt(strings.TrimSpace(string(pages)), 10, 64)
+		if err != nil {
+			return capacity, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		size := resource.MustParse(strings.TrimPrefix(name, "hugepages-"))
+		capacity.Hugepages[name] = count * size.Value()
+	}
+	return capacity, nil
+}
+
+// parseHugepages parses an amount of hugepages, which has to be a multiple of the page size.
+func parseHugepages(name, value string) (int64, error) {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil || quantity.Sign() < 0 {
+		return 0, fmt.Errorf("invalid resources %s %q", name, value)
+	}
+	size := resource.MustParse(strings.TrimPrefix(name, "hugepages-"))
+	if quantity.Value()%size.Value() != 0 {
+		return 0, fmt.Errorf("resources %s %q is not a multiple of the page size", name, value)
+	}
+	return quantity.Value(), nil
+}
+
+// exceeds tells whether a request is above its limit. Empty values never exceed.
+func exceeds(request, limit string) bool {
+	if request == "" || limit == "" {
+		return false
+	}
+	requested, err := resource.ParseQuantity(request)
+	if err != nil {
+		return false
+	}
+	limited, err := resource.ParseQuantity(limit)
+	return err == nil && requested.Cmp(limited) > 0
+}
+
+// hasResources tells whether any declared container has a resources block.
+func hasResources(config *parser.Configuration) bool {
+	for _, containerInfo := range declaredContainers(config) {
+		if !IsEmptyStruct(utils.WorkloadResources(&containerInfo, config)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateResources checks the resources blocks of the declared containers against the capacity of the host:
+// no container may be limited to more CPUs or memory than the host has or be pinned to CPUs or NUMA nodes that are
+// not online, and the hugepages of all containers together have to fit into the hugepages reserved on the host.
+func ValidateResources(config *parser.Configuration, host HostCapacity) error {
+	var errs []error
+	hugepages := map[string]int64{}
+	for _, containerInfo := range declaredContainers(config) {
+		declared := utils.WorkloadResources(&containerInfo, config)
+		if IsEmptyStruct(declared) {
+			continue
+		}
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Errorf("container %s: "+format, append([]interface{}{containerInfo.ContainerName}, args...)...))
+		}
+
+		resources, err := utils.ContainerResources(declared)
+		if err != nil {
+			fail("%w", err)
+			continue
+		}
+		if exceeds(declared.Requests.CPU, declared.Limits.CPU) {
+			fail("requests.cpu %s exceeds limits.cpu %s", declared.Requests.CPU, declared.Limits.CPU)
+		}
+		if resources.NanoCPUs > int64(len(host.CPUs))*1000000000 {
+			fail("limits.cpu %s exceeds the %d CPUs of the host", declared.Limits.CPU, len(host.CPUs))
+		}
+		if exceeds(declared.Requests.Memory, declared.Limits.Memory) {
+			fail("requests.memory %s exceeds limits.memory %s", declared.Requests.Memory, declared.Limits.Memory)
+		}
+		if resources.Memory > host.Memory {
+			fail("limits.memory %s exceeds the %s of memory of the host", declared.Limits.Memory, resource.NewQuantity(host.Memory, resource.BinarySI))
+		}
+		if resources.CpusetCpus != "" {
+			cpus, err := parseCPUList(resources.CpusetCpus)
+			if err != nil {
+				fail("invalid cpusetCpus: %w", err)
+			}
+			for _, cpu := range cpus {
+				if !slices.Contains(host.CPUs, cpu) {
+					fail("cpusetCpus %s contains CPU %d which is not online on the host", resources.CpusetCpus, cpu)
+					break
+				}
+			}
+			if err == nil && resources.NanoCPUs > int64(len(cpus))*1000000000 {
+				fail("limits.cpu %s exceeds the %d CPUs of cpusetCpus %s", declared.Limits.CPU, len(cpus), resources.CpusetCpus)
+			}
+		}
+		if resources.CpusetMems != "" {
+			nodes, err := parseCPUList(resources.CpusetMems)
+			if err != nil {
+				fail("invalid cpusetMems: %w", err)
+			}
+			for _, node := range nodes {
+				if !slices.Contains(host.NUMANodes, node) {
+					fail("cpusetMems %s contains NUMA node %d which is not online on the host", resources.CpusetMems, node)
+					break
+				}
+			}
+		}
+
+		for _, pages := range []struct{ name, request, limit string }{
+			{"hugepages-2Mi", declared.Requests.Hugepages2Mi, declared.Limits.Hugepages2Mi},
+			{"hugepages-1Gi", declared.Requests.Hugepages1Gi, declared.Limits.Hugepages1Gi},
+		} {
+			// the limit is what the container may take, the request when no limit is set
+			value := pages.limit
+			if value == "" {
+				value = pages.request
+			}
+			if value == "" {
+				continue
+			}
+			quantity, err := parseHugepages(pages.name, value)
+			if err != nil {
+				fail("%w", err)
+				continue
+			}
+			if exceeds(pages.request, pages.limit) {
+				fail("requests.%s %s exceeds limits.%s %s", pages.name, pages.request, pages.name, pages.limit)
+			}
+			hugepages[pages.name] += quantity
+		}
+	}
+
+	for _, name := range []string{"hugepages-2Mi", "hugepages-1Gi"} {
+		if hugepages[name] > host.Hugepages[name] {
+			errs = append(errs, fmt.Errorf("the containers need %s of %s, but the host reserves %s",
+				resource.NewQuantity(hugepages[name], resource.BinarySI), name, resource.NewQuantity(host.Hugepages[name], resource.BinarySI)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkHostResources validates the resources blocks of the configuration against the host below root.
+// The host is not read when no container declares resources.
+func checkHostResources(config *parser.Configuration, root string) error {
+	if !hasResources(config) {
+		return nil
+	}
+	if root == "" {
+		root = "/"
+	}
+	host, err := ReadHostCapacity(root)
+	if err != nil {
+		return err
+	}
+	return ValidateResources(config, host)
+}
@@ -0,0 +1,149 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeHostFiles writes files below a fake host root, keyed by their path relative to the root.
+func writeHostFiles(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for path, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, path)), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(root, path), []byte(content), 0644))
+	}
+	return root
+}
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := parseCPUList("0-3,8,10-11\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 8, 10, 11}, cpus)
+
+	for _, list := range []string{"a", "3-1", "0-b"} {
+		_, err := parseCPUList(list)
+		assert.Error(t, err, list)
+	}
+}
+
+func TestReadHostCapacity(t *testing.T) {
+	root := writeHostFiles(t, map[string]string{
+		"sys/devices/system/cpu/online":                            "0-7\n",
+		"sys/devices/system/node/online":                           "0-1\n",
+		"proc/meminfo":                                             "MemTotal:       16384000 kB\nMemFree:         8000000 kB\n",
+		"sys/kernel/mm/hugepages/hugepages-2048kB/nr_hugepages":    "1024\n",
+		"sys/kernel/mm/hugepages/hugepages-1048576kB/nr_hugepages": "2\n",
+	})
+
+	host, err := ReadHostCapacity(root)
+	assert.NoError(t, err)
+	assert.Equal(t, HostCapacity{
+		CPUs:      []int{0, 1, 2, 3, 4, 5, 6, 7},
+		NUMANodes: []int{0, 1},
+		Memory:    16384000 * 1024,
+		Hugepages: map[string]int64{"hugepages-2Mi": 2 << 30, "hugepages-1Gi": 2 << 30},
+	}, host)
+
+	// hosts without NUMA and hugepages support
+	root = writeHostFiles(t, map[string]string{
+		"sys/devices/system/cpu/online": "0-1\n",
+		"proc/meminfo":                  "MemTotal:       1024 kB\n",
+	})
+	host, err = ReadHostCapacity(root)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0}, host.NUMANodes)
+	assert.Empty(t, host.Hugepages)
+
+	_, err = ReadHostCapacity(t.TempDir())
+	assert.ErrorContains(t, err, "failed to read online CPUs")
+}
+
+func TestValidateResources(t *testing.T) {
+	host := HostCapacity{
+		CPUs:      []int{0, 1, 2, 3},
+		NUMANodes: []int{0},
+		Memory:    8 << 30,
+		Hugepages: map[string]int64{"hugepages-2Mi": 1 << 30},
+	}
+	newConfig := func(pipelines ...workloads.Resources) *parser.Configuration {
+		config := &parser.Configuration{}
+		for n, resources := range pipelines {
+			config.WorkloadToBeRun = append(config.WorkloadToBeRun, workloads.WorkloadConfig{
+				FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: []string{"pipeline-0", "pipeline-1"}[n], ImageAndTag: "tiber-broadcast-suite:latest", Resources: resources},
+			})
+		}
+		return config
+	}
+
+	var fits workloads.Resources
+	fits.Requests.CPU = "1"
+	fits.Limits.CPU = "2"
+	fits.Limits.Memory = "4Gi"
+	fits.Limits.Hugepages2Mi = "512Mi"
+	fits.CpusetCpus = "2-3"
+	fits.CpusetMems = "0"
+	assert.NoError(t, ValidateResources(newConfig(fits, fits), host))
+	assert.NoError(t, ValidateResources(newConfig(), host))
+
+	tooLarge := fits
+	tooLarge.Limits.CPU = "6"
+	tooLarge.Limits.Memory = "16Gi"
+	tooLarge.CpusetCpus = "2-4"
+	tooLarge.CpusetMems = "1"
+	assert.EqualError(t, ValidateResources(newConfig(tooLarge), host), ""+
+		"container pipeline-0: limits.cpu 6 exceeds the 4 CPUs of the host\n"+
+		"container pipeline-0: limits.memory 16Gi exceeds the 8Gi of memory of the host\n"+
+		"container pipeline-0: cpusetCpus 2-4 contains CPU 4 which is not online on the host\n"+
+		"container pipeline-0: limits.cpu 6 exceeds the 3 CPUs of cpusetCpus 2-4\n"+
+		"container pipeline-0: cpusetMems 1 contains NUMA node 1 which is not online on the host")
+
+	inverted := fits
+	inverted.Requests.CPU = "3"
+	inverted.Requests.Memory = "5Gi"
+	inverted.Requests.Hugepages2Mi = "1Gi"
+	assert.EqualError(t, ValidateResources(newConfig(inverted), host), ""+
+		"container pipeline-0: requests.cpu 3 exceeds limits.cpu 2\n"+
+		"container pipeline-0: requests.memory 5Gi exceeds limits.memory 4Gi\n"+
+		"container pipeline-0: requests.hugepages-2Mi 1Gi exceeds limits.hugepages-2Mi 512Mi")
+
+	hungry := fits
+	hungry.Limits.Hugepages2Mi = "768Mi"
+	hungry.Limits.Hugepages1Gi = "1Gi"
+	assert.EqualError(t, ValidateResources(newConfig(fits, hungry), host), ""+
+		"the containers need 1280Mi of hugepages-2Mi, but the host reserves 1Gi\n"+
+		"the containers need 1Gi of hugepages-1Gi, but the host reserves 0")
+
+	invalid := fits
+	invalid.Limits.CPU = "two"
+	invalid.Limits.Hugepages2Mi = "3Mi"
+	assert.ErrorContains(t, ValidateResources(newConfig(invalid), host), `container pipeline-0: invalid resources.limits.cpu "two"`)
+	invalid.Limits.CPU = "2"
+	assert.EqualError(t, ValidateResources(newConfig(invalid), host), `container pipeline-0: resources hugepages-2Mi "3Mi" is not a multiple of the page size`)
+}
+
+func TestCheckHostResources(t *testing.T) {
+	config := &parser.Configuration{WorkloadToBeRun: []workloads.WorkloadConfig{{
+		FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: "pipeline-0", ImageAndTag: "tiber-broadcast-suite:latest"},
+	}}}
+
+	// the host is not read without resources blocks
+	assert.NoError(t, checkHostResources(config, t.TempDir()))
+
+	config.WorkloadToBeRun[0].FfmpegPipeline.Resources.Limits.CPU = "4"
+	root := writeHostFiles(t, map[string]string{
+		"sys/devices/system/cpu/online": "0-1\n",
+		"proc/meminfo":                  "MemTotal:       1024 kB\n",
+	})
+	assert.EqualError(t, checkHostResources(config, root), "container pipeline-0: limits.cpu 4 exceeds the 2 CPUs of the host")
+}
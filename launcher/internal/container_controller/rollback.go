@@ -0,0 +1,95 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/go-logr/logr"
+)
+
+// FailurePolicy decides what happens to the containers of a run when one of them fails.
+type FailurePolicy string
+
+const (
+	// FailurePolicyRollback stops starting containers and removes every container created during the run.
+	FailurePolicyRollback FailurePolicy = "rollback"
+	// FailurePolicyKeepGoing keeps the created containers and starts every container not depending on the failed one.
+	// It is also the behaviour of an empty policy.
+	FailurePolicyKeepGoing FailurePolicy = "keep-going"
+)
+
+// ContainerResult is the outcome of a run for a single container.
+type ContainerResult string
+
+const (
+	ResultCreated        ContainerResult = "created"
+	ResultRecreated      ContainerResult = "recreated"
+	ResultUnchanged      ContainerResult = "unchanged"
+	ResultFailed         ContainerResult = "failed"
+	ResultNotStarted     ContainerResult = "not started"
+	ResultRolledBack     ContainerResult = "rolled back"
+	ResultRollbackFailed ContainerResult = "rollback failed"
+)
+
+type RunReportEntry struct {
+	Container string          `json:"container"`
+	Type      string          `json:"type"`
+	Result    ContainerResult `json:"result"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// RunReport lists the outcome of a run for every declared container in declaration order.
+type RunReport struct {
+	Entries []RunReportEntry `json:"entries"`
+}
+
+// Failed tells whether any container of the run failed or has not been started.
+func (r RunReport) Failed() bool {
+	for _, entry := range r.Entries {
+		if entry.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteTable writes the report as a table with one row per container.
+func (r RunReport) WriteTable(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "CONTAINER\tTYPE\tRESULT\tERROR")
+	for _, entry := range r.Entries {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", entry.Container, entry.Type, entry.Result, entry.Error)
+	}
+	return table.Flush()
+}
+
+// rollback removes the containers created during the run in reverse declaration order.
+// Containers that were running before the run are left alone. A container re-created during the run
+// is removed too, its previous instance cannot be restored.
+func rollback(ctx context.Context, cli ContainerController, log logr.Logger, nodes []startupNode, outcomes []startupOutcome) {
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if !outcomes[i].created {
+			continue
+		}
+		name := nodes[i].info.ContainerName
+		log.Info("Rolling back container created during the failed run", "container", name)
+		err := removeContainer(ctx, cli, name)
+		if err != nil {
+			log.Error(err, "Failed to roll back container", "container", name)
+			outcomes[i].result = ResultRollbackFailed
+			if outcomes[i].err == nil {
+				outcomes[i].err = fmt.Errorf("failed to remove container %s: %w", name, err)
+			}
+			continue
+		}
+		outcomes[i].result = ResultRolledBack
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func reportResults(report RunReport) map[string]ContainerResult {
+	results := map[string]ContainerResult{}
+	for _, entry := range report.Entries {
+		results[entry.Container] = entry.Result
+	}
+	return results
+}
+
+func TestCreateAndRunContainersOnFailure(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+
+	t.Run("Rollback removes only the containers created during the run", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		mockController.On("ContainerList", ctx, container.ListOptions{All: true}).Return([]types.Container{
+			{Names: []string{"/" + MediaProxyAgentContainerName}, State: "running"},
+		}, nil)
+		mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{{RepoTags: []string{"mcm-image:latest", "ffmpeg-image:latest"}}}, nil)
+		mockController.On("ContainerCreate", ctx, mock.Anything, mock.Anything, mock.Anything, nil, MediaProxyContainerName).Return(container.CreateResponse{ID: "mcm-id"}, nil)
+		mockController.On("ContainerStart", ctx, "mcm-id", container.StartOptions{}).Return(nil)
+		mockController.On("ContainerCreate", ctx, mock.Anything, mock.Anything, mock.Anything, nil, "ffmpeg-pipeline").Return(container.CreateResponse{ID: "ffmpeg-id"}, nil)
+		mockController.On("ContainerStart", ctx, "ffmpeg-id", container.StartOptions{}).Return(errors.New("failed to start container"))
+		mockController.On("ContainerRemove", ctx, "ffmpeg-pipeline", container.RemoveOptions{Force: true}).Return(nil)
+		mockController.On("ContainerRemove", ctx, MediaProxyContainerName, container.RemoveOptions{Force: true}).Return(nil)
+
+		report, err := CreateAndRunContainersWithOptions(ctx, mockController, log, teardownTestConfig(), RunOptions{OnFailure: FailurePolicyRollback})
+		mockController.AssertExpectations(t)
+
+		assert.EqualError(t, err, "failed to start container")
+		assert.True(t, report.Failed())
+		assert.Equal(t, map[string]ContainerResult{
+			MediaProxyAgentContainerName: ResultUnchanged,
+			MediaProxyContainerName:      ResultRolledBack,
+			"ffmpeg-pipeline":            ResultRolledBack,
+			"nmos-client":                ResultNotStarted,
+		}, reportResults(report))
+		mockController.AssertNotCalled(t, "ContainerRemove", ctx, MediaProxyAgentContainerName, mock.Anything)
+	})
+
+	t.Run("Keep-going starts independent workloads and keeps created containers", func(t *testing.T) {
+		config := startupTestConfig(2)
+		config.RunOnce.MediaProxyAgent.ImageAndTag = ""
+		config.RunOnce.MediaProxyMcm[0].ImageAndTag = ""
+
+		mockController := newStartupMock(ctx)
+		mockController.On("ContainerCreate", ctx, mock.Anything, mock.Anything, mock.Anything, nil, "ffmpeg-pipeline-0").Return(container.CreateResponse{}, errors.New("failed to create container"))
+		mockController.On("ContainerCreate", ctx, mock.Anything, mock.Anything, mock.Anything, nil, mock.Anything).Return(container.CreateResponse{ID: "test-id"}, nil)
+
+		report, err := CreateAndRunContainersWithOptions(ctx, mockController, log, config, RunOptions{OnFailure: FailurePolicyKeepGoing})
+
+		assert.EqualError(t, err, "failed to create container")
+		assert.Equal(t, map[string]ContainerResult{
+			"ffmpeg-pipeline-0": ResultFailed,
+			"nmos-client-0":     ResultNotStarted,
+			"ffmpeg-pipeline-1": ResultCreated,
+			"nmos-client-1":     ResultCreated,
+		}, reportResults(report))
+		mockController.AssertNotCalled(t, "ContainerRemove", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRunReportWriteTable(t *testing.T) {
+	report := RunReport{Entries: []RunReportEntry{
+		{Container: MediaProxyAgentContainerName, Type: "MediaProxyAgent", Result: ResultUnchanged},
+		{Container: "ffmpeg-pipeline", Type: "BcsPipelineFfmpeg", Result: ResultRolledBack, Error: "failed to start container"},
+	}}
+
+	var table bytes.Buffer
+	assert.NoError(t, report.WriteTable(&table))
+	assert.Equal(t, ""+
+		"CONTAINER        TYPE               RESULT       ERROR\n"+
+		"mesh-agent       MediaProxyAgent    unchanged    \n"+
+		"ffmpeg-pipeline  BcsPipelineFfmpeg  rolled back  failed to start container\n", table.String())
+}
@@ -0,0 +1,324 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/utils"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+
+	"github.com/go-logr/logr"
+)
+
+// RunOptions controls how CreateAndRunContainersWithOptions starts the containers.
+type RunOptions struct {
+	ReadinessTimeout  time.Duration // time a container gets to become ready before its dependents fail; zero disables the readiness gates
+	ReadinessInterval time.Duration // delay between two readiness probes of the same container
+	OnFailure         FailurePolicy // what happens to the containers of a run that failed part way
+	Lockfile          string        // path of the lockfile pinning the images, empty when images are not locked
+	HostRoot          string        // root the proc and sys filesystems of the host are read from, / when empty
+	CheckHostPaths    bool          // check that the host paths the containers mount exist under HostRoot before anything is created
+}
+
+// DefaultRunOptions returns the options used by the launcher when no flags override them.
+func DefaultRunOptions() RunOptions {
+	return RunOptions{
+		ReadinessTimeout:  60 * time.Second,
+		ReadinessInterval: 500 * time.Millisecond,
+		OnFailure:         FailurePolicyRollback,
+		CheckHostPaths:    true,
+	}
+}
+
+var errContainerExited = errors.New("container exited")
+
+// startupNode is a container in the startup dependency graph.
+type startupNode struct {
+	info      general.Containers
+	dependsOn []int  // indexes of the nodes that have to be ready before this one is started
+	address   string // host:port probed by the readiness gate, empty when only the container state is checked
+	// unreachable is the driver of the network when the host cannot reach the container through it (macvlan, ipvlan).
+	// The readiness gate then relies on the Docker health status of the container instead of the address.
+	unreachable string
+}
+
+// unreachableDrivers are the network drivers whose containers cannot be reached from the host they run on.
+var unreachableDrivers = map[string]bool{"macvlan": true, "ipvlan": true}
+
+// startupGraph orders the declared containers: MCM MediaProxy Agent before the MCM MediaProxy instances,
+// the MCM MediaProxy instance a workload attaches to before its FFmpeg pipeline and each FFmpeg pipeline before
+// its NMOS client. Missing components are skipped, so a pipeline depends on the Agent when MCM MediaProxy is not declared.
+func startupGraph(config *parser.Configuration) []startupNode {
+	var nodes []startupNode
+	drivers := map[string]string{}
+	if requests, err := requestedNetworks(config); err == nil {
+		for _, request := range requests {
+			drivers[request.name] = request.definition.Driver
+		}
+	}
+	agent := -1
+	mediaProxies, pipelines := map[int]int{}, map[int]int{}
+	for _, declared := range declaredContainers(config) {
+		node := startupNode{info: declared}
+		switch declared.Type {
+		case general.MediaProxyAgent:
+			agent = len(nodes)
+			node.address = readinessAddress(config.RunOnce.MediaProxyAgent.Network, config.RunOnce.MediaProxyAgent.GRPCPort)
+		case general.MediaProxyMCM:
+			mediaProxies[declared.Id] = len(nodes)
+			if agent >= 0 {
+				node.dependsOn = []int{agent}
+			}
+		case general.BcsPipelineFfmpeg:
+			pipelines[declared.Id] = len(nodes)
+			if mediaProxy, ok := mediaProxies[config.RunOnce.MediaProxyMcm.Find(config.WorkloadToBeRun[declared.Id].MediaProxy)]; ok {
+				node.dependsOn = []int{mediaProxy}
+			} else if agent >= 0 {
+				node.dependsOn = []int{agent}
+			}
+			pipeline := config.WorkloadToBeRun[declared.Id].FfmpegPipeline
+			node.address = readinessAddress(pipeline.Network, strconv.Itoa(pipeline.GRPCPort))
+		case general.BcsPipelineNmosClient:
+			if pipeline, ok := pipelines[declared.Id]; ok {
+				node.dependsOn = []int{pipeline}
+			}
+			nmosClient := config.WorkloadToBeRun[declared.Id].NmosClient
+			node.address = readinessAddress(nmosClient.Network, strconv.Itoa(nmosClient.NmosPort))
+		}
+		if networkConfig := containerNetworkConfig(declared, config); networkConfig.Enable && unreachableDrivers[drivers[networkConfig.Name]] {
+			node.address, node.unreachable = "", drivers[networkConfig.Name]
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// readinessAddress returns the address the service of a container listens on as seen from the host.
+// The configured IP is used when there is one, otherwise the port published on the host is probed.
+func readinessAddress(networkConfig workloads.NetworkConfig, port string) string {
+	if port == "" || port == "0" {
+		return ""
+	}
+	host := "127.0.0.1"
+	if networkConfig.IP != "" && networkConfig.IP != "host" {
+		host = networkConfig.IP
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// validateContainerSettings checks the settings of the declared containers that ConstructContainerConfig
+// falls back to defaults for, so a mistake in the configuration file stops the run instead.
+func validateContainerSettings(config *parser.Configuration) error {
+	var errs []error
+	if err := utils.ValidateMediaProxies(config); err != nil {
+		errs = append(errs, err)
+	}
+	for _, containerInfo := range declaredContainers(config) {
+		if _, err := utils.ContainerSecurityProfile(&containerInfo, config); err != nil {
+			errs = append(errs, fmt.Errorf("container %s: %w", containerInfo.ContainerName, err))
+		}
+		if _, err := utils.ContainerHealthcheck(&containerInfo, config); err != nil {
+			errs = append(errs, fmt.Errorf("container %s: %w", containerInfo.ContainerName, err))
+		}
+		if _, err := utils.ContainerRestartPolicy(&containerInfo, config); err != nil {
+			errs = append(errs, fmt.Errorf("container %s: %w", containerInfo.ContainerName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CreateAndRunContainersWithOptions creates and runs the containers declared in the launcher configuration.
+// Containers are started as soon as the containers they depend on are ready, so independent workloads start
+// in parallel. When a container fails, the containers depending on it are not started and opts.OnFailure decides
+// what happens to the rest of the run. The report lists the outcome for every declared container.
+// The first failure in declaration order is returned.
+func CreateAndRunContainersWithOptions(ctx context.Context, cli ContainerController, log logr.Logger, config *parser.Configuration, opts RunOptions) (RunReport, error) {
+	for _, instance := range config.WorkloadToBeRun {
+		if IsEmptyStruct(instance.FfmpegPipeline) || IsEmptyStruct(instance.NmosClient) {
+			return RunReport{}, fmt.Errorf("no information about BCS pipeline provided. Either FfmpegPipeline or NmosClient is empty for instance Ffmpeg: %s; Nmos: %s", instance.FfmpegPipeline.Name, instance.NmosClient.Name)
+		}
+	}
+
+	if IsEmptyStruct(config.RunOnce.MediaProxyAgent) {
+		log.Info("No information about MCM MediaProxy Agent provided. Omitting creation of MCM MediaProxy Agent container")
+	}
+	if len(config.RunOnce.MediaProxyMcm) == 0 {
+		log.Info("No information about MCM MediaProxy provided. Omitting creation of MCM MediaProxy container")
+	}
+	if len(config.WorkloadToBeRun) == 0 {
+		log.Info("No workloads provided under workloadToBeRun. Omitting creation of BCS pipeline and NMOS node containers")
+	}
+
+	if err := ValidateConfiguration(config, ValidateOptions{HostPaths: opts.CheckHostPaths, HostRoot: opts.HostRoot}); err != nil {
+		log.Error(err, "Invalid launcher configuration")
+		return RunReport{}, err
+	}
+
+	if err := checkHostResources(config, opts.HostRoot); err != nil {
+		log.Error(err, "Container resources exceed the host capacity")
+		return RunReport{}, err
+	}
+
+	if opts.Lockfile != "" {
+		if err := LockImages(ctx, cli, log, config, opts.Lockfile); err != nil {
+			log.Error(err, "Images do not match the lockfile")
+			return RunReport{}, err
+		}
+	}
+
+	if err := EnsureNetworks(ctx, cli, log, config); err != nil {
+		log.Error(err, "Failed to prepare custom networks")
+		return RunReport{}, err
+	}
+
+	nodes := startupGraph(config)
+	outcomes := make([]startupOutcome, len(nodes))
+	done := make([]chan struct{}, len(nodes))
+	for i := range nodes {
+		done[i] = make(chan struct{})
+	}
+	// aborted is closed on the first failure when the run is rolled back, so no further containers are started
+	aborted := make(chan struct{})
+	var abortOnce sync.Once
+	for i := range nodes {
+		go func(i int) {
+			defer close(done[i])
+			for _, dependency := range nodes[i].dependsOn {
+				<-done[dependency]
+				if outcomes[dependency].err != nil {
+					outcomes[i] = startupOutcome{result: ResultNotStarted, err: fmt.Errorf("dependency %s of container %s failed", nodes[dependency].info.ContainerName, nodes[i].info.ContainerName)}
+					log.Info("Omitting container creation because its dependency failed", "container", nodes[i].info.ContainerName, "dependency", nodes[dependency].info.ContainerName)
+					return
+				}
+			}
+			select {
+			case <-aborted:
+				outcomes[i] = startupOutcome{result: ResultNotStarted, err: fmt.Errorf("container %s is not started because the run is rolled back", nodes[i].info.ContainerName)}
+				return
+			default:
+			}
+			outcomes[i] = startContainer(ctx, cli, log, nodes[i], config, opts)
+			if outcomes[i].err != nil && opts.OnFailure == FailurePolicyRollback {
+				abortOnce.Do(func() { close(aborted) })
+			}
+		}(i)
+	}
+	for i := range nodes {
+		<-done[i]
+	}
+
+	var firstErr error
+	for _, outcome := range outcomes {
+		if outcome.err != nil {
+			firstErr = outcome.err
+			break
+		}
+	}
+	if firstErr != nil && opts.OnFailure == FailurePolicyRollback {
+		rollback(ctx, cli, log, nodes, outcomes)
+	}
+
+	report := RunReport{}
+	for i, node := range nodes {
+		entry := RunReportEntry{Container: node.info.ContainerName, Type: node.info.Type.String(), Result: outcomes[i].result}
+		if outcomes[i].err != nil {
+			entry.Error = outcomes[i].err.Error()
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+	return report, firstErr
+}
+
+// startupOutcome is the result of starting a single container.
+type startupOutcome struct {
+	result  ContainerResult
+	created bool // a container has been created during this run, even if it failed afterwards
+	err     error
+}
+
+func startContainer(ctx context.Context, cli ContainerController, log logr.Logger, node startupNode, config *parser.Configuration, opts RunOptions) startupOutcome {
+	result, err := ensureContainer(ctx, cli, log, &node.info, config)
+	outcome := startupOutcome{result: result, created: result == ResultCreated || result == ResultRecreated}
+	if err != nil {
+		log.Error(err, "Failed to create container", "container", node.info.ContainerName, "type", node.info.Type.String())
+		outcome.result, outcome.err = ResultFailed, err
+		return outcome
+	}
+	if opts.ReadinessTimeout <= 0 {
+		return outcome
+	}
+	err = waitUntilReady(ctx, cli, log, node, opts)
+	if err != nil {
+		log.Error(err, "Container did not become ready", "container", node.info.ContainerName)
+		outcome.result, outcome.err = ResultFailed, err
+	}
+	return outcome
+}
+
+// waitUntilReady probes the container until it is running and, when it has a readiness address,
+// accepts TCP connections on it. It gives up after opts.ReadinessTimeout or as soon as the container exits.
+func waitUntilReady(ctx context.Context, cli ContainerController, log logr.Logger, node startupNode, opts RunOptions) error {
+	ctx, cancel := context.WithTimeout(ctx, opts.ReadinessTimeout)
+	defer cancel()
+	if node.unreachable != "" {
+		log.Info("Host cannot reach the container through its network. Readiness is taken from its health check instead of a TCP probe",
+			"container", node.info.ContainerName, "driver", node.unreachable)
+	}
+
+	for {
+		err := probeReadiness(ctx, cli, node)
+		if err == nil {
+			log.Info("Container is ready", "container", node.info.ContainerName, "address", node.address)
+			return nil
+		}
+		if errors.Is(err, errContainerExited) {
+			return fmt.Errorf("container %s is not ready: %w", node.info.ContainerName, err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("container %s is not ready within %s: %w", node.info.ContainerName, opts.ReadinessTimeout, err)
+		case <-time.After(opts.ReadinessInterval):
+		}
+	}
+}
+
+func probeReadiness(ctx context.Context, cli ContainerController, node startupNode) error {
+	info, err := cli.ContainerInspect(ctx, node.info.ContainerName)
+	if err != nil {
+		return err
+	}
+	if info.ContainerJSONBase == nil || info.State == nil {
+		return errors.New("container state is unknown")
+	}
+	if !info.State.Running {
+		if info.State.Status == "exited" || info.State.Status == "dead" {
+			return fmt.Errorf("%w with code %d", errContainerExited, info.State.ExitCode)
+		}
+		return fmt.Errorf("container is %s", info.State.Status)
+	}
+	if node.unreachable != "" && info.State.Health != nil && info.State.Health.Status != "healthy" {
+		return fmt.Errorf("container is %s", info.State.Health.Status)
+	}
+	if node.address == "" {
+		return nil
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", node.address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
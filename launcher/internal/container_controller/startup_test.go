@@ -0,0 +1,280 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func startupTestConfig(workloadCount int) *parser.Configuration {
+	config := teardownTestConfig()
+	config.WorkloadToBeRun = nil
+	for i := 0; i < workloadCount; i++ {
+		config.WorkloadToBeRun = append(config.WorkloadToBeRun, workloads.WorkloadConfig{
+			FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: "ffmpeg-pipeline-" + strconv.Itoa(i), ImageAndTag: "ffmpeg-image:latest"},
+			NmosClient:     workloads.NmosClientConfig{Name: "nmos-client-" + strconv.Itoa(i), ImageAndTag: "nmos-image:latest"},
+		})
+	}
+	return config
+}
+
+// listen opens a TCP listener on a free local port and returns the port.
+func listen(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func newStartupMock(ctx context.Context) *MockContainerController {
+	mockController := new(MockContainerController)
+	mockController.On("ContainerList", ctx, container.ListOptions{All: true}).Return([]types.Container{}, nil)
+	mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{{RepoTags: []string{"agent-image:latest", "mcm-image:latest", "ffmpeg-image:latest", "nmos-image:latest"}}}, nil)
+	mockController.On("ContainerStart", ctx, mock.Anything, container.StartOptions{}).Return(nil)
+	return mockController
+}
+
+func TestStartupGraph(t *testing.T) {
+	config := startupTestConfig(2)
+	config.RunOnce.MediaProxyAgent.GRPCPort = "50051"
+	config.WorkloadToBeRun[0].FfmpegPipeline.GRPCPort = 50055
+	config.WorkloadToBeRun[0].FfmpegPipeline.Network = workloads.NetworkConfig{Enable: true, Name: "bcs-net", IP: "10.0.0.2"}
+	config.WorkloadToBeRun[1].NmosClient.NmosPort = 5004
+
+	nodes := startupGraph(config)
+
+	dependencies := map[string][]string{}
+	for _, node := range nodes {
+		dependencies[node.info.ContainerName] = []string{}
+		for _, dependency := range node.dependsOn {
+			dependencies[node.info.ContainerName] = append(dependencies[node.info.ContainerName], nodes[dependency].info.ContainerName)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		MediaProxyAgentContainerName: {},
+		MediaProxyContainerName:      {MediaProxyAgentContainerName},
+		"ffmpeg-pipeline-0":          {MediaProxyContainerName},
+		"nmos-client-0":              {"ffmpeg-pipeline-0"},
+		"ffmpeg-pipeline-1":          {MediaProxyContainerName},
+		"nmos-client-1":              {"ffmpeg-pipeline-1"},
+	}, dependencies)
+	assert.Equal(t, "127.0.0.1:50051", nodes[0].address)
+	assert.Equal(t, "", nodes[1].address)
+	assert.Equal(t, "10.0.0.2:50055", nodes[2].address)
+	assert.Equal(t, "127.0.0.1:5004", nodes[5].address)
+
+	config.RunOnce.MediaProxyMcm = nil
+	nodes = startupGraph(config)
+	assert.Equal(t, []int{0}, nodes[1].dependsOn, "pipelines depend on the agent when media proxy is not declared")
+}
+
+func TestStartupGraphMediaProxyInstances(t *testing.T) {
+	config := startupTestConfig(2)
+	config.RunOnce.MediaProxyMcm = append(config.RunOnce.MediaProxyMcm, workloads.MediaProxyMcmConfig{Name: "media-proxy-nic1", ImageAndTag: "mcm-image:latest"})
+	config.WorkloadToBeRun[1].MediaProxy = "media-proxy-nic1"
+
+	nodes := startupGraph(config)
+
+	assert.Equal(t, []string{MediaProxyAgentContainerName, MediaProxyContainerName, "media-proxy-nic1", "ffmpeg-pipeline-0", "nmos-client-0", "ffmpeg-pipeline-1", "nmos-client-1"},
+		[]string{nodes[0].info.ContainerName, nodes[1].info.ContainerName, nodes[2].info.ContainerName, nodes[3].info.ContainerName, nodes[4].info.ContainerName, nodes[5].info.ContainerName, nodes[6].info.ContainerName})
+	assert.Equal(t, []int{0}, nodes[2].dependsOn)
+	assert.Equal(t, []int{1}, nodes[3].dependsOn, "workloads without mediaProxy attach to the first instance")
+	assert.Equal(t, []int{2}, nodes[5].dependsOn)
+}
+
+func TestStartupGraphMacvlan(t *testing.T) {
+	config := startupTestConfig(1)
+	config.RunOnce = parser.RunOnce{}
+	config.WorkloadToBeRun[0].FfmpegPipeline.GRPCPort = 50055
+	config.WorkloadToBeRun[0].FfmpegPipeline.Network = workloads.NetworkConfig{Enable: true, Name: "st2110", IP: "192.168.10.2",
+		Driver: "macvlan", Subnet: "192.168.10.0/24", Parent: "ens801f0"}
+	config.WorkloadToBeRun[0].NmosClient.NmosPort = 5004
+	config.WorkloadToBeRun[0].NmosClient.Network = workloads.NetworkConfig{Enable: true, Name: "st2110", IP: "192.168.10.3"}
+
+	nodes := startupGraph(config)
+
+	assert.Equal(t, startupNode{info: nodes[0].info, unreachable: "macvlan"}, nodes[0], "the host cannot reach its macvlan children")
+	assert.Equal(t, "macvlan", nodes[1].unreachable, "the network is defined by another container")
+	assert.Empty(t, nodes[1].address)
+}
+
+func TestWaitUntilReadyUnreachable(t *testing.T) {
+	ctx := context.Background()
+	node := startupNode{info: general.Containers{ContainerName: "ffmpeg-pipeline-0"}, unreachable: "macvlan"}
+	opts := RunOptions{ReadinessTimeout: 2 * time.Second, ReadinessInterval: 10 * time.Millisecond}
+	health := func(status string) container.InspectResponse {
+		state := runningState(true)
+		state.State.Health = &container.Health{Status: status}
+		return state
+	}
+
+	mockController := new(MockContainerController)
+	mockController.On("ContainerInspect", mock.Anything, "ffmpeg-pipeline-0").Return(health("starting"), nil).Twice()
+	mockController.On("ContainerInspect", mock.Anything, "ffmpeg-pipeline-0").Return(health("healthy"), nil)
+	assert.NoError(t, waitUntilReady(ctx, mockController, logr.Discard(), node, opts))
+	mockController.AssertNumberOfCalls(t, "ContainerInspect", 3)
+
+	mockController = new(MockContainerController)
+	mockController.On("ContainerInspect", mock.Anything, "ffmpeg-pipeline-0").Return(health("unhealthy"), nil)
+	opts.ReadinessTimeout = 50 * time.Millisecond
+	assert.ErrorContains(t, waitUntilReady(ctx, mockController, logr.Discard(), node, opts), "container is unhealthy")
+
+	mockController = new(MockContainerController)
+	mockController.On("ContainerInspect", mock.Anything, "ffmpeg-pipeline-0").Return(runningState(true), nil)
+	assert.NoError(t, waitUntilReady(ctx, mockController, logr.Discard(), node, opts), "running is enough without a health check")
+}
+
+func TestValidateContainerSettings(t *testing.T) {
+	config := startupTestConfig(1)
+	assert.NoError(t, validateContainerSettings(config))
+
+	config.SecurityProfile = "root"
+	config.WorkloadToBeRun[0].FfmpegPipeline.HealthCheck = workloads.HealthCheck{Interval: "often"}
+	config.WorkloadToBeRun[0].NmosClient.RestartPolicy = workloads.RestartPolicy{Name: "sometimes"}
+	err := validateContainerSettings(config)
+	assert.ErrorContains(t, err, `container mesh-agent: unknown security profile "root"`)
+	assert.ErrorContains(t, err, `container ffmpeg-pipeline-0: invalid healthCheck.interval "often"`)
+	assert.ErrorContains(t, err, "container nmos-client-0: invalid restartPolicy")
+
+	config = startupTestConfig(1)
+	config.RunOnce.MediaProxyMcm = append(config.RunOnce.MediaProxyMcm, workloads.MediaProxyMcmConfig{ImageAndTag: "mcm-image:latest"})
+	config.WorkloadToBeRun[0].MediaProxy = "media-proxy-nic1"
+	err = validateContainerSettings(config)
+	assert.ErrorContains(t, err, `mediaProxyMcm: duplicate instance name "media-proxy"`)
+	assert.ErrorContains(t, err, `workloadToBeRun[0]: unknown mediaProxy "media-proxy-nic1"`)
+
+	// nothing is created with invalid settings
+	mockController := new(MockContainerController)
+	_, err = CreateAndRunContainersWithOptions(context.Background(), mockController, logr.Discard(), config, DefaultRunOptions())
+	assert.Error(t, err)
+	mockController.AssertExpectations(t)
+}
+
+func TestCreateAndRunContainersWithOptions(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+	opts := RunOptions{ReadinessTimeout: 2 * time.Second, ReadinessInterval: 10 * time.Millisecond}
+
+	t.Run("Starts NMOS client after its FFmpeg pipeline is listening", func(t *testing.T) {
+		config := startupTestConfig(1)
+		config.RunOnce = parser.RunOnce{}
+		config.WorkloadToBeRun[0].FfmpegPipeline.GRPCPort = listen(t)
+		config.WorkloadToBeRun[0].NmosClient.NmosPort = listen(t)
+
+		mockController := newStartupMock(ctx)
+		var mu sync.Mutex
+		var events []string
+		record := func(event string) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+		}
+		mockController.On("ContainerCreate", ctx, mock.Anything, mock.Anything, mock.Anything, nil, mock.Anything).
+			Run(func(args mock.Arguments) { record("create " + args.String(5)) }).Return(container.CreateResponse{ID: "test-id"}, nil)
+		mockController.On("ContainerInspect", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { record("probe " + args.String(1)) }).Return(runningState(true), nil)
+
+		_, err := CreateAndRunContainersWithOptions(ctx, mockController, log, config, opts)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"create ffmpeg-pipeline-0", "probe ffmpeg-pipeline-0", "create nmos-client-0", "probe nmos-client-0"}, events)
+	})
+
+	t.Run("Does not start dependents of a container that exits", func(t *testing.T) {
+		config := startupTestConfig(1)
+		config.RunOnce = parser.RunOnce{}
+
+		mockController := newStartupMock(ctx)
+		mockController.On("ContainerCreate", ctx, mock.Anything, mock.Anything, mock.Anything, nil, "ffmpeg-pipeline-0").Return(container.CreateResponse{ID: "test-id"}, nil)
+		mockController.On("ContainerInspect", mock.Anything, "ffmpeg-pipeline-0").Return(container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{State: &container.State{Status: "exited", ExitCode: 1}},
+		}, nil)
+
+		_, err := CreateAndRunContainersWithOptions(ctx, mockController, log, config, opts)
+
+		assert.ErrorIs(t, err, errContainerExited)
+		mockController.AssertNotCalled(t, "ContainerCreate", ctx, mock.Anything, mock.Anything, mock.Anything, nil, "nmos-client-0")
+	})
+
+	t.Run("Fails when a service does not listen within the timeout", func(t *testing.T) {
+		config := startupTestConfig(1)
+		config.RunOnce = parser.RunOnce{}
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		config.WorkloadToBeRun[0].FfmpegPipeline.GRPCPort = listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+
+		mockController := newStartupMock(ctx)
+		mockController.On("ContainerCreate", ctx, mock.Anything, mock.Anything, mock.Anything, nil, "ffmpeg-pipeline-0").Return(container.CreateResponse{ID: "test-id"}, nil)
+		mockController.On("ContainerInspect", mock.Anything, "ffmpeg-pipeline-0").Return(runningState(true), nil)
+
+		shortOpts := opts
+		shortOpts.ReadinessTimeout = 100 * time.Millisecond
+		_, err = CreateAndRunContainersWithOptions(ctx, mockController, log, config, shortOpts)
+
+		assert.ErrorContains(t, err, "is not ready within")
+		mockController.AssertNotCalled(t, "ContainerCreate", ctx, mock.Anything, mock.Anything, mock.Anything, nil, "nmos-client-0")
+	})
+
+	t.Run("Starts independent workloads in parallel", func(t *testing.T) {
+		config := startupTestConfig(2)
+		config.RunOnce = parser.RunOnce{}
+
+		mockController := newStartupMock(ctx)
+		// each pipeline waits in ContainerCreate until the other one is being created too
+		var pipelines sync.WaitGroup
+		pipelines.Add(2)
+		bothCreating := make(chan struct{})
+		go func() { pipelines.Wait(); close(bothCreating) }()
+		barrier := func(mock.Arguments) {
+			pipelines.Done()
+			select {
+			case <-bothCreating:
+			case <-time.After(time.Second):
+			}
+		}
+		for i := 0; i < 2; i++ {
+			mockController.On("ContainerCreate", ctx, mock.Anything, mock.Anything, mock.Anything, nil, "ffmpeg-pipeline-"+strconv.Itoa(i)).
+				Run(barrier).Return(container.CreateResponse{ID: "test-id"}, nil)
+			mockController.On("ContainerCreate", ctx, mock.Anything, mock.Anything, mock.Anything, nil, "nmos-client-"+strconv.Itoa(i)).
+				Return(container.CreateResponse{ID: "test-id"}, nil)
+		}
+
+		start := time.Now()
+		_, err := CreateAndRunContainersWithOptions(ctx, mockController, log, config, RunOptions{})
+
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(start), time.Second, "pipelines of independent workloads are created one after another")
+		mockController.AssertNumberOfCalls(t, "ContainerCreate", 4)
+	})
+}
+
+func TestCreateAndRunContainersWithOptions_HostPaths(t *testing.T) {
+	mockController := new(MockContainerController)
+	config := planTestConfig("/etc/bcs/nmos")
+
+	_, err := CreateAndRunContainersWithOptions(context.Background(), mockController, logr.Discard(), config,
+		RunOptions{CheckHostPaths: true, HostRoot: t.TempDir()})
+
+	assert.ErrorContains(t, err, "workloadToBeRun[0].nmosClient: NMOS json file /etc/bcs/nmos/nmos.json does not exist")
+	assert.Empty(t, mockController.Calls, "the container runtime is not touched")
+}
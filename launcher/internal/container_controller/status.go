@@ -0,0 +1,243 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/go-logr/logr"
+)
+
+// StateMissing is reported for declared containers that do not exist.
+const StateMissing = "missing"
+
+// Image status of a container: whether it runs the image its configured tag points to.
+const (
+	ImageCurrent  = "current"
+	ImageOutdated = "outdated"
+	ImageUntagged = "untagged" // the configured image is not present locally anymore
+)
+
+// nodeAPITimeout bounds the request probing the node API of an NMOS client.
+const nodeAPITimeout = 2 * time.Second
+
+type NetworkStatus struct {
+	Name string `json:"name"`
+	IP   string `json:"ip,omitempty"`
+}
+
+// ContainerStatus is the observed state of a container declared in the launcher configuration.
+type ContainerStatus struct {
+	Name          string          `json:"name"`
+	Type          string          `json:"type"`
+	State         string          `json:"state"`
+	Health        string          `json:"health,omitempty"` // starting, healthy or unhealthy when the container has a health check
+	StartedAt     string          `json:"startedAt,omitempty"`
+	UptimeSeconds int64           `json:"uptimeSeconds,omitempty"`
+	RestartCount  int             `json:"restartCount"`
+	Image         string          `json:"image"`
+	ImageID       string          `json:"imageId,omitempty"`
+	ConfiguredID  string          `json:"configuredImageId,omitempty"` // image the configured tag currently points to
+	ImageStatus   string          `json:"imageStatus,omitempty"`
+	Ports         []string        `json:"ports,omitempty"`
+	Networks      []NetworkStatus `json:"networks,omitempty"`
+	ExitCode      *int            `json:"exitCode,omitempty"` // last exit code of a container that is not running
+	// NodeAPIAddress and NodeAPIAnswers tell whether the node API of an NMOS client responds to HTTP requests.
+	NodeAPIAddress string `json:"nodeApiAddress,omitempty"`
+	NodeAPIAnswers *bool  `json:"nodeApiAnswers,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// Status lists the containers declared in the launcher configuration in the order they are started.
+type Status struct {
+	Containers []ContainerStatus `json:"containers"`
+}
+
+// StatusContainers inspects every container declared in the launcher configuration without changing anything.
+// A container that cannot be inspected is reported with its error; an error is returned only when the images
+// cannot be listed.
+func StatusContainers(ctx context.Context, cli ContainerController, log logr.Logger, config *parser.Configuration) (Status, error) {
+	status := Status{Containers: []ContainerStatus{}}
+	images, err := cli.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return status, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	httpClient := &http.Client{Timeout: nodeAPITimeout}
+	for _, node := range startupGraph(config) {
+		containerStatus := inspectContainerStatus(ctx, cli, node.info, images)
+		if node.info.Type == general.BcsPipelineNmosClient && node.address != "" && containerStatus.State == "running" {
+			answers := nodeAPIAnswers(ctx, httpClient, node.address)
+			containerStatus.NodeAPIAddress, containerStatus.NodeAPIAnswers = node.address, &answers
+		}
+		log.Info("Container status", "container", containerStatus.Name, "state", containerStatus.State)
+		status.Containers = append(status.Containers, containerStatus)
+	}
+	return status, nil
+}
+
+func inspectContainerStatus(ctx context.Context, cli ContainerController, containerInfo general.Containers, images []image.Summary) ContainerStatus {
+	containerStatus := ContainerStatus{
+		Name:  containerInfo.ContainerName,
+		Type:  containerInfo.Type.String(),
+		Image: containerInfo.Image,
+	}
+	if summary := findImage(images, containerInfo.Image); summary != nil {
+		containerStatus.ConfiguredID = summary.ID
+	}
+	info, err := cli.ContainerInspect(ctx, containerInfo.ContainerName)
+	if client.IsErrNotFound(err) {
+		containerStatus.State = StateMissing
+		return containerStatus
+	}
+	if err != nil {
+		containerStatus.State = "unknown"
+		containerStatus.Error = err.Error()
+		return containerStatus
+	}
+
+	if info.ContainerJSONBase != nil {
+		containerStatus.RestartCount = info.RestartCount
+		containerStatus.ImageID = info.Image
+		if info.State != nil {
+			containerStatus.State = info.State.Status
+			if info.State.Health != nil {
+				containerStatus.Health = info.State.Health.Status
+			}
+			if info.State.Running {
+				containerStatus.StartedAt = info.State.StartedAt
+				if started, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil {
+					containerStatus.UptimeSeconds = int64(time.Since(started).Seconds())
+				}
+			} else if info.State.Status != "created" {
+				exitCode := info.State.ExitCode
+				containerStatus.ExitCode = &exitCode
+			}
+		}
+	}
+	switch {
+	case containerStatus.ConfiguredID == "":
+		containerStatus.ImageStatus = ImageUntagged
+	case containerStatus.ConfiguredID == containerStatus.ImageID:
+		containerStatus.ImageStatus = ImageCurrent
+	default:
+		containerStatus.ImageStatus = ImageOutdated
+	}
+
+	if info.NetworkSettings != nil {
+		containerStatus.Ports = publishedPorts(info.NetworkSettings.Ports)
+		for name, endpoint := range info.NetworkSettings.Networks {
+			networkStatus := NetworkStatus{Name: name}
+			if endpoint != nil {
+				networkStatus.IP = endpoint.IPAddress
+			}
+			containerStatus.Networks = append(containerStatus.Networks, networkStatus)
+		}
+		sort.Slice(containerStatus.Networks, func(i, j int) bool { return containerStatus.Networks[i].Name < containerStatus.Networks[j].Name })
+	}
+	return containerStatus
+}
+
+// publishedPorts formats the exposed ports of a container like docker ps does, e.g. 0.0.0.0:8080->8080/tcp.
+func publishedPorts(ports nat.PortMap) []string {
+	var formatted []string
+	for port, bindings := range ports {
+		if len(bindings) == 0 {
+			formatted = append(formatted, string(port))
+		}
+		for _, binding := range bindings {
+			formatted = append(formatted, fmt.Sprintf("%s:%s->%s", binding.HostIP, binding.HostPort, port))
+		}
+	}
+	sort.Strings(formatted)
+	return formatted
+}
+
+// nodeAPIAnswers tells whether the NMOS node API at address responds to an HTTP request, whatever its status code.
+func nodeAPIAnswers(ctx context.Context, httpClient *http.Client, address string) bool {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/x-nmos/node/", nil)
+	if err != nil {
+		return false
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return false
+	}
+	response.Body.Close()
+	return true
+}
+
+// WriteJSON writes the status as indented JSON.
+func (s Status) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// WriteTable writes the status as a table with one row per container.
+func (s Status) WriteTable(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "CONTAINER\tTYPE\tSTATE\tUPTIME\tRESTARTS\tIMAGE\tPORTS\tNETWORKS\tEXIT CODE\tNODE API")
+	for _, c := range s.Containers {
+		uptime, image, exitCode, nodeAPI := "-", c.Image, "-", "-"
+		if c.StartedAt != "" {
+			uptime = (time.Duration(c.UptimeSeconds) * time.Second).String()
+		}
+		if c.ImageStatus != "" {
+			image += " (" + c.ImageStatus + ")"
+		}
+		if c.ExitCode != nil {
+			exitCode = strconv.Itoa(*c.ExitCode)
+		}
+		if c.NodeAPIAnswers != nil {
+			nodeAPI = c.NodeAPIAddress + " down"
+			if *c.NodeAPIAnswers {
+				nodeAPI = c.NodeAPIAddress + " up"
+			}
+		}
+		var networks []string
+		for _, n := range c.Networks {
+			if n.IP != "" {
+				networks = append(networks, n.Name+"="+n.IP)
+			} else {
+				networks = append(networks, n.Name)
+			}
+		}
+		state := c.State
+		if c.Health != "" {
+			state += " (" + c.Health + ")"
+		}
+		if c.Error != "" {
+			state += ": " + c.Error
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", c.Name, c.Type, state, uptime, c.RestartCount, image,
+			orDash(strings.Join(c.Ports, ",")), orDash(strings.Join(networks, ",")), exitCode, nodeAPI)
+	}
+	return table.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
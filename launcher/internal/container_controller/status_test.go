@@ -0,0 +1,114 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+func inspectResponse(state container.State, imageID string, restarts int) container.InspectResponse {
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{State: &state, Image: imageID, RestartCount: restarts},
+		NetworkSettings:   &container.NetworkSettings{},
+	}
+}
+
+func TestStatusContainers(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+
+	nodeAPI := httptest.NewServer(http.NotFoundHandler())
+	defer nodeAPI.Close()
+	nodeAPIPort, _ := strconv.Atoi(nodeAPI.URL[len("http://127.0.0.1:"):])
+
+	config := startupTestConfig(2)
+	config.WorkloadToBeRun[0].NmosClient.NmosPort = nodeAPIPort
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed.Close()
+	config.WorkloadToBeRun[1].NmosClient.NmosPort = closed.Addr().(*net.TCPAddr).Port
+	startedAt := time.Now().Add(-90 * time.Second).UTC().Format(time.RFC3339Nano)
+
+	agent := inspectResponse(container.State{Status: "running", Running: true, StartedAt: startedAt, Health: &container.Health{Status: container.Healthy}}, "sha256:agent", 0)
+	agent.NetworkSettings.Ports = nat.PortMap{"50051/tcp": {{HostIP: "0.0.0.0", HostPort: "50051"}}, "8100/tcp": nil}
+	agent.NetworkSettings.Networks = map[string]*network.EndpointSettings{"bcs-net": {IPAddress: "10.0.0.2"}, "bridge": {}}
+
+	mockController := new(MockContainerController)
+	mockController.On("ImageList", ctx, image.ListOptions{}).Return([]image.Summary{
+		{ID: "sha256:agent", RepoTags: []string{"agent-image:latest"}},
+		{ID: "sha256:ffmpeg-new", RepoTags: []string{"ffmpeg-image:latest"}},
+		{ID: "sha256:nmos", RepoTags: []string{"nmos-image:latest"}},
+	}, nil)
+	mockController.On("ContainerInspect", ctx, MediaProxyAgentContainerName).Return(agent, nil)
+	mockController.On("ContainerInspect", ctx, MediaProxyContainerName).Return(container.InspectResponse{}, errdefs.NotFound(errors.New("no such container")))
+	mockController.On("ContainerInspect", ctx, "ffmpeg-pipeline-0").Return(inspectResponse(container.State{Status: "exited", ExitCode: 137}, "sha256:ffmpeg-old", 3), nil)
+	mockController.On("ContainerInspect", ctx, "nmos-client-0").Return(inspectResponse(container.State{Status: "running", Running: true, StartedAt: startedAt}, "sha256:nmos", 0), nil)
+	mockController.On("ContainerInspect", ctx, "ffmpeg-pipeline-1").Return(container.InspectResponse{}, errors.New("connection refused"))
+	mockController.On("ContainerInspect", ctx, "nmos-client-1").Return(inspectResponse(container.State{Status: "running", Running: true, StartedAt: startedAt}, "sha256:nmos", 0), nil)
+
+	status, err := StatusContainers(ctx, mockController, log, config)
+	mockController.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Len(t, status.Containers, 6)
+
+	agentStatus := status.Containers[0]
+	assert.Equal(t, "running", agentStatus.State)
+	assert.Equal(t, "healthy", agentStatus.Health)
+	assert.InDelta(t, 90, agentStatus.UptimeSeconds, 5)
+	assert.Equal(t, ImageCurrent, agentStatus.ImageStatus)
+	assert.Equal(t, []string{"0.0.0.0:50051->50051/tcp", "8100/tcp"}, agentStatus.Ports)
+	assert.Equal(t, []NetworkStatus{{Name: "bcs-net", IP: "10.0.0.2"}, {Name: "bridge"}}, agentStatus.Networks)
+	assert.Nil(t, agentStatus.ExitCode)
+
+	assert.Equal(t, StateMissing, status.Containers[1].State)
+
+	pipeline := status.Containers[2]
+	assert.Equal(t, "exited", pipeline.State)
+	assert.Equal(t, 3, pipeline.RestartCount)
+	assert.Equal(t, 137, *pipeline.ExitCode)
+	assert.Equal(t, ImageOutdated, pipeline.ImageStatus)
+	assert.Equal(t, "sha256:ffmpeg-new", pipeline.ConfiguredID)
+
+	assert.Equal(t, "127.0.0.1:"+strconv.Itoa(nodeAPIPort), status.Containers[3].NodeAPIAddress)
+	assert.True(t, *status.Containers[3].NodeAPIAnswers)
+
+	assert.Equal(t, "connection refused", status.Containers[4].Error)
+	assert.False(t, *status.Containers[5].NodeAPIAnswers)
+}
+
+func TestStatusWriteTable(t *testing.T) {
+	exitCode, answers := 137, true
+	status := Status{Containers: []ContainerStatus{
+		{Name: MediaProxyAgentContainerName, Type: "MediaProxyAgent", State: "running", StartedAt: "2024-01-01T00:00:00Z", UptimeSeconds: 3725,
+			Image: "agent-image:latest", ImageStatus: ImageCurrent, Ports: []string{"0.0.0.0:50051->50051/tcp"}, Networks: []NetworkStatus{{Name: "bcs-net", IP: "10.0.0.2"}}},
+		{Name: "ffmpeg-pipeline", Type: "BcsPipelineFfmpeg", State: "exited", RestartCount: 3, Image: "ffmpeg-image:latest", ImageStatus: ImageOutdated, ExitCode: &exitCode},
+		{Name: "nmos-client", Type: "BcsPipelineNmosClient", State: StateMissing, Image: "nmos-image:latest", NodeAPIAddress: "10.0.0.3:5004", NodeAPIAnswers: &answers},
+	}}
+
+	var table bytes.Buffer
+	assert.NoError(t, status.WriteTable(&table))
+	assert.Equal(t, ""+
+		"CONTAINER        TYPE                   STATE    UPTIME  RESTARTS  IMAGE                           PORTS                     NETWORKS          EXIT CODE  NODE API\n"+
+		"mesh-agent       MediaProxyAgent        running  1h2m5s  0         agent-image:latest (current)    0.0.0.0:50051->50051/tcp  bcs-net=10.0.0.2  -          -\n"+
+		"ffmpeg-pipeline  BcsPipelineFfmpeg      exited   -       3         ffmpeg-image:latest (outdated)  -                         -                 137        -\n"+
+		"nmos-client      BcsPipelineNmosClient  missing  -       0         nmos-image:latest               -                         -                 -          10.0.0.3:5004 up\n", table.String())
+}
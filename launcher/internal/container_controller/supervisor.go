@@ -0,0 +1,302 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/go-logr/logr"
+)
+
+// RestartPolicy controls how the Supervisor restarts failed containers.
+type RestartPolicy struct {
+	InitialBackoff time.Duration // delay before the first restart of a failed container
+	MaxBackoff     time.Duration // upper bound of the exponentially growing delay
+	MaxRestarts    int           // restart budget per container, the supervisor gives up once it is spent
+	StableAfter    time.Duration // a container running longer than this gets its backoff and budget reset
+}
+
+// DefaultRestartPolicy returns the policy used by the launcher when no flags override it.
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     5 * time.Minute,
+		MaxRestarts:    5,
+		StableAfter:    10 * time.Minute,
+	}
+}
+
+// resubscribeDelay is the pause between losing the Docker events stream and subscribing again.
+var resubscribeDelay = 5 * time.Second
+
+type supervisedContainer struct {
+	info        general.Containers
+	restarts    int
+	lastRestart time.Time
+	pending     *time.Timer
+	generation  int // bumped for every scheduled restart, so a timer that fired before it was cancelled is told apart
+	givenUp     bool
+}
+
+// dueRestart is sent by the timer of a scheduled restart when it fires.
+type dueRestart struct {
+	name       string
+	generation int
+}
+
+// Supervisor watches the containers declared in the launcher configuration
+// and restarts them when they fail.
+type Supervisor struct {
+	cli        ContainerController
+	log        logr.Logger
+	policy     RestartPolicy
+	containers map[string]*supervisedContainer
+	nmosClient map[string]string // FFmpeg pipeline container name -> paired NMOS client container name
+	due        chan dueRestart
+	done       <-chan struct{} // closed when Run returns, so the timers firing afterwards do not block
+}
+
+func NewSupervisor(cli ContainerController, log logr.Logger, config *parser.Configuration, policy RestartPolicy) *Supervisor {
+	s := &Supervisor{
+		cli:        cli,
+		log:        log,
+		policy:     policy,
+		containers: make(map[string]*supervisedContainer),
+		nmosClient: make(map[string]string),
+	}
+	for _, declared := range declaredContainers(config) {
+		s.containers[declared.ContainerName] = &supervisedContainer{info: declared}
+	}
+	// every container has at most one pending restart, so firing timers never block
+	s.due = make(chan dueRestart, len(s.containers))
+	for _, instance := range config.WorkloadToBeRun {
+		if instance.FfmpegPipeline.Name != "" && instance.NmosClient.Name != "" {
+			s.nmosClient[instance.FfmpegPipeline.Name] = instance.NmosClient.Name
+		}
+	}
+	return s
+}
+
+// Run subscribes to the Docker events stream and supervises the containers until ctx is cancelled.
+// When the events stream breaks (for example because the Docker daemon restarts), the supervisor
+// subscribes again and re-checks the state of every container, so failures that happened
+// in the meantime are not missed.
+func (s *Supervisor) Run(ctx context.Context) error {
+	names := filters.NewArgs(
+		filters.Arg("type", string(events.ContainerEventType)),
+		filters.Arg("event", string(events.ActionDie)),
+		filters.Arg("event", string(events.ActionStop)),
+		filters.Arg("event", string(events.ActionDestroy)),
+	)
+	for name := range s.containers {
+		names.Add("container", name)
+	}
+	s.log.Info("Supervising containers", "count", len(s.containers), "policy", s.policy)
+	s.done = ctx.Done()
+
+	for {
+		eventCtx, cancel := context.WithCancel(ctx)
+		messages, errs := s.cli.Events(eventCtx, events.ListOptions{Filters: names})
+		s.sweep(ctx)
+
+		err := s.watch(ctx, messages, errs)
+		cancel()
+		if ctx.Err() != nil {
+			s.stopPending()
+			return nil
+		}
+		s.log.Error(err, "Docker events stream interrupted. Subscribing again", "delay", resubscribeDelay)
+		select {
+		case <-ctx.Done():
+			s.stopPending()
+			return nil
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
+
+func (s *Supervisor) watch(ctx context.Context, messages <-chan events.Message, errs <-chan error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case msg := <-messages:
+			s.handleEvent(ctx, msg)
+		case due := <-s.due:
+			s.restart(ctx, due)
+		}
+	}
+}
+
+// sweep schedules a restart of every supervised container that has already exited with failure.
+func (s *Supervisor) sweep(ctx context.Context) {
+	for name, c := range s.containers {
+		info, err := s.cli.ContainerInspect(ctx, name)
+		if err != nil {
+			if !client.IsErrNotFound(err) {
+				s.log.Error(err, "Failed to inspect supervised container", "container", name)
+			}
+			continue
+		}
+		if info.State != nil && !info.State.Running && info.State.ExitCode != 0 {
+			s.log.Info("Supervised container is not running", "container", name, "exitCode", info.State.ExitCode)
+			s.schedule(ctx, c)
+		}
+	}
+}
+
+func (s *Supervisor) handleEvent(ctx context.Context, msg events.Message) {
+	name := msg.Actor.Attributes["name"]
+	c, ok := s.containers[name]
+	if !ok {
+		return
+	}
+	switch msg.Action {
+	case events.ActionDie:
+		exitCode := msg.Actor.Attributes["exitCode"]
+		if exitCode == "0" {
+			s.log.Info("Container exited successfully. Not restarting it", "container", name)
+			return
+		}
+		s.log.Info("Container failed", "container", name, "exitCode", exitCode)
+		s.schedule(ctx, c)
+	case events.ActionStop, events.ActionDestroy:
+		// Stopped or removed on purpose (docker stop, launcher teardown, our own restart)
+		if c.pending != nil {
+			s.log.Info("Container stopped on purpose. Cancelling its restart", "container", name, "event", msg.Action)
+			c.pending.Stop()
+			c.pending = nil
+		}
+	}
+}
+
+func (s *Supervisor) schedule(ctx context.Context, c *supervisedContainer) {
+	if c.pending != nil || c.givenUp {
+		return
+	}
+	if !c.lastRestart.IsZero() && time.Since(c.lastRestart) > s.policy.StableAfter {
+		c.restarts = 0
+	}
+	if c.restarts >= s.policy.MaxRestarts {
+		c.givenUp = true
+		s.log.Info("Restart budget exhausted. Container is no longer restarted", "container", c.info.ContainerName, "restarts", c.restarts)
+		s.stopDockerRestarts(ctx, c.info.ContainerName)
+		return
+	}
+	delay := s.backoff(c.restarts)
+	c.generation++
+	due := dueRestart{name: c.info.ContainerName, generation: c.generation}
+	s.log.Info("Scheduling container restart", "container", due.name, "attempt", c.restarts+1, "delay", delay)
+	c.pending = time.AfterFunc(delay, func() { s.fire(due) })
+}
+
+// fire hands a due restart over to Run. A timer cancelled after it fired is followed by the timer of the next
+// restart, so the buffer of due restarts can be full; the restart is dropped once Run has returned.
+func (s *Supervisor) fire(due dueRestart) {
+	select {
+	case s.due <- due:
+	case <-s.done:
+	}
+}
+
+// stopDockerRestarts stops a container given up on when Docker restarts it by its restart policy, so a crash-looping
+// container stays stopped like without the policy.
+func (s *Supervisor) stopDockerRestarts(ctx context.Context, name string) {
+	info, err := s.cli.ContainerInspect(ctx, name)
+	if err != nil || info.ContainerJSONBase == nil || info.HostConfig == nil {
+		return
+	}
+	if info.HostConfig.RestartPolicy.IsNone() {
+		return
+	}
+	if err := s.cli.ContainerStop(ctx, name, container.StopOptions{}); err != nil {
+		s.log.Error(err, "Failed to stop container restarted by Docker", "container", name)
+		return
+	}
+	s.log.Info("Container stopped so that Docker does not restart it either", "container", name, "restartPolicy", info.HostConfig.RestartPolicy.Name)
+}
+
+func (s *Supervisor) backoff(restarts int) time.Duration {
+	delay := s.policy.InitialBackoff
+	for i := 0; i < restarts && delay < s.policy.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.policy.MaxBackoff {
+		delay = s.policy.MaxBackoff
+	}
+	return delay
+}
+
+func (s *Supervisor) restart(ctx context.Context, due dueRestart) {
+	name := due.name
+	c := s.containers[name]
+	if c.pending == nil || due.generation != c.generation {
+		// cancelled while the timer was firing, possibly with another restart scheduled since
+		return
+	}
+	c.pending = nil
+
+	c.restarts++
+	c.lastRestart = time.Now()
+
+	// The Docker restart policy (unless-stopped by default) restarts a failed container on its own,
+	// without a stop event to cancel our restart. Restarting it again would interrupt it twice,
+	// but the restart counts against the budget all the same.
+	info, err := s.cli.ContainerInspect(ctx, name)
+	if err == nil && info.State != nil && info.State.Running {
+		s.log.Info("Container already restarted by Docker", "container", name, "attempt", c.restarts)
+		s.restartNmosClient(ctx, c)
+		return
+	}
+
+	err = s.cli.ContainerRestart(ctx, name, container.StopOptions{})
+	if err != nil {
+		s.log.Error(err, "Failed to restart container", "container", name, "attempt", c.restarts)
+		s.schedule(ctx, c)
+		return
+	}
+	s.log.Info("Container restarted", "container", name, "attempt", c.restarts)
+	s.restartNmosClient(ctx, c)
+}
+
+// restartNmosClient restarts the NMOS client paired with a restarted FFmpeg pipeline. The NMOS client keeps
+// a gRPC connection to its pipeline, so it has to be restarted together with it.
+func (s *Supervisor) restartNmosClient(ctx context.Context, c *supervisedContainer) {
+	if c.info.Type != general.BcsPipelineFfmpeg {
+		return
+	}
+	name := c.info.ContainerName
+	nmosName, ok := s.nmosClient[name]
+	if !ok {
+		return
+	}
+	err := s.cli.ContainerRestart(ctx, nmosName, container.StopOptions{})
+	if err != nil {
+		s.log.Error(err, "Failed to restart NMOS client paired with FFmpeg pipeline", "container", nmosName, "pipeline", name)
+		return
+	}
+	s.log.Info("NMOS client restarted together with its FFmpeg pipeline", "container", nmosName, "pipeline", name)
+}
+
+func (s *Supervisor) stopPending() {
+	for _, c := range s.containers {
+		if c.pending != nil {
+			c.pending.Stop()
+			c.pending = nil
+		}
+	}
+}
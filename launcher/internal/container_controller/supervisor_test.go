@@ -0,0 +1,253 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func dieEvent(name, exitCode string) events.Message {
+	return events.Message{
+		Type:   events.ContainerEventType,
+		Action: events.ActionDie,
+		Actor:  events.Actor{Attributes: map[string]string{"name": name, "exitCode": exitCode}},
+	}
+}
+
+func stopEvent(name string) events.Message {
+	return events.Message{
+		Type:   events.ContainerEventType,
+		Action: events.ActionStop,
+		Actor:  events.Actor{Attributes: map[string]string{"name": name}},
+	}
+}
+
+func newSupervisorMock(messages chan events.Message) *MockContainerController {
+	mockController := new(MockContainerController)
+	var messagesOut <-chan events.Message = messages
+	var errsOut <-chan error = make(chan error)
+	mockController.On("Events", mock.Anything, mock.Anything).Return(messagesOut, errsOut)
+	mockController.On("ContainerInspect", mock.Anything, mock.Anything).Return(runningState(false), nil)
+	return mockController
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	s := NewSupervisor(nil, logr.Discard(), teardownTestConfig(), RestartPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second})
+
+	assert.Equal(t, 1*time.Second, s.backoff(0))
+	assert.Equal(t, 2*time.Second, s.backoff(1))
+	assert.Equal(t, 8*time.Second, s.backoff(3))
+	assert.Equal(t, 10*time.Second, s.backoff(4))
+	assert.Equal(t, 10*time.Second, s.backoff(20))
+}
+
+func TestSupervisorRun(t *testing.T) {
+	log := logr.Discard()
+	policy := RestartPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, MaxRestarts: 3, StableAfter: time.Hour}
+
+	t.Run("Restarts failed FFmpeg pipeline together with its NMOS client", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		messages := make(chan events.Message)
+		mockController := newSupervisorMock(messages)
+		restarted := make(chan string, 4)
+		mockController.On("ContainerRestart", mock.Anything, mock.Anything, container.StopOptions{}).
+			Run(func(args mock.Arguments) { restarted <- args.String(1) }).Return(nil)
+
+		done := make(chan error)
+		go func() { done <- NewSupervisor(mockController, log, teardownTestConfig(), policy).Run(ctx) }()
+
+		messages <- dieEvent("ffmpeg-pipeline", "139")
+		assert.Equal(t, "ffmpeg-pipeline", <-restarted)
+		assert.Equal(t, "nmos-client", <-restarted)
+
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("Does not restart again a pipeline already restarted by Docker", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		messages := make(chan events.Message)
+		mockController := new(MockContainerController)
+		var messagesOut <-chan events.Message = messages
+		var errsOut <-chan error = make(chan error)
+		mockController.On("Events", mock.Anything, mock.Anything).Return(messagesOut, errsOut)
+		mockController.On("ContainerInspect", mock.Anything, mock.Anything).Return(runningState(true), nil)
+		restarted := make(chan string, 4)
+		mockController.On("ContainerRestart", mock.Anything, mock.Anything, container.StopOptions{}).
+			Run(func(args mock.Arguments) { restarted <- args.String(1) }).Return(nil)
+
+		done := make(chan error)
+		go func() { done <- NewSupervisor(mockController, log, teardownTestConfig(), policy).Run(ctx) }()
+
+		// unless-stopped: Docker restarts the pipeline after die, no stop event follows
+		messages <- dieEvent("ffmpeg-pipeline", "139")
+		assert.Equal(t, "nmos-client", <-restarted)
+		time.Sleep(50 * time.Millisecond)
+
+		cancel()
+		assert.NoError(t, <-done)
+		mockController.AssertNumberOfCalls(t, "ContainerRestart", 1)
+		mockController.AssertNotCalled(t, "ContainerRestart", mock.Anything, "ffmpeg-pipeline", mock.Anything)
+	})
+
+	t.Run("Counts restarts by Docker against the budget and stops the container once it is spent", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		messages := make(chan events.Message)
+		mockController := new(MockContainerController)
+		var messagesOut <-chan events.Message = messages
+		var errsOut <-chan error = make(chan error)
+		mockController.On("Events", mock.Anything, mock.Anything).Return(messagesOut, errsOut)
+		restartedByDocker := runningState(true)
+		restartedByDocker.HostConfig = &container.HostConfig{RestartPolicy: container.RestartPolicy{Name: container.RestartPolicyUnlessStopped}}
+		inspected := make(chan struct{}, 8)
+		mockController.On("ContainerInspect", mock.Anything, MediaProxyAgentContainerName).
+			Run(func(mock.Arguments) { inspected <- struct{}{} }).Return(restartedByDocker, nil)
+		mockController.On("ContainerInspect", mock.Anything, mock.Anything).Return(runningState(false), nil)
+		stopped := make(chan string, 1)
+		mockController.On("ContainerStop", mock.Anything, MediaProxyAgentContainerName, container.StopOptions{}).
+			Run(func(args mock.Arguments) { stopped <- args.String(1) }).Return(nil)
+		budgetPolicy := policy
+		budgetPolicy.MaxRestarts = 2
+
+		done := make(chan error)
+		go func() { done <- NewSupervisor(mockController, log, teardownTestConfig(), budgetPolicy).Run(ctx) }()
+
+		<-inspected // sweep
+		for i := 0; i < 2; i++ {
+			messages <- dieEvent(MediaProxyAgentContainerName, "1")
+			<-inspected
+		}
+		messages <- dieEvent(MediaProxyAgentContainerName, "1")
+		assert.Equal(t, MediaProxyAgentContainerName, <-stopped, "a crash-looping container is given up on")
+
+		cancel()
+		assert.NoError(t, <-done)
+		mockController.AssertNotCalled(t, "ContainerRestart", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Does not restart containers that exited successfully or were stopped on purpose", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		messages := make(chan events.Message)
+		mockController := newSupervisorMock(messages)
+		slowPolicy := policy
+		slowPolicy.InitialBackoff = 50 * time.Millisecond
+
+		done := make(chan error)
+		go func() { done <- NewSupervisor(mockController, log, teardownTestConfig(), slowPolicy).Run(ctx) }()
+
+		messages <- dieEvent("nmos-client", "0")
+		messages <- dieEvent(MediaProxyContainerName, "143")
+		messages <- stopEvent(MediaProxyContainerName)
+		time.Sleep(100 * time.Millisecond)
+
+		cancel()
+		assert.NoError(t, <-done)
+		mockController.AssertNotCalled(t, "ContainerRestart", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Gives up once the restart budget is spent", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		messages := make(chan events.Message)
+		mockController := newSupervisorMock(messages)
+		restarted := make(chan string, 8)
+		mockController.On("ContainerRestart", mock.Anything, MediaProxyAgentContainerName, container.StopOptions{}).
+			Run(func(args mock.Arguments) { restarted <- args.String(1) }).Return(nil)
+		budgetPolicy := policy
+		budgetPolicy.MaxRestarts = 2
+
+		done := make(chan error)
+		go func() { done <- NewSupervisor(mockController, log, teardownTestConfig(), budgetPolicy).Run(ctx) }()
+
+		for i := 0; i < 2; i++ {
+			messages <- dieEvent(MediaProxyAgentContainerName, "1")
+			<-restarted
+		}
+		messages <- dieEvent(MediaProxyAgentContainerName, "1")
+		time.Sleep(50 * time.Millisecond)
+
+		cancel()
+		assert.NoError(t, <-done)
+		mockController.AssertNumberOfCalls(t, "ContainerRestart", 2)
+	})
+}
+
+func TestSupervisorIgnoresStaleRestart(t *testing.T) {
+	ctx := context.Background()
+	mockController := new(MockContainerController)
+	mockController.On("ContainerInspect", ctx, MediaProxyAgentContainerName).Return(runningState(false), nil)
+	mockController.On("ContainerRestart", ctx, MediaProxyAgentContainerName, container.StopOptions{}).Return(nil)
+	s := NewSupervisor(mockController, logr.Discard(), teardownTestConfig(), RestartPolicy{InitialBackoff: time.Hour, MaxBackoff: time.Hour, MaxRestarts: 3})
+	c := s.containers[MediaProxyAgentContainerName]
+
+	// the timer fires and its restart is queued, then the container is stopped and fails again
+	s.schedule(ctx, c)
+	stale := dueRestart{name: MediaProxyAgentContainerName, generation: c.generation}
+	s.handleEvent(ctx, stopEvent(MediaProxyAgentContainerName))
+	s.handleEvent(ctx, dieEvent(MediaProxyAgentContainerName, "1"))
+	defer s.stopPending()
+
+	s.restart(ctx, stale)
+	mockController.AssertNotCalled(t, "ContainerRestart", mock.Anything, mock.Anything, mock.Anything)
+	assert.NotNil(t, c.pending, "the restart scheduled after the stale one keeps its backoff")
+
+	s.restart(ctx, dueRestart{name: MediaProxyAgentContainerName, generation: c.generation})
+	mockController.AssertNumberOfCalls(t, "ContainerRestart", 1)
+	assert.Equal(t, 1, c.restarts)
+}
+
+func TestSupervisorFireAfterRun(t *testing.T) {
+	s := NewSupervisor(nil, logr.Discard(), teardownTestConfig(), DefaultRestartPolicy())
+	for i := 0; i < cap(s.due); i++ {
+		s.due <- dueRestart{name: MediaProxyAgentContainerName}
+	}
+	done := make(chan struct{})
+	s.done = done
+
+	fired := make(chan struct{})
+	go func() { s.fire(dueRestart{name: MediaProxyAgentContainerName, generation: 1}); close(fired) }()
+	close(done)
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("a timer firing after Run returned blocks on the full buffer of due restarts")
+	}
+}
+
+func TestSupervisorCancelWithPendingRestarts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := make(chan events.Message)
+	mockController := newSupervisorMock(messages)
+	s := NewSupervisor(mockController, logr.Discard(), teardownTestConfig(),
+		RestartPolicy{InitialBackoff: 20 * time.Millisecond, MaxBackoff: time.Second, MaxRestarts: 3, StableAfter: time.Hour})
+
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+	messages <- dieEvent(MediaProxyAgentContainerName, "1")
+	messages <- dieEvent("ffmpeg-pipeline", "1")
+	cancel()
+	assert.NoError(t, <-done)
+
+	// the timers fire after Run returned, or were stopped by it
+	time.Sleep(50 * time.Millisecond)
+	mockController.AssertNotCalled(t, "ContainerRestart", mock.Anything, mock.Anything, mock.Anything)
+	for _, c := range s.containers {
+		assert.Nil(t, c.pending)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/go-logr/logr"
+)
+
+// DefaultStopTimeout is the time a container gets to exit gracefully before Docker kills it.
+const DefaultStopTimeout = 10 * time.Second
+
+// TeardownReport summarizes what StopAndRemoveContainers did with each declared container.
+type TeardownReport struct {
+	Stopped        []string // containers that were running and have been stopped gracefully
+	AlreadyStopped []string // containers that existed but were not running
+	Removed        []string // containers that have been removed (stopped + already stopped)
+	Missing        []string // containers declared in the configuration that do not exist
+}
+
+// StopAndRemoveContainers tears down every container declared in the launcher configuration.
+// Containers are handled in reverse dependency order: NMOS clients and FFmpeg pipelines first
+// (last workload first), then MCM MediaProxy and finally MCM MediaProxy Agent.
+// Running containers get stopTimeout to exit before they are killed.
+//
+// Teardown does not stop on the first failure. Every declared container is processed
+// and all errors are returned joined together with the report of what has been done.
+func StopAndRemoveContainers(ctx context.Context, cli ContainerController, log logr.Logger, config *parser.Configuration, stopTimeout time.Duration) (TeardownReport, error) {
+	report := TeardownReport{}
+	var errs []error
+
+	declared := declaredContainers(config)
+	for i := len(declared) - 1; i >= 0; i-- {
+		if err := stopAndRemoveContainer(ctx, cli, log, declared[i].ContainerName, stopTimeout, &report); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	log.Info("Teardown finished", "removed", strings.Join(report.Removed, ","),
+		"alreadyStopped", strings.Join(report.AlreadyStopped, ","), "missing", strings.Join(report.Missing, ","))
+	return report, errors.Join(errs...)
+}
+
+// stopAndRemoveContainer stops the container if it is running, removes it and records the outcome in the report.
+func stopAndRemoveContainer(ctx context.Context, cli ContainerController, log logr.Logger, name string, stopTimeout time.Duration, report *TeardownReport) error {
+	info, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			log.Info("Container does not exist. Nothing to tear down", "container", name)
+			report.Missing = append(report.Missing, name)
+			return nil
+		}
+		log.Error(err, "Failed to inspect container", "container", name)
+		return fmt.Errorf("inspect %s: %w", name, err)
+	}
+
+	if info.State != nil && info.State.Running {
+		log.Info("Stopping container", "container", name, "timeout", stopTimeout)
+		timeoutSeconds := int(stopTimeout.Seconds())
+		err = cli.ContainerStop(ctx, name, container.StopOptions{Timeout: &timeoutSeconds})
+		if err != nil {
+			log.Error(err, "Failed to stop container", "container", name)
+			return fmt.Errorf("stop %s: %w", name, err)
+		}
+		report.Stopped = append(report.Stopped, name)
+	} else {
+		log.Info("Container is already stopped", "container", name)
+		report.AlreadyStopped = append(report.AlreadyStopped, name)
+	}
+
+	err = removeContainer(ctx, cli, name)
+	if err != nil {
+		log.Error(err, "Failed to remove container", "container", name)
+		return fmt.Errorf("remove %s: %w", name, err)
+	}
+	report.Removed = append(report.Removed, name)
+	return nil
+}
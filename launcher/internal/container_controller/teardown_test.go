@@ -0,0 +1,104 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func teardownTestConfig() *parser.Configuration {
+	return &parser.Configuration{
+		RunOnce: parser.RunOnce{
+			MediaProxyAgent: workloads.MediaProxyAgentConfig{ImageAndTag: "agent-image:latest"},
+			MediaProxyMcm:   workloads.MediaProxyMcmInstances{{ImageAndTag: "mcm-image:latest"}},
+		},
+		WorkloadToBeRun: []workloads.WorkloadConfig{
+			{
+				FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: "ffmpeg-pipeline", ImageAndTag: "ffmpeg-image:latest"},
+				NmosClient:     workloads.NmosClientConfig{Name: "nmos-client", ImageAndTag: "nmos-image:latest"},
+			},
+		},
+	}
+}
+
+func runningState(running bool) container.InspectResponse {
+	return container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{State: &container.State{Running: running}}}
+}
+
+func TestDeclaredContainers(t *testing.T) {
+	declared := declaredContainers(teardownTestConfig())
+
+	names := []string{}
+	for _, c := range declared {
+		names = append(names, c.ContainerName)
+	}
+	assert.Equal(t, []string{MediaProxyAgentContainerName, MediaProxyContainerName, "ffmpeg-pipeline", "nmos-client"}, names)
+	assert.Equal(t, 0, declared[2].Id)
+	assert.Equal(t, 0, declared[3].Id)
+}
+
+func TestStopAndRemoveContainers(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+	timeout := 5 * time.Second
+	timeoutSeconds := 5
+
+	t.Run("Stops and removes containers in reverse dependency order", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		var order []string
+		record := func(args mock.Arguments) { order = append(order, args.String(1)) }
+
+		mockController.On("ContainerInspect", ctx, "nmos-client").Return(runningState(true), nil)
+		mockController.On("ContainerInspect", ctx, "ffmpeg-pipeline").Return(runningState(false), nil)
+		mockController.On("ContainerInspect", ctx, MediaProxyContainerName).Return(container.InspectResponse{}, errdefs.NotFound(errors.New("no such container")))
+		mockController.On("ContainerInspect", ctx, MediaProxyAgentContainerName).Return(runningState(true), nil)
+		mockController.On("ContainerStop", ctx, mock.Anything, container.StopOptions{Timeout: &timeoutSeconds}).Return(nil).Times(2)
+		mockController.On("ContainerRemove", ctx, mock.Anything, container.RemoveOptions{Force: true}).Run(record).Return(nil).Times(3)
+
+		report, err := StopAndRemoveContainers(ctx, mockController, log, teardownTestConfig(), timeout)
+		mockController.AssertExpectations(t)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"nmos-client", "ffmpeg-pipeline", MediaProxyAgentContainerName}, order)
+		assert.Equal(t, []string{"nmos-client", MediaProxyAgentContainerName}, report.Stopped)
+		assert.Equal(t, []string{"ffmpeg-pipeline"}, report.AlreadyStopped)
+		assert.Equal(t, []string{MediaProxyContainerName}, report.Missing)
+		assert.Equal(t, order, report.Removed)
+	})
+
+	t.Run("Continues teardown and reports all errors", func(t *testing.T) {
+		mockController := new(MockContainerController)
+		stopErr := errors.New("failed to stop container")
+		inspectErr := errors.New("daemon not reachable")
+
+		mockController.On("ContainerInspect", ctx, "nmos-client").Return(runningState(true), nil)
+		mockController.On("ContainerInspect", ctx, "ffmpeg-pipeline").Return(container.InspectResponse{}, inspectErr)
+		mockController.On("ContainerInspect", ctx, MediaProxyContainerName).Return(runningState(false), nil)
+		mockController.On("ContainerInspect", ctx, MediaProxyAgentContainerName).Return(runningState(false), nil)
+		mockController.On("ContainerStop", ctx, "nmos-client", mock.Anything).Return(stopErr)
+		mockController.On("ContainerRemove", ctx, mock.Anything, container.RemoveOptions{Force: true}).Return(nil).Times(2)
+
+		report, err := StopAndRemoveContainers(ctx, mockController, log, teardownTestConfig(), timeout)
+		mockController.AssertExpectations(t)
+
+		assert.ErrorIs(t, err, stopErr)
+		assert.ErrorIs(t, err, inspectErr)
+		assert.Equal(t, []string{MediaProxyContainerName, MediaProxyAgentContainerName}, report.Removed)
+		assert.Empty(t, report.Stopped)
+	})
+}
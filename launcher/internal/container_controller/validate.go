@@ -0,0 +1,204 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/resources/nmos"
+	"bcs.pod.launcher.intel/resources_library/utils"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+	"github.com/go-logr/logr"
+)
+
+// ValidateOptions selects the checks of ValidateConfiguration that look at the host.
+type ValidateOptions struct {
+	HostPaths bool   // check that the NMOS json files and the sources of the volumes and devices exist
+	HostRoot  string // root the host paths are looked up in, / when empty
+}
+
+// ValidateConfiguration checks the launcher configuration for conflicts before any container is touched: the settings
+// of the containers, the custom networks and their static IPs, the container names and the host ports the containers
+// listen on. With opts.HostPaths it also checks the files and directories of the host the containers mount.
+// It needs no container runtime and returns all problems found at once.
+func ValidateConfiguration(config *parser.Configuration, opts ValidateOptions) error {
+	var errs []error
+	for _, instance := range config.WorkloadToBeRun {
+		if IsEmptyStruct(instance.FfmpegPipeline) || IsEmptyStruct(instance.NmosClient) {
+			errs = append(errs, fmt.Errorf("no information about BCS pipeline provided. Either FfmpegPipeline or NmosClient is empty for instance Ffmpeg: %s; Nmos: %s", instance.FfmpegPipeline.Name, instance.NmosClient.Name))
+		}
+	}
+	if err := validateContainerSettings(config); err != nil {
+		errs = append(errs, err)
+	}
+	if requests, err := requestedNetworks(config); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, request := range requests {
+			if err := request.validate(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	errs = append(errs, validateContainerNames(config)...)
+	errs = append(errs, validateHostPorts(config)...)
+	if opts.HostPaths {
+		errs = append(errs, validateHostPaths(config, opts.HostRoot)...)
+	}
+	return errors.Join(errs...)
+}
+
+// containerSource returns the key of the launcher configuration a declared container is defined under.
+func containerSource(containerInfo general.Containers) string {
+	switch containerInfo.Type {
+	case general.MediaProxyAgent:
+		return "runOnce.mediaProxyAgent"
+	case general.MediaProxyMCM:
+		return fmt.Sprintf("runOnce.mediaProxyMcm[%d]", containerInfo.Id)
+	case general.BcsPipelineFfmpeg:
+		return fmt.Sprintf("workloadToBeRun[%d].ffmpegPipeline", containerInfo.Id)
+	case general.BcsPipelineNmosClient:
+		return fmt.Sprintf("workloadToBeRun[%d].nmosClient", containerInfo.Id)
+	}
+	return containerInfo.ContainerName
+}
+
+// validateContainerNames reports containers without a name and containers sharing one. Duplicate names of
+// MCM Media Proxy instances are reported by utils.ValidateMediaProxies.
+func validateContainerNames(config *parser.Configuration) []error {
+	var errs []error
+	seen := map[string]general.Containers{}
+	for _, containerInfo := range declaredContainers(config) {
+		if containerInfo.ContainerName == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", containerSource(containerInfo)))
+			continue
+		}
+		other, taken := seen[containerInfo.ContainerName]
+		if !taken {
+			seen[containerInfo.ContainerName] = containerInfo
+		} else if other.Type != general.MediaProxyMCM || containerInfo.Type != general.MediaProxyMCM {
+			errs = append(errs, fmt.Errorf("container name %s is used by %s and %s", containerInfo.ContainerName, containerSource(other), containerSource(containerInfo)))
+		}
+	}
+	return errs
+}
+
+// hostPorts returns the ports a container occupies on the host, e.g. 50051/tcp: the published ports and,
+// in the host network, the ports it listens on. Ports that are not set (0) are left to Docker and skipped.
+func hostPorts(containerInfo general.Containers, config *parser.Configuration, spec ContainerSpec) []string {
+	ports := map[nat.Port]bool{}
+	for port, bindings := range spec.HostConfig.PortBindings {
+		for _, binding := range bindings {
+			ports[nat.Port(binding.HostPort+"/"+port.Proto())] = true
+		}
+	}
+	if spec.HostConfig.NetworkMode.IsHost() {
+		for port := range spec.Config.ExposedPorts {
+			ports[port] = true
+		}
+		if containerInfo.Type == general.MediaProxyMCM {
+			ports[nat.Port(utils.MediaProxySDKPort(config.RunOnce.MediaProxyMcm[containerInfo.Id])+"/tcp")] = true
+		}
+	}
+	sorted := make([]string, 0, len(ports))
+	for port := range ports {
+		if port.Int() > 0 {
+			sorted = append(sorted, string(port))
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// validateHostPorts reports host ports used by more than one container, e.g. two workloads with the same gRPCPort
+// or nmosPort. Published ports share the host ports whatever network the containers are attached to.
+func validateHostPorts(config *parser.Configuration) []error {
+	var errs []error
+	usedBy := map[string]string{}
+	for _, containerInfo := range declaredContainers(config) {
+		spec, err := newContainerSpec(utils.PreviewContainerConfig(&containerInfo, config, logr.Discard()))
+		if err != nil {
+			continue
+		}
+		for _, port := range hostPorts(containerInfo, config, spec) {
+			if other, taken := usedBy[port]; taken {
+				errs = append(errs, fmt.Errorf("host port %s is used by containers %s and %s", port, other, containerInfo.ContainerName))
+				continue
+			}
+			usedBy[port] = containerInfo.ContainerName
+		}
+	}
+	return errs
+}
+
+// validateHostPaths reports NMOS json files that are missing or do not match the schema of nmos.Config, and sources of bind mounts and devices
+// that do not exist under root. The NMOS configuration directory is reported through its json file.
+func validateHostPaths(config *parser.Configuration, root string) []error {
+	var errs []error
+	missing := func(path string) bool {
+		_, err := os.Stat(filepath.Join(root, path))
+		return err != nil
+	}
+	for _, containerInfo := range declaredContainers(config) {
+		source := containerSource(containerInfo)
+		if containerInfo.Type == general.BcsPipelineNmosClient {
+			path := utils.NmosJsonFilePath(config, containerInfo.Id)
+			data, err := os.ReadFile(filepath.Join(root, path))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: NMOS json file %s does not exist", source, path))
+			} else if err := parser.UnmarshalStrictJSON(path, data, &nmos.Config{}); err != nil {
+				var configErrors parser.ConfigErrors
+				if !errors.As(err, &configErrors) {
+					errs = append(errs, fmt.Errorf("%s: NMOS json file %s cannot be parsed: %w", source, path, err))
+				}
+				for _, configError := range configErrors {
+					errs = append(errs, fmt.Errorf("%s: NMOS json file %w", source, configError))
+				}
+			}
+			continue
+		}
+
+		_, hostConfig, _ := utils.PreviewContainerConfig(&containerInfo, config, logr.Discard())
+		if hostConfig == nil {
+			continue
+		}
+		for _, m := range hostConfig.Mounts {
+			if m.Type != mount.TypeBind {
+				continue
+			}
+			if m.Source == "" {
+				errs = append(errs, fmt.Errorf("%s: source of volume %s is not set", source, m.Target))
+			} else if missing(m.Source) {
+				errs = append(errs, fmt.Errorf("%s: source %s of volume %s does not exist", source, m.Source, m.Target))
+			}
+		}
+		for _, bind := range hostConfig.Binds {
+			hostPath, target, _ := strings.Cut(bind, ":")
+			target, _, _ = strings.Cut(target, ":")
+			if strings.HasPrefix(hostPath, "/") && missing(hostPath) {
+				errs = append(errs, fmt.Errorf("%s: source %s of volume %s does not exist", source, hostPath, target))
+			}
+		}
+		for _, device := range hostConfig.Devices {
+			if device.PathOnHost == "" {
+				errs = append(errs, fmt.Errorf("%s: device %s is not set", source, device.PathInContainer))
+			} else if missing(device.PathOnHost) {
+				errs = append(errs, fmt.Errorf("%s: device %s does not exist", source, device.PathOnHost))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,74 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package containercontroller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfiguration(t *testing.T) {
+	t.Run("Accepts a configuration without conflicts", func(t *testing.T) {
+		assert.NoError(t, ValidateConfiguration(planTestConfig(t.TempDir()), ValidateOptions{}))
+	})
+
+	t.Run("Reports all conflicts at once", func(t *testing.T) {
+		config := planTestConfig(t.TempDir())
+		second := config.WorkloadToBeRun[0]
+		second.FfmpegPipeline.Name = "ffmpeg-pipeline-2"
+		second.NmosClient.Name = "ffmpeg-pipeline"
+		config.WorkloadToBeRun = append(config.WorkloadToBeRun, second)
+		config.RunOnce.MediaProxyMcm = append(config.RunOnce.MediaProxyMcm, workloads.MediaProxyMcmConfig{Name: "media-proxy-nic1", ImageAndTag: "mcm-image:latest"})
+
+		err := ValidateConfiguration(config, ValidateOptions{})
+		assert.EqualError(t, err, `IP 10.0.0.2 in network bcs-net is requested by containers ffmpeg-pipeline and ffmpeg-pipeline-2
+container name ffmpeg-pipeline is used by workloadToBeRun[0].ffmpegPipeline and workloadToBeRun[1].nmosClient
+host port 8002/tcp is used by containers media-proxy and media-proxy-nic1
+host port 50055/tcp is used by containers ffmpeg-pipeline and ffmpeg-pipeline-2
+host port 5004/tcp is used by containers nmos-client and ffmpeg-pipeline`)
+	})
+
+	t.Run("Distinct SDK ports separate MCM Media Proxy instances in the host network", func(t *testing.T) {
+		config := planTestConfig(t.TempDir())
+		config.RunOnce.MediaProxyMcm = append(config.RunOnce.MediaProxyMcm, workloads.MediaProxyMcmConfig{Name: "media-proxy-nic1", ImageAndTag: "mcm-image:latest", SDKPort: "8003"})
+		assert.NoError(t, ValidateConfiguration(config, ValidateOptions{}))
+	})
+
+	t.Run("Checks the host paths under the host root", func(t *testing.T) {
+		root := t.TempDir()
+		config := planTestConfig("/etc/bcs/nmos")
+		config.RunOnce.MediaProxyMcm[0].Volumes = []string{"/dev/vfio:/dev/vfio", "memif:/run/mcm"}
+		config.WorkloadToBeRun[0].FfmpegPipeline.Volumes = workloads.Volumes{Videos: "/videos", Dri: "/dri", Kahawai: "/tmp/kahawai", Devnull: "/dev/null",
+			TmpHugepages: "/tmp/hugepages", Hugepages: "/hugepages", Imtl: "/var/run/imtl"}
+		config.WorkloadToBeRun[0].FfmpegPipeline.Devices = workloads.Devices{Vfio: "/dev/vfio", Dri: "/dev/dri"}
+		for _, dir := range []string{"/videos", "/dri", "/tmp/hugepages", "/hugepages", "/var/run/imtl", "/dev/vfio", "/etc/bcs/nmos"} {
+			assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+		}
+		for _, file := range []string{"/tmp/kahawai", "/dev/null"} {
+			assert.NoError(t, os.WriteFile(filepath.Join(root, file), nil, 0644))
+		}
+		assert.NoError(t, os.WriteFile(filepath.Join(root, "/etc/bcs/nmos/nmos.json"), []byte(`{"logging_level": `), 0644))
+
+		assert.NoError(t, ValidateConfiguration(config, ValidateOptions{}), "host paths are not checked by default")
+		err := ValidateConfiguration(config, ValidateOptions{HostPaths: true, HostRoot: root})
+		assert.EqualError(t, err, `workloadToBeRun[0].ffmpegPipeline: source of volume /dev/shm is not set
+workloadToBeRun[0].ffmpegPipeline: device /dev/dri does not exist
+workloadToBeRun[0].nmosClient: NMOS json file /etc/bcs/nmos/nmos.json cannot be parsed: unexpected end of JSON input`)
+
+		assert.NoError(t, os.WriteFile(filepath.Join(root, "/etc/bcs/nmos/nmos.json"), []byte(`{"function": "tx", "sender": [{"stream_type": {"st2110": {"transport": "st2110-21"}}}]}`), 0644))
+		err = ValidateConfiguration(config, ValidateOptions{HostPaths: true, HostRoot: root})
+		assert.ErrorContains(t, err, `workloadToBeRun[0].nmosClient: NMOS json file /etc/bcs/nmos/nmos.json:1:72: sender[0].stream_type.st2110.transport: expected one of "st2110-20", "st2110-22", "st2110-30", got "st2110-21"`)
+
+		assert.NoError(t, os.Remove(filepath.Join(root, "/etc/bcs/nmos/nmos.json")))
+		err = ValidateConfiguration(config, ValidateOptions{HostPaths: true, HostRoot: root})
+		assert.ErrorContains(t, err, "workloadToBeRun[0].nmosClient: NMOS json file /etc/bcs/nmos/nmos.json does not exist")
+	})
+}
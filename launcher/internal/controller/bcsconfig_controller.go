@@ -231,6 +231,10 @@ func (r *BcsConfigReconciler) reconcileConfigMap(ctx context.Context, bcs *bcsv1
 }
 
 func (r *BcsConfigReconciler) reconcileDeployment(ctx context.Context, bcs *bcsv1.BcsConfigSpec, log logr.Logger) error {
+	if err := utils.ValidateBcsDevices(bcs); err != nil {
+		log.Error(err, "Invalid devices of BcsConfig, the Deployment is not created")
+		return err
+	}
 	bcsDeployment := &appsv1.Deployment{}
 	err := r.Get(ctx, types.NamespacedName{Name: bcs.Name, Namespace: bcs.Namespace}, bcsDeployment)
 	if errors.IsNotFound(err) {
@@ -76,12 +76,13 @@ definition:
     image: "mcm/mesh-agent:latest"
     restPort: 8100
     grpcPort: 50051
-    requests:
-      cpu: "500m"
-      memory: "256Mi"
-    limits:
-      cpu: "1000m"
-      memory: "512Mi"
+    resources:
+      requests:
+        cpu: "500m"
+        memory: "256Mi"
+      limits:
+        cpu: "1000m"
+        memory: "512Mi"
     scheduleOnNode: ["node-role.kubernetes.io/worker=true"]
   mediaProxy:
     image: mcm/media-proxy:latest
@@ -89,16 +90,17 @@ definition:
     args: ["-d", "0000:ca:11.0", "-i", $(POD_IP)]
     grpcPort: 8001
     sdkPort: 8002
-    requests:
-      cpu: "2"
-      memory: "8Gi"
-      hugepages-1Gi: "1Gi"
-      hugepages-2Mi: "2Gi"
-    limits:
-      cpu: "2"
-      memory: "8Gi"
-      hugepages-1Gi: "1Gi"
-      hugepages-2Mi: "2Gi"
+    resources:
+      requests:
+        cpu: "2"
+        memory: "8Gi"
+        hugepages-1Gi: "1Gi"
+        hugepages-2Mi: "2Gi"
+      limits:
+        cpu: "2"
+        memory: "8Gi"
+        hugepages-1Gi: "1Gi"
+        hugepages-2Mi: "2Gi"
     volumes:
       memif: /tmp/mcm/memif
       vfio: /dev/vfio
@@ -111,12 +113,13 @@ definition:
     scheduleOnNode: ["node-role.kubernetes.io/worker=true"]
   mtlManager:
     image: mtl-manager:latest
-    requests:
-      cpu: "500m"
-      memory: "256Mi"
-    limits:
-      cpu: "1000m"
-      memory: "512Mi"
+    resources:
+      requests:
+        cpu: "500m"
+        memory: "256Mi"
+      limits:
+        cpu: "1000m"
+        memory: "512Mi"
     volumes:
       imtlHostPath: /var/run/imtl
       bpfPath: /sys/fs/bpf
@@ -0,0 +1,128 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// A reference in a value of a configuration file is replaced by:
+//
+//	${VAR}            the value of the environment variable VAR, an error when VAR is not set
+//	${VAR:-default}   the value of VAR, default when VAR is not set or empty
+//	${file:path}      the content of the file, e.g. a secret in /run/secrets, without its trailing newline.
+//	                  A relative path is relative to the directory of the configuration file.
+//	$$                a single $
+//
+// Only values are resolved, keys are taken as they are. A value consisting of a single reference takes the type of
+// the field it sets, e.g. gRPCPort: "${GRPC_PORT}" is an integer.
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// HasReferences tells whether a configuration file references environment variables or secret files.
+func HasReferences(data []byte) bool {
+	return strings.Contains(strings.ReplaceAll(string(data), "$$", ""), "${")
+}
+
+// interpolate replaces the references of a scalar. A value of a field not accepting strings that consisted of a
+// single reference is resolved to its own type, other values stay strings. It returns false when a reference
+// cannot be resolved. With redact the strings keep their references.
+func (c *strictChecker) interpolate(node *yamlv3.Node, schema *Schema, path string) bool {
+	if c.interpolated[node] || !strings.Contains(node.Value, "$") {
+		return true
+	}
+	c.interpolated[node] = true
+	value, single, ok := c.resolve(node, path)
+	if !ok || value == node.Value {
+		return ok
+	}
+	if single && len(schema.Type) > 0 && !schema.Type.has("string") {
+		node.Value, node.Tag, node.Style = value, "", 0
+	} else if !c.redact {
+		node.Value, node.Tag = value, "!!str"
+	}
+	return true
+}
+
+// resolve returns the value with its references replaced and whether it consisted of a single reference.
+func (c *strictChecker) resolve(node *yamlv3.Node, path string) (string, bool, bool) {
+	var b strings.Builder
+	rest, references, literal, ok := node.Value, 0, false, true
+	for rest != "" {
+		i := strings.Index(rest, "$")
+		if i < 0 || i == len(rest)-1 || rest[i+1] != '$' && rest[i+1] != '{' {
+			end := len(rest)
+			if i >= 0 {
+				end = i + 1
+			}
+			b.WriteString(rest[:end])
+			rest, literal = rest[end:], true
+			continue
+		}
+		if i > 0 || rest[i+1] == '$' {
+			b.WriteString(rest[:i])
+			literal = true
+		}
+		if rest[i+1] == '$' {
+			b.WriteByte('$')
+			rest = rest[i+2:]
+			continue
+		}
+		end := strings.Index(rest[i:], "}")
+		if end < 0 {
+			c.failReference(node, path, "reference %q is not closed with }", rest[i:])
+			return "", false, false
+		}
+		reference := rest[i+2 : i+end]
+		rest = rest[i+end+1:]
+		references++
+		value, err := c.lookup(reference)
+		if err != nil {
+			c.failReference(node, path, "%v", err)
+			ok = false
+			continue
+		}
+		b.WriteString(value)
+	}
+	return b.String(), references == 1 && !literal, ok
+}
+
+// lookup returns the value of a reference without its ${ and }.
+func (c *strictChecker) lookup(reference string) (string, error) {
+	if path, ok := strings.CutPrefix(reference, "file:"); ok {
+		if path == "" {
+			return "", fmt.Errorf("invalid reference ${%s}, the path of the secret file is missing", reference)
+		}
+		if !filepath.IsAbs(path) && c.file != "" {
+			path = filepath.Join(filepath.Dir(c.file), path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("secret file %s cannot be read: %w", path, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	name, fallback, hasDefault := strings.Cut(reference, ":-")
+	if !variableName.MatchString(name) {
+		return "", fmt.Errorf("invalid reference ${%s}, use ${VAR}, ${VAR:-default} or ${file:path}", reference)
+	}
+	value, set := os.LookupEnv(name)
+	if hasDefault && value == "" {
+		return fallback, nil
+	}
+	if !set {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
@@ -0,0 +1,145 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+func TestParseLauncherConfiguration_References(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "secrets"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "secrets", "registry-password"), []byte("s3cret: {x}\n"), 0600))
+	t.Setenv("VFIO_PORT_TX", "0000:ca:11.0")
+	t.Setenv("GRPC_PORT", "50088")
+	t.Setenv("PIPELINE_IP", "10.123.1.1")
+	t.Setenv("NMOS_PORT", "")
+
+	t.Run("Replaces the references of the values", func(t *testing.T) {
+		file := filepath.Join(dir, "launcher.yaml")
+		assert.NoError(t, os.WriteFile(file, []byte(`k8s: false
+configuration:
+  registries:
+    - server: registry.example.com
+      username: bcs
+      password: ${file:secrets/registry-password}
+  workloadToBeRun:
+    - ffmpegPipeline:
+        name: bcs-ffmpeg-pipeline-tx
+        imageAndTag: tiber-broadcast-suite:${TAG:-latest}
+        gRPCPort: "${GRPC_PORT}"
+        environmentVariables:
+          - "VFIO_PORT_TX=${VFIO_PORT_TX}"
+          - "PRICE=$$5"
+        custom_network: &network
+          enable: true
+          ip: ${PIPELINE_IP}
+      nmosClient:
+        nmosPort: ${NMOS_PORT:-5004}
+        custom_network:
+          <<: *network
+`), 0644))
+
+		config, err := ParseLauncherConfiguration(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "s3cret: {x}", config.Registries[0].Password, "a secret file is read without its trailing newline")
+		pipeline := config.WorkloadToBeRun[0].FfmpegPipeline
+		assert.Equal(t, "tiber-broadcast-suite:latest", pipeline.ImageAndTag)
+		assert.Equal(t, 50088, pipeline.GRPCPort, "a quoted reference takes the type of the field")
+		assert.Equal(t, []string{"VFIO_PORT_TX=0000:ca:11.0", "PRICE=$5"}, pipeline.EnvironmentVariables)
+		assert.Equal(t, "10.123.1.1", pipeline.Network.IP)
+		assert.Equal(t, 5004, config.WorkloadToBeRun[0].NmosClient.NmosPort, "the default replaces an empty variable")
+		assert.Equal(t, "10.123.1.1", config.WorkloadToBeRun[0].NmosClient.Network.IP, "merged values are replaced too")
+	})
+
+	t.Run("Reports references that cannot be resolved", func(t *testing.T) {
+		t.Setenv("PIPELINE_NAME", "tx")
+		file := filepath.Join(dir, "broken.yaml")
+		assert.NoError(t, os.WriteFile(file, []byte(`configuration:
+  workloadToBeRun:
+    - ffmpegPipeline:
+        gRPCPort: ${PIPELINE_NAME}
+        environmentVariables:
+          - VFIO_PORT_TX=${VFIO_PORT_RX}
+          - TOKEN=${file:/run/secrets/bcs-token-missing}
+        custom_network:
+          ip: ${PIPELINE-IP}
+          subnet: ${SUBNET
+`), 0644))
+
+		_, err := ParseLauncherConfiguration(file)
+		assert.EqualError(t, err, file+`:4:19: configuration.workloadToBeRun[0].ffmpegPipeline.gRPCPort: expected an integer, got "tx"
+`+file+`:6:13: configuration.workloadToBeRun[0].ffmpegPipeline.environmentVariables[0]: environment variable VFIO_PORT_RX is not set
+`+file+`:7:13: configuration.workloadToBeRun[0].ffmpegPipeline.environmentVariables[1]: secret file /run/secrets/bcs-token-missing cannot be read: open /run/secrets/bcs-token-missing: no such file or directory
+`+file+`:9:15: configuration.workloadToBeRun[0].ffmpegPipeline.custom_network.ip: invalid reference ${PIPELINE-IP}, use ${VAR}, ${VAR:-default} or ${file:path}
+`+file+`:10:19: configuration.workloadToBeRun[0].ffmpegPipeline.custom_network.subnet: reference "${SUBNET" is not closed with }`)
+	})
+}
+
+func TestUnmarshalInterpolatedJSON(t *testing.T) {
+	t.Setenv("NMOS_PORT", "5004")
+	t.Setenv("NMOS_LABEL", `intel "tx" node`)
+	data := []byte(`{"name": "${NMOS_LABEL}", "replicas": "${NMOS_PORT}", "ports": ["${NMOS_PORT}"], "unknown": "${UNKNOWN}"}`)
+
+	var config schemaTestConfig
+	assert.NoError(t, UnmarshalInterpolatedJSON("nmos.json", data, &config), "keys the type does not describe are not resolved")
+	assert.Equal(t, `intel "tx" node`, config.Name)
+	assert.Equal(t, uint(5004), config.Replicas)
+	assert.Equal(t, []int{5004}, config.Ports)
+
+	err := UnmarshalStrictJSON("nmos.json", data, &config)
+	assert.EqualError(t, err, `nmos.json:1:82: unknown field "unknown"`)
+
+	err = UnmarshalInterpolatedJSON("nmos.json", []byte(`{"name": "${NMOS_NAME}"}`), &config)
+	assert.EqualError(t, err, `nmos.json:1:10: name: environment variable NMOS_NAME is not set`)
+}
+
+func TestUnmarshalRedactedJSON(t *testing.T) {
+	t.Setenv("NMOS_PORT", "5004")
+	secret := filepath.Join(t.TempDir(), "label")
+	assert.NoError(t, os.WriteFile(secret, []byte("s3cr3t\n"), 0600))
+	data := []byte(`{"name": "node-${file:` + secret + `}", "replicas": "${NMOS_PORT}"}`)
+
+	var config schemaTestConfig
+	assert.NoError(t, UnmarshalRedactedJSON("nmos.json", data, &config))
+	assert.Equal(t, "node-${file:"+secret+"}", config.Name, "strings keep their references")
+	assert.Equal(t, uint(5004), config.Replicas)
+
+	err := UnmarshalRedactedJSON("nmos.json", []byte(`{"name": "${NMOS_NAME}"}`), &config)
+	assert.EqualError(t, err, `nmos.json:1:10: name: environment variable NMOS_NAME is not set`, "references are resolved all the same")
+}
+
+func TestHasReferences(t *testing.T) {
+	assert.True(t, HasReferences([]byte(`{"label": "${NMOS_LABEL}"}`)))
+	assert.False(t, HasReferences([]byte(`{"label": "$${NMOS_LABEL}"}`)))
+	assert.False(t, HasReferences([]byte(`{"label": "$HOME"}`)))
+}
+
+// TestInterpolatedDocumentDecodesAlike checks that a document written back from its node tree, which yaml.v2 decodes
+// after the references are replaced and the layers merged, decodes like the file itself.
+func TestInterpolatedDocumentDecodesAlike(t *testing.T) {
+	files, err := filepath.Glob("../../configuration_files/bcslauncher-static-config-*.yaml")
+	assert.NoError(t, err)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		var document yamlv3.Node
+		assert.NoError(t, yamlv3.Unmarshal(data, &document))
+		written, err := yamlv3.Marshal(document.Content[0])
+		assert.NoError(t, err)
+
+		var expected, actual Config
+		assert.NoError(t, yaml.Unmarshal(data, &expected))
+		assert.NoError(t, yaml.Unmarshal(written, &actual))
+		assert.Equal(t, expected, actual, file)
+	}
+}
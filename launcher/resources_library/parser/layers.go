@@ -0,0 +1,331 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// The configuration of docker mode can be split into layers, e.g. a base file with the pipelines shared by all hosts
+// and an overlay per host with its NICs, IP addresses and image tags. The layers are the files given in order, each
+// preceded by the files listed under its include key, relative to its directory. A file included several times is
+// merged once, at its first place. A later layer overrides an earlier one:
+//
+//   - mappings are merged key by key,
+//   - any other value, null included, replaces the value of the earlier layer,
+//   - lists replace the list of the earlier layer, except the lists of keyedLists: an item is merged into the item of
+//     the earlier layer with the same key, items with a new key or without a key are appended.
+
+// keyedLists maps the lists merged item by item to the key identifying an item.
+var keyedLists = map[string]string{
+	"configuration.workloadToBeRun":       "ffmpegPipeline.name",
+	"configuration.runOnce.mediaProxyMcm": "name",
+	"configuration.registries":            "server",
+}
+
+// layer is a file of a layered configuration, as written and with its references replaced.
+type layer struct {
+	file     string
+	raw      *yamlv3.Node
+	resolved *yamlv3.Node
+}
+
+// ParseLauncherConfiguration reads the configuration of docker mode from one or more files merged in order, see
+// layers.go. References to environment variables and secret files in the values, e.g. ${VFIO_PORT_TX}, are replaced,
+// see interpolate.go. Every file is checked on its own: unknown keys, values of the wrong type and references that
+// cannot be resolved are rejected with their position in the file.
+func ParseLauncherConfiguration(filenames ...string) (Configuration, error) {
+	layers, err := readLayers(filenames, func() strictChecker {
+		return strictChecker{interpolated: map[*yamlv3.Node]bool{}}
+	})
+	if err != nil {
+		return Configuration{}, err
+	}
+	var config Config
+	if document := mergeLayers(layers, false); document != nil {
+		// yaml.v2 decodes the merged node tree written back to YAML
+		data, err := yamlv3.Marshal(document)
+		if err == nil {
+			err = yaml.Unmarshal(data, &config)
+		}
+		if err != nil {
+			return Configuration{}, fmt.Errorf("%s: %w", strings.Join(filenames, ", "), err)
+		}
+	}
+	return config.Configuration, nil
+}
+
+// EffectiveLauncherConfiguration returns the configuration merged from the files as ParseLauncherConfiguration reads
+// it, written as YAML. The references are kept as they are written, so that secrets are not printed, but they are
+// resolved to check them.
+func EffectiveLauncherConfiguration(filenames ...string) ([]byte, error) {
+	layers, err := readLayers(filenames, func() strictChecker {
+		return strictChecker{interpolated: map[*yamlv3.Node]bool{}}
+	})
+	if err != nil {
+		return nil, err
+	}
+	document := mergeLayers(layers, true)
+	if document == nil {
+		return nil, nil
+	}
+	var out strings.Builder
+	encoder := yamlv3.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(out.String()), nil
+}
+
+// ParseLauncherMode tells whether the launcher runs in Kubernetes mode, as set by the last of the files, with their
+// includes, setting it. The files are not checked, in Kubernetes mode they are read by the controller.
+func ParseLauncherMode(filenames ...string) (bool, error) {
+	layers, err := readLayers(filenames, func() strictChecker { return strictChecker{lenient: true} })
+	if err != nil {
+		return false, err
+	}
+	var config struct {
+		ModeK8s bool `yaml:"k8s"`
+	}
+	if document := mergeLayers(layers, false); document != nil {
+		data, err := yamlv3.Marshal(document)
+		if err == nil {
+			err = yaml.Unmarshal(data, &config)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return config.ModeK8s, nil
+}
+
+// readLayers reads and checks the files with their includes in the order they are merged. The problems of all files
+// are reported together.
+func readLayers(filenames []string, newChecker func() strictChecker) ([]layer, error) {
+	if len(filenames) == 0 {
+		return nil, errors.New("no launcher configuration file given")
+	}
+	var layers []layer
+	var problems ConfigErrors
+	read := map[string]bool{}
+	var add func(file string, includedBy []string) error
+	add = func(file string, includedBy []string) error {
+		file = filepath.Clean(file)
+		for i, including := range includedBy {
+			if including == file {
+				return fmt.Errorf("include cycle: %s", strings.Join(append(includedBy[i:], file), " -> "))
+			}
+		}
+		if read[file] {
+			return nil
+		}
+		read[file] = true
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var document yamlv3.Node
+		if err := yamlv3.Unmarshal(data, &document); err != nil {
+			return fmt.Errorf("%s: %w", displayName(file), err)
+		}
+		var raw, resolved *yamlv3.Node
+		if len(document.Content) > 0 {
+			raw = normalize(document.Content[0], "")
+			checker := newChecker()
+			checker.file, checker.root = file, SchemaGenerator{Tag: "yaml"}.Generate(reflect.TypeOf(Config{}))
+			checker.check(document.Content[0], checker.root, "")
+			if len(checker.errors) > 0 {
+				problems = append(problems, checker.errors...)
+				return nil
+			}
+			resolved = normalize(document.Content[0], "")
+		}
+		for _, include := range includes(resolved) {
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(file), include)
+			}
+			if err := add(include, append(includedBy[:len(includedBy):len(includedBy)], file)); err != nil {
+				return err
+			}
+		}
+		layers = append(layers, layer{file: file, raw: raw, resolved: resolved})
+		return nil
+	}
+	for _, file := range filenames {
+		if err := add(file, nil); err != nil {
+			return nil, err
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return layers, nil
+}
+
+// includes returns the files listed under the include key of a document.
+func includes(document *yamlv3.Node) []string {
+	if document == nil || document.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	var files []string
+	if value := mappingValue(document, "include"); value != nil {
+		for _, item := range value.Content {
+			files = append(files, item.Value)
+		}
+	}
+	return files
+}
+
+// mergeLayers merges the layers in order into a single document without the include key, nil when all are empty.
+func mergeLayers(layers []layer, raw bool) *yamlv3.Node {
+	var document *yamlv3.Node
+	for _, l := range layers {
+		node := l.resolved
+		if raw {
+			node = l.raw
+		}
+		if node != nil {
+			document = mergeNodes(document, node, "")
+		}
+	}
+	if document != nil && document.Kind == yamlv3.MappingNode {
+		for i := 0; i+1 < len(document.Content); i += 2 {
+			if document.Content[i].Value == "include" {
+				document.Content = append(document.Content[:i], document.Content[i+2:]...)
+				break
+			}
+		}
+	}
+	return document
+}
+
+// mergeNodes merges overlay into base, both normalized, and returns the result. path locates the nodes in the
+// configuration.
+func mergeNodes(base, overlay *yamlv3.Node, path string) *yamlv3.Node {
+	if base == nil || base.Kind != overlay.Kind {
+		return overlay
+	}
+	switch overlay.Kind {
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(overlay.Content); i += 2 {
+			key, value := overlay.Content[i], overlay.Content[i+1]
+			if j := mappingIndex(base, key.Value); j >= 0 {
+				base.Content[j+1] = mergeNodes(base.Content[j+1], value, joinPath(path, key.Value))
+			} else {
+				base.Content = append(base.Content, key, value)
+			}
+		}
+		return base
+	case yamlv3.SequenceNode:
+		key, ok := keyedLists[path]
+		if !ok {
+			return overlay
+		}
+		for _, item := range overlay.Content {
+			merged := false
+			if id, ok := itemKey(item, key); ok {
+				for i, existing := range base.Content {
+					if existingID, ok := itemKey(existing, key); ok && existingID == id {
+						base.Content[i], merged = mergeNodes(existing, item, path+"[]"), true
+						break
+					}
+				}
+			}
+			if !merged {
+				base.Content = append(base.Content, item)
+			}
+		}
+		return base
+	}
+	return overlay
+}
+
+// itemKey returns the value at the dotted key of an item of a keyed list, e.g. ffmpegPipeline.name.
+func itemKey(item *yamlv3.Node, key string) (string, bool) {
+	for _, name := range strings.Split(key, ".") {
+		if item.Kind != yamlv3.MappingNode {
+			return "", false
+		}
+		if item = mappingValue(item, name); item == nil {
+			return "", false
+		}
+	}
+	if item.Kind != yamlv3.ScalarNode || item.ShortTag() == "!!null" || item.Value == "" {
+		return "", false
+	}
+	return item.Value, true
+}
+
+// normalize returns a copy of a node with the aliases and merged mappings (<<: *anchor) expanded, so that nodes of
+// different files can be merged and written together. An item of a keyed list written as a single mapping, e.g.
+// one mediaProxyMcm instance, becomes a list of that item.
+func normalize(node *yamlv3.Node, path string) *yamlv3.Node {
+	if node.Kind == yamlv3.AliasNode {
+		node = node.Alias
+	}
+	copied := *node
+	copied.Anchor = ""
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		copied.Content = nil
+		pairs := mappingPairs(node)
+		own := map[string]bool{}
+		for _, pair := range pairs {
+			if !pair.merged {
+				own[pair.key.Value] = true
+			}
+		}
+		written := map[string]bool{}
+		for _, pair := range pairs {
+			key := pair.key.Value
+			if pair.merged && own[key] || written[key] {
+				continue
+			}
+			written[key] = true
+			copied.Content = append(copied.Content, normalize(pair.key, ""), normalize(pair.value, joinPath(path, key)))
+		}
+		if _, ok := keyedLists[path]; ok {
+			return &yamlv3.Node{Kind: yamlv3.SequenceNode, Tag: "!!seq", Line: node.Line, Column: node.Column, Content: []*yamlv3.Node{&copied}}
+		}
+	case yamlv3.SequenceNode:
+		copied.Content = make([]*yamlv3.Node, len(node.Content))
+		for i, item := range node.Content {
+			copied.Content[i] = normalize(item, path+"[]")
+		}
+	}
+	return &copied
+}
+
+// mappingIndex returns the index of a key in the content of a normalized mapping, -1 when it is missing.
+func mappingIndex(node *yamlv3.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value of a key of a normalized mapping, nil when it is missing.
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if i := mappingIndex(node, key); i >= 0 {
+		return node.Content[i+1]
+	}
+	return nil
+}
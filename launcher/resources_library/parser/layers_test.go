@@ -0,0 +1,219 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const layersTestBase = `k8s: false
+configuration:
+  securityProfile: minimal
+  runOnce:
+    mediaProxyMcm:
+      name: media-proxy-nic0
+      imageAndTag: mcm/media-proxy:latest
+      interfaceName: ens801f0
+  registries:
+    - server: registry.example.com
+      username: bcs
+      password: ${file:secrets/registry-password}
+  workloadToBeRun:
+    - ffmpegPipeline: &pipeline
+        name: bcs-ffmpeg-pipeline-tx
+        imageAndTag: tiber-broadcast-suite:latest
+        gRPCPort: 50088
+        environmentVariables:
+          - "http_proxy="
+          - "VFIO_PORT_TX=0000:ca:11.0"
+        custom_network:
+          enable: true
+          ip: 10.123.1.1
+      nmosClient:
+        nmosPort: 5045
+    - ffmpegPipeline:
+        <<: *pipeline
+        name: bcs-ffmpeg-pipeline-rx
+        gRPCPort: 50089
+        custom_network:
+          enable: true
+          ip: 10.123.1.2
+`
+
+const layersTestHost = `include:
+  - ../base.yaml
+configuration:
+  securityProfile:
+  runOnce:
+    mediaProxyMcm:
+      - name: media-proxy-nic0
+        interfaceName: ens2f0
+      - name: media-proxy-nic1
+        imageAndTag: mcm/media-proxy:latest
+        interfaceName: ens2f1
+  workloadToBeRun:
+    - ffmpegPipeline:
+        name: bcs-ffmpeg-pipeline-rx
+        imageAndTag: tiber-broadcast-suite:${BCS_TAG}
+        environmentVariables:
+          - "VFIO_PORT_RX=0000:4b:01.0"
+        custom_network:
+          ip: 10.50.1.2
+    - mediaProxy: media-proxy-nic1
+      ffmpegPipeline:
+        name: bcs-ffmpeg-pipeline-tx-2
+        gRPCPort: 50090
+`
+
+func writeLayersTestFiles(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	host := filepath.Join(dir, "hosts", "host-a.yaml")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "hosts"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "secrets"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "secrets", "registry-password"), []byte("s3cret\n"), 0600))
+	assert.NoError(t, os.WriteFile(base, []byte(layersTestBase), 0644))
+	assert.NoError(t, os.WriteFile(host, []byte(layersTestHost), 0644))
+	return base, host
+}
+
+func TestParseLauncherConfiguration_Layers(t *testing.T) {
+	t.Setenv("BCS_TAG", "24.09")
+	base, host := writeLayersTestFiles(t)
+
+	check := func(t *testing.T, config Configuration) {
+		assert.Empty(t, config.SecurityProfile, "null removes the value of the base")
+		instances := config.RunOnce.MediaProxyMcm
+		if assert.Len(t, instances, 2) {
+			assert.Equal(t, "media-proxy-nic0", instances[0].Name)
+			assert.Equal(t, "mcm/media-proxy:latest", instances[0].ImageAndTag, "instances are merged by name")
+			assert.Equal(t, "ens2f0", instances[0].InterfaceName)
+			assert.Equal(t, "media-proxy-nic1", instances[1].Name)
+		}
+		assert.Equal(t, "s3cret", config.Registries[0].Password, "a secret file is relative to the file referencing it")
+
+		workloads := config.WorkloadToBeRun
+		if assert.Len(t, workloads, 3) {
+			tx, rx, tx2 := workloads[0].FfmpegPipeline, workloads[1].FfmpegPipeline, workloads[2].FfmpegPipeline
+			assert.Equal(t, "bcs-ffmpeg-pipeline-tx", tx.Name)
+			assert.Equal(t, "10.123.1.1", tx.Network.IP)
+			assert.Equal(t, 5045, workloads[0].NmosClient.NmosPort)
+
+			assert.Equal(t, "bcs-ffmpeg-pipeline-rx", rx.Name, "workloads are merged by the name of their pipeline")
+			assert.Equal(t, 50089, rx.GRPCPort)
+			assert.Equal(t, "tiber-broadcast-suite:24.09", rx.ImageAndTag)
+			assert.Equal(t, []string{"VFIO_PORT_RX=0000:4b:01.0"}, rx.EnvironmentVariables, "other lists are replaced")
+			assert.True(t, rx.Network.Enable)
+			assert.Equal(t, "10.50.1.2", rx.Network.IP)
+
+			assert.Equal(t, "bcs-ffmpeg-pipeline-tx-2", tx2.Name, "new workloads are appended")
+			assert.Equal(t, "media-proxy-nic1", workloads[2].MediaProxy)
+		}
+	}
+
+	t.Run("Merges the included files first", func(t *testing.T) {
+		config, err := ParseLauncherConfiguration(host)
+		assert.NoError(t, err)
+		check(t, config)
+	})
+
+	t.Run("Merges the files given in order", func(t *testing.T) {
+		config, err := ParseLauncherConfiguration(base, host)
+		assert.NoError(t, err)
+		check(t, config)
+
+		config, err = ParseLauncherConfiguration(host, base)
+		assert.NoError(t, err)
+		check(t, config) // base is merged once, where host includes it
+	})
+
+	t.Run("Reports the problems of every file", func(t *testing.T) {
+		overlay := filepath.Join(filepath.Dir(base), "overlay.yaml")
+		assert.NoError(t, os.WriteFile(overlay, []byte(`configuration:
+  workloadToBeRun:
+    - ffmpegPipeline:
+        name: bcs-ffmpeg-pipeline-tx
+        gRPCPort: "${GRPC_PORT}"
+`), 0644))
+		assert.NoError(t, os.WriteFile(base, []byte("configuration:\n  securityProfile: root\n"), 0644))
+
+		_, err := ParseLauncherConfiguration(base, overlay)
+		assert.EqualError(t, err, base+`:2:20: configuration.securityProfile: expected one of "minimal", "privileged", got "root"
+`+overlay+`:5:19: configuration.workloadToBeRun[0].ffmpegPipeline.gRPCPort: environment variable GRPC_PORT is not set`)
+	})
+
+	t.Run("Rejects include cycles", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(base, []byte("include: [hosts/host-a.yaml]\n"), 0644))
+		_, err := ParseLauncherConfiguration(host)
+		assert.EqualError(t, err, "include cycle: "+host+" -> "+base+" -> "+host)
+	})
+}
+
+func TestEffectiveLauncherConfiguration(t *testing.T) {
+	t.Setenv("BCS_TAG", "24.09")
+	_, host := writeLayersTestFiles(t)
+
+	effective, err := EffectiveLauncherConfiguration(host)
+	assert.NoError(t, err)
+	assert.Equal(t, `k8s: false
+configuration:
+  securityProfile:
+  runOnce:
+    mediaProxyMcm:
+      - name: media-proxy-nic0
+        imageAndTag: mcm/media-proxy:latest
+        interfaceName: ens2f0
+      - name: media-proxy-nic1
+        imageAndTag: mcm/media-proxy:latest
+        interfaceName: ens2f1
+  registries:
+    - server: registry.example.com
+      username: bcs
+      password: ${file:secrets/registry-password}
+  workloadToBeRun:
+    - ffmpegPipeline:
+        name: bcs-ffmpeg-pipeline-tx
+        imageAndTag: tiber-broadcast-suite:latest
+        gRPCPort: 50088
+        environmentVariables:
+          - "http_proxy="
+          - "VFIO_PORT_TX=0000:ca:11.0"
+        custom_network:
+          enable: true
+          ip: 10.123.1.1
+      nmosClient:
+        nmosPort: 5045
+    - ffmpegPipeline:
+        imageAndTag: tiber-broadcast-suite:${BCS_TAG}
+        environmentVariables:
+          - "VFIO_PORT_RX=0000:4b:01.0"
+        name: bcs-ffmpeg-pipeline-rx
+        gRPCPort: 50089
+        custom_network:
+          enable: true
+          ip: 10.50.1.2
+    - mediaProxy: media-proxy-nic1
+      ffmpegPipeline:
+        name: bcs-ffmpeg-pipeline-tx-2
+        gRPCPort: 50090
+`, string(effective))
+}
+
+func TestParseLauncherMode_Layers(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	host := filepath.Join(dir, "host.yaml")
+	assert.NoError(t, os.WriteFile(base, []byte("k8s: true\n"), 0644))
+	assert.NoError(t, os.WriteFile(host, []byte("include: [base.yaml]\nconfiguration: {}\n"), 0644))
+
+	modeK8s, err := ParseLauncherMode(host)
+	assert.NoError(t, err)
+	assert.True(t, modeK8s)
+}
@@ -8,13 +8,12 @@
 package parser
 
 import (
-	"os"
-
 	"bcs.pod.launcher.intel/resources_library/workloads"
-	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
+	// Include lists the files this file is layered on, relative to its directory, see layers.go.
+	Include       []string      `yaml:"include,omitempty"`
 	ModeK8s       bool          `yaml:"k8s"`
 	Configuration Configuration `yaml:"configuration"`
 }
@@ -22,35 +21,32 @@ type Config struct {
 type Configuration struct {
 	RunOnce         RunOnce                    `yaml:"runOnce"`
 	WorkloadToBeRun []workloads.WorkloadConfig `yaml:"workloadToBeRun"`
+	// Registries holds the credentials for pulling images from private registries. Images of other registries
+	// are pulled with the credentials of the Docker config.json file found at DockerConfig, in $DOCKER_CONFIG
+	// or in ~/.docker.
+	Registries   []RegistryCredentials `yaml:"registries"`
+	DockerConfig string                `yaml:"dockerConfig"`
+	// ImageSource is a docker save tarball or a directory of tarballs the missing images are loaded from
+	// before they are pulled. A workload can override it.
+	ImageSource string `yaml:"imageSource"`
+	// SecurityProfile is minimal (default) or privileged. A workload can override it.
+	SecurityProfile string `yaml:"securityProfile" jsonschema:"enum=minimal|privileged"`
+	// LauncherID and ConfigFile are not read from the file. They are set by the launcher
+	// and stamped on the containers as ownership labels.
+	LauncherID string `yaml:"-"`
+	ConfigFile string `yaml:"-"`
 }
 
 type RunOnce struct {
-	MediaProxyAgent workloads.MediaProxyAgentConfig `yaml:"mediaProxyAgent"`
-	MediaProxyMcm   workloads.MediaProxyMcmConfig   `yaml:"mediaProxyMcm"`
-}
-
-func ParseLauncherMode(filename string) (bool, error) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return false, err
-	}
-	var config Config
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
-		return false, err
-	}
-	return config.ModeK8s, nil
+	MediaProxyAgent workloads.MediaProxyAgentConfig  `yaml:"mediaProxyAgent"`
+	MediaProxyMcm   workloads.MediaProxyMcmInstances `yaml:"mediaProxyMcm"`
 }
 
-func ParseLauncherConfiguration(filename string) (Configuration, error) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return Configuration{}, err
-	}
-	var config Config
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
-		return Configuration{}, err
-	}
-	return config.Configuration, nil
+// RegistryCredentials authenticates the launcher at a container registry, either with a username and password
+// or with an identity token.
+type RegistryCredentials struct {
+	Server        string `yaml:"server"`
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password"`
+	IdentityToken string `yaml:"identityToken"`
 }
@@ -148,11 +148,11 @@ configuration:
 	assert.Equal(t, "50051", config.RunOnce.MediaProxyAgent.GRPCPort)
 	assert.Equal(t, "8100", config.RunOnce.MediaProxyAgent.RestPort)
 	assert.False(t, config.RunOnce.MediaProxyAgent.Network.Enable)
-	assert.Equal(t, "mcm/media-proxy:latest", config.RunOnce.MediaProxyMcm.ImageAndTag)
-	assert.Equal(t, "eth0", config.RunOnce.MediaProxyMcm.InterfaceName)
-	assert.Len(t, config.RunOnce.MediaProxyMcm.Volumes, 1)
-	assert.Equal(t, "/dev/vfio:/dev/vfio", config.RunOnce.MediaProxyMcm.Volumes[0])
-	assert.False(t, config.RunOnce.MediaProxyMcm.Network.Enable)
+	assert.Equal(t, "mcm/media-proxy:latest", config.RunOnce.MediaProxyMcm[0].ImageAndTag)
+	assert.Equal(t, "eth0", config.RunOnce.MediaProxyMcm[0].InterfaceName)
+	assert.Len(t, config.RunOnce.MediaProxyMcm[0].Volumes, 1)
+	assert.Equal(t, "/dev/vfio:/dev/vfio", config.RunOnce.MediaProxyMcm[0].Volumes[0])
+	assert.False(t, config.RunOnce.MediaProxyMcm[0].Network.Enable)
 
 	assert.Len(t, config.WorkloadToBeRun, 1)
 	assert.Equal(t, "bcs-ffmpeg-pipeline-tx", config.WorkloadToBeRun[0].FfmpegPipeline.Name)
@@ -0,0 +1,210 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package parser
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaDraft is the JSON Schema version of the generated schemas, the one most editors support.
+const SchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is the part of JSON Schema the configuration types are described with.
+type Schema struct {
+	Draft       string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Type        SchemaTypes        `json:"type,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Minimum     *int64             `json:"minimum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties is false for structs and the schema of the values for maps.
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+// SchemaTypes are the JSON types a value may have, written as a single string when there is only one.
+type SchemaTypes []string
+
+func (t SchemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t SchemaTypes) has(name string) bool {
+	for _, typ := range t {
+		if typ == name {
+			return true
+		}
+	}
+	return false
+}
+
+// definitionRef is the prefix of the references to the named structs of a schema.
+const definitionRef = "#/definitions/"
+
+// SchemaGenerator derives a JSON Schema from a Go type, so the schema cannot drift from the structs it describes.
+// Fields are named by the struct tag Tag, yaml or json, and a jsonschema:"enum=a|b" tag restricts a string field
+// to the values listed.
+type SchemaGenerator struct {
+	Tag string
+	// Overrides replaces the schema of types that are not described by their fields, e.g. metav1.ObjectMeta.
+	Overrides map[reflect.Type]*Schema
+}
+
+// Generate returns the schema of t. The named structs t consists of are listed in the definitions of the schema.
+func (g SchemaGenerator) Generate(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	state := &schemaState{generator: g, definitions: map[string]*Schema{}, names: map[reflect.Type]string{}}
+	schema := state.structSchema(t)
+	if len(state.definitions) > 0 {
+		schema.Definitions = state.definitions
+	}
+	return schema
+}
+
+type schemaState struct {
+	generator   SchemaGenerator
+	definitions map[string]*Schema
+	names       map[reflect.Type]string
+}
+
+func (s *schemaState) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if override, ok := s.generator.Overrides[t]; ok {
+		return override
+	}
+	// A type decoding itself from YAML may accept more than one form. The only one of the configuration,
+	// MediaProxyMcmInstances, is a list that also accepts a single element.
+	if s.generator.Tag == "yaml" && t.Kind() == reflect.Slice && reflect.PtrTo(t).Implements(unmarshalerType) {
+		item := s.schema(t.Elem())
+		return &Schema{AnyOf: []*Schema{item, {Type: SchemaTypes{"array"}, Items: item}}}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return &Schema{Ref: definitionRef + s.define(t)}
+	case reflect.Map:
+		return &Schema{Type: SchemaTypes{"object"}, AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: SchemaTypes{"array"}, Items: s.schema(t.Elem())}
+	case reflect.String:
+		if s.generator.Tag == "yaml" {
+			// yaml.v2 decodes every scalar into a string, e.g. gRPCPort: 50051 of MediaProxyAgentConfig.
+			return &Schema{Type: SchemaTypes{"string", "number", "boolean"}}
+		}
+		return &Schema{Type: SchemaTypes{"string"}}
+	case reflect.Bool:
+		return &Schema{Type: SchemaTypes{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: SchemaTypes{"integer"}}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := int64(0)
+		return &Schema{Type: SchemaTypes{"integer"}, Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: SchemaTypes{"number"}}
+	}
+	return &Schema{}
+}
+
+// define adds a named struct to the definitions and returns its name there, the type name or, when another
+// package has a type of the same name, the package and type name.
+func (s *schemaState) define(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := s.definitions[name]; taken {
+		name = t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:] + "." + name
+	}
+	s.names[t] = name
+	s.definitions[name] = nil // reserves the name while the fields are generated
+	s.definitions[name] = s.structSchema(t)
+	return name
+}
+
+func (s *schemaState) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: SchemaTypes{"object"}, Properties: map[string]*Schema{}, AdditionalProperties: false}
+	s.addFields(schema, t)
+	return schema
+}
+
+func (s *schemaState) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get(s.generator.Tag)
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		// yaml.v2 inlines the fields of structs marked inline, encoding/json the ones of embedded structs without a name.
+		inline := strings.Contains(","+options+",", ",inline,") || (s.generator.Tag == "json" && field.Anonymous && name == "")
+		if _, overridden := s.generator.Overrides[fieldType]; inline && !overridden && fieldType.Kind() == reflect.Struct {
+			s.addFields(schema, fieldType)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+			if s.generator.Tag == "yaml" {
+				name = strings.ToLower(name)
+			}
+		}
+		property := s.schema(field.Type)
+		if enum := schemaEnum(field.Tag.Get("jsonschema")); enum != nil {
+			restricted := *property
+			restricted.Enum = enum
+			property = &restricted
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// schemaEnum returns the values of the enum option of a jsonschema tag, e.g. enum=minimal|privileged.
+func schemaEnum(tag string) []string {
+	for _, option := range strings.Split(tag, ",") {
+		if values, ok := strings.CutPrefix(option, "enum="); ok {
+			return strings.Split(values, "|")
+		}
+	}
+	return nil
+}
+
+// definition returns the schema a reference of root points to.
+func (root *Schema) definition(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = root.Definitions[strings.TrimPrefix(schema.Ref, definitionRef)]
+	}
+	if schema == nil {
+		return &Schema{}
+	}
+	return schema
+}
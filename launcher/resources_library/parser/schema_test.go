@@ -0,0 +1,123 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package parser
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaTestLimits struct {
+	CPU string `yaml:"cpu" json:"cpu"`
+}
+
+type schemaTestBase struct {
+	Limits schemaTestLimits `yaml:"limits" json:"limits"`
+}
+
+type schemaTestConfig struct {
+	schemaTestBase `yaml:",inline"`
+	Name           string            `yaml:"name" json:"name"`
+	Profile        string            `yaml:"profile" json:"profile" jsonschema:"enum=minimal|privileged"`
+	Replicas       uint              `yaml:"replicas" json:"replicas"`
+	Labels         map[string]string `yaml:"labels" json:"labels"`
+	Ports          []int             `yaml:"ports" json:"ports"`
+	Internal       string            `yaml:"-" json:"-"`
+}
+
+func TestSchemaGenerator(t *testing.T) {
+	t.Run("Describes the yaml keys", func(t *testing.T) {
+		schema := SchemaGenerator{Tag: "yaml"}.Generate(reflect.TypeOf(&schemaTestConfig{}))
+		data, err := json.Marshal(schema)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "limits": {"$ref": "#/definitions/schemaTestLimits"},
+    "name": {"type": ["string", "number", "boolean"]},
+    "profile": {"type": ["string", "number", "boolean"], "enum": ["minimal", "privileged"]},
+    "replicas": {"type": "integer", "minimum": 0},
+    "labels": {"type": "object", "additionalProperties": {"type": ["string", "number", "boolean"]}},
+    "ports": {"type": "array", "items": {"type": "integer"}}
+  },
+  "definitions": {
+    "schemaTestLimits": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {"cpu": {"type": ["string", "number", "boolean"]}}
+    }
+  }
+}`, string(data))
+	})
+
+	t.Run("Describes the json keys", func(t *testing.T) {
+		schema := SchemaGenerator{Tag: "json"}.Generate(reflect.TypeOf(schemaTestConfig{}))
+		assert.Equal(t, SchemaTypes{"string"}, schema.Properties["name"].Type)
+		assert.Contains(t, schema.Properties, "limits", "encoding/json inlines embedded structs without a name")
+		assert.NotContains(t, schema.Properties, "schemaTestBase")
+	})
+
+	t.Run("A list decoding itself also accepts a single element", func(t *testing.T) {
+		schema := SchemaGenerator{Tag: "yaml"}.Generate(reflect.TypeOf(RunOnce{}))
+		mcm := schema.Properties["mediaProxyMcm"]
+		if assert.Len(t, mcm.AnyOf, 2) {
+			assert.Equal(t, "#/definitions/MediaProxyMcmConfig", mcm.AnyOf[0].Ref)
+			assert.Equal(t, SchemaTypes{"array"}, mcm.AnyOf[1].Type)
+		}
+	})
+}
+
+func TestCheckSchema(t *testing.T) {
+	schema := SchemaGenerator{Tag: "yaml"}.Generate(reflect.TypeOf(Config{}))
+
+	t.Run("Checks the values of enums", func(t *testing.T) {
+		err := CheckSchema("launcher.yaml", []byte(`configuration:
+  securityProfile: root
+  runOnce:
+    mediaProxyMcm: media-proxy
+  workloadToBeRun:
+    - securityProfile: privileged
+      ffmpegPipeline:
+        restartPolicy:
+          name: no
+    - ffmpegPipeline:
+        restartPolicy:
+          name: sometimes
+`), schema)
+		assert.EqualError(t, err, `launcher.yaml:2:20: configuration.securityProfile: expected one of "minimal", "privileged", got "root"
+launcher.yaml:4:20: configuration.runOnce.mediaProxyMcm: expected a mapping or a list, got "media-proxy"
+launcher.yaml:12:17: configuration.workloadToBeRun[1].ffmpegPipeline.restartPolicy.name: expected one of "no", "always", "unless-stopped", "on-failure", got "sometimes"`)
+	})
+
+	t.Run("Objects without properties accept any key", func(t *testing.T) {
+		open := &Schema{Type: SchemaTypes{"object"}, Properties: map[string]*Schema{
+			"metadata": {Type: SchemaTypes{"object"}},
+			"labels":   {Type: SchemaTypes{"object"}, AdditionalProperties: &Schema{Type: SchemaTypes{"string"}}},
+		}, AdditionalProperties: false}
+		assert.NoError(t, CheckSchema("", []byte("metadata:\n  name: sample\n  labels: {app: bcs}\n"), open))
+		assert.EqualError(t, CheckSchema("", []byte("labels:\n  replicas: 2\n"), open), `2:13: labels.replicas: expected a string, got the integer 2`)
+	})
+}
+
+func TestUnmarshalStrictJSON(t *testing.T) {
+	var config schemaTestConfig
+	err := UnmarshalStrictJSON("config.json", []byte(`{"name": "bcs", "replicas": 2, "ports": [5004, 5005]}`), &config)
+	assert.NoError(t, err)
+	assert.Equal(t, "bcs", config.Name)
+	assert.Equal(t, []int{5004, 5005}, config.Ports)
+
+	err = UnmarshalStrictJSON("config.json", []byte(`{"name": 1, "replicas": -2, "ports": ["5004"], "profile": "minimal"}`), &config)
+	assert.EqualError(t, err, `config.json:1:10: name: expected a string, got the integer 1
+config.json:1:25: replicas: expected a non-negative integer, got the integer -2
+config.json:1:39: ports[0]: expected an integer, got the string "5004": remove the quotes`)
+
+	err = UnmarshalStrictJSON("config.json", []byte(`{"name": `), &config)
+	assert.EqualError(t, err, "unexpected end of JSON input")
+}
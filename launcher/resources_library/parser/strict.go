@@ -0,0 +1,460 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// ConfigError is a problem of a configuration file, located at the line and column of the offending key or value.
+type ConfigError struct {
+	File    string
+	Line    int
+	Column  int
+	Path    string // e.g. configuration.workloadToBeRun[0].ffmpegPipeline.gRPCPort
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	position := fmt.Sprintf("%d:%d", e.Line, e.Column)
+	if e.File != "" {
+		position = e.File + ":" + position
+	}
+	if e.Path == "" {
+		return position + ": " + e.Message
+	}
+	return position + ": " + e.Path + ": " + e.Message
+}
+
+// ConfigErrors lists all problems found in a configuration file, in the order they appear in it.
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// UnmarshalStrict decodes data into out like yaml.Unmarshal, but first checks the document against the schema of
+// the type of out, see CheckSchema.
+func UnmarshalStrict(file string, data []byte, out interface{}) error {
+	if _, err := checkDocument(file, data, SchemaGenerator{Tag: "yaml"}.Generate(reflect.TypeOf(out)), strictChecker{}); err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s: %w", displayName(file), err)
+	}
+	return nil
+}
+
+// UnmarshalStrictJSON decodes data into out like json.Unmarshal, but first replaces the references to environment
+// variables and secret files of its values and checks the document against the schema of the type of out,
+// see interpolate.go.
+func UnmarshalStrictJSON(file string, data []byte, out interface{}) error {
+	return unmarshalJSON(file, data, out, false, false)
+}
+
+// UnmarshalInterpolatedJSON decodes data into out like json.Unmarshal after replacing the references of its values.
+// Unlike UnmarshalStrictJSON it does not reject keys and values out does not describe.
+func UnmarshalInterpolatedJSON(file string, data []byte, out interface{}) error {
+	return unmarshalJSON(file, data, out, true, false)
+}
+
+// UnmarshalRedactedJSON decodes data into out like UnmarshalInterpolatedJSON, but string values keep their references
+// as written once they are resolved, so the secrets they point to are not disclosed when out is printed.
+func UnmarshalRedactedJSON(file string, data []byte, out interface{}) error {
+	return unmarshalJSON(file, data, out, true, true)
+}
+
+func unmarshalJSON(file string, data []byte, out interface{}, lenient, redact bool) error {
+	if !json.Valid(data) {
+		return json.Unmarshal(data, out)
+	}
+	checker := strictChecker{interpolated: map[*yamlv3.Node]bool{}, lenient: lenient, redact: redact}
+	document, err := checkDocument(file, data, SchemaGenerator{Tag: "json"}.Generate(reflect.TypeOf(out)), checker)
+	if err != nil {
+		return err
+	}
+	if document != nil && bytes.Contains(data, []byte("$")) {
+		var value interface{}
+		if err := document.Decode(&value); err != nil {
+			return fmt.Errorf("%s: %w", displayName(file), err)
+		}
+		if data, err = json.Marshal(value); err != nil {
+			return fmt.Errorf("%s: %w", displayName(file), err)
+		}
+	}
+	return json.Unmarshal(data, out)
+}
+
+// CheckSchema checks a YAML or JSON document against a schema. Keys the schema has no property for and values of
+// the wrong type or not among the values of an enum are reported as ConfigErrors with the position of the key or
+// value in the file and, for unknown keys, the closest known key. file only names the data in the errors.
+// Empty values are accepted everywhere, they leave the field unset.
+func CheckSchema(file string, data []byte, schema *Schema) error {
+	_, err := checkDocument(file, data, schema, strictChecker{})
+	return err
+}
+
+// checkDocument parses data and walks it with the checker along the schema. It returns the root node of the
+// document, nil for an empty one.
+func checkDocument(file string, data []byte, schema *Schema, checker strictChecker) (*yamlv3.Node, error) {
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%s: %w", displayName(file), err)
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	checker.file, checker.root = file, schema
+	checker.check(document.Content[0], schema, "")
+	if len(checker.errors) > 0 {
+		return nil, checker.errors
+	}
+	return document.Content[0], nil
+}
+
+func displayName(file string) string {
+	if file == "" {
+		return "configuration"
+	}
+	return file
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// strictChecker walks a YAML node tree along the schema it is checked against and collects the problems it finds.
+// With interpolated set it also replaces the references of the values it passes.
+type strictChecker struct {
+	file         string
+	root         *Schema
+	interpolated map[*yamlv3.Node]bool // the scalars whose references are replaced, an alias passes a node again
+	lenient      bool                  // only the references are reported, not the values that do not match the schema
+	redact       bool                  // the references of string values are resolved but kept as written
+	errors       ConfigErrors
+}
+
+func (c *strictChecker) fail(node *yamlv3.Node, path, format string, args ...interface{}) {
+	if !c.lenient {
+		c.failReference(node, path, format, args...)
+	}
+}
+
+func (c *strictChecker) failReference(node *yamlv3.Node, path, format string, args ...interface{}) {
+	c.errors = append(c.errors, ConfigError{File: c.file, Line: node.Line, Column: node.Column, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *strictChecker) check(node *yamlv3.Node, schema *Schema, path string) {
+	if node.Kind == yamlv3.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yamlv3.ScalarNode && node.ShortTag() == "!!null" {
+		return
+	}
+	schema = c.root.definition(schema)
+	if len(schema.AnyOf) > 0 {
+		for _, alternative := range schema.AnyOf {
+			if alternative = c.root.definition(alternative); nodeKind(alternative) == node.Kind {
+				c.check(node, alternative, path)
+				return
+			}
+		}
+		kinds := make([]string, len(schema.AnyOf))
+		for i, alternative := range schema.AnyOf {
+			kinds[i] = kindName(nodeKind(c.root.definition(alternative)))
+		}
+		c.fail(node, path, "expected %s, got %s", strings.Join(kinds, " or "), describeNode(node))
+		return
+	}
+
+	if node.Kind == yamlv3.ScalarNode && c.interpolated != nil {
+		if !c.interpolate(node, schema, path) || node.ShortTag() == "!!null" {
+			return
+		}
+	}
+
+	switch {
+	case len(schema.Type) == 0:
+	case schema.Type.has("object"):
+		if !c.expect(node, yamlv3.MappingNode, path) {
+			return
+		}
+		c.checkProperties(node, schema, path)
+	case schema.Type.has("array"):
+		if c.expect(node, yamlv3.SequenceNode, path) {
+			for i, item := range node.Content {
+				c.check(item, schema.Items, path+"["+strconv.Itoa(i)+"]")
+			}
+		}
+	default:
+		if c.expect(node, yamlv3.ScalarNode, path) {
+			c.checkScalar(node, schema, path)
+		}
+	}
+}
+
+// nodeKind returns the kind of node a schema describes.
+func nodeKind(schema *Schema) yamlv3.Kind {
+	switch {
+	case schema.Type.has("object"):
+		return yamlv3.MappingNode
+	case schema.Type.has("array"):
+		return yamlv3.SequenceNode
+	default:
+		return yamlv3.ScalarNode
+	}
+}
+
+// expect reports a node that is not of the kind the schema describes.
+func (c *strictChecker) expect(node *yamlv3.Node, kind yamlv3.Kind, path string) bool {
+	if node.Kind == kind {
+		return true
+	}
+	c.fail(node, path, "expected %s, got %s", kindName(kind), describeNode(node))
+	return false
+}
+
+// checkProperties checks the keys and values of a mapping. Keys without a property are checked against the schema of
+// additionalProperties, rejected when it is false or accepted when it is not set.
+func (c *strictChecker) checkProperties(node *yamlv3.Node, schema *Schema, path string) {
+	pairs := mappingPairs(node)
+	seen := map[string]*yamlv3.Node{}
+	for _, pair := range pairs {
+		if !pair.merged {
+			if previous, ok := seen[pair.key.Value]; ok {
+				c.fail(pair.key, path, "field %q is already set on line %d", pair.key.Value, previous.Line)
+				continue
+			}
+			seen[pair.key.Value] = pair.key
+		}
+	}
+	for _, pair := range pairs {
+		key := pair.key.Value
+		if pair.merged && seen[key] != nil || !pair.merged && seen[key] != pair.key {
+			continue
+		}
+		property, ok := schema.Properties[key]
+		if values, isMap := schema.AdditionalProperties.(*Schema); !ok && isMap {
+			property, ok = values, true
+		}
+		if !ok && schema.AdditionalProperties != false {
+			continue
+		}
+		if !ok {
+			known := make([]string, 0, len(schema.Properties))
+			for name := range schema.Properties {
+				known = append(known, name)
+			}
+			if suggestion := closest(key, known); suggestion != "" {
+				c.fail(pair.key, path, "unknown field %q, did you mean %q?", key, suggestion)
+			} else {
+				c.fail(pair.key, path, "unknown field %q", key)
+			}
+			continue
+		}
+		c.check(pair.value, property, joinPath(path, key))
+	}
+}
+
+func (c *strictChecker) checkScalar(node *yamlv3.Node, schema *Schema, path string) {
+	if !scalarMatches(node, schema.Type) {
+		expected := schemaTypeName(schema)
+		if schema.Type.has("integer") || schema.Type.has("number") {
+			c.failNumber(node, expected, path)
+		} else {
+			c.fail(node, path, "expected %s, got %s", expected, describeNode(node))
+		}
+		return
+	}
+	if schema.Minimum != nil {
+		if value, err := strconv.ParseFloat(node.Value, 64); err == nil && value < float64(*schema.Minimum) {
+			c.fail(node, path, "expected %s, got %s", schemaTypeName(schema), describeNode(node))
+			return
+		}
+	}
+	if len(schema.Enum) > 0 {
+		for _, value := range schema.Enum {
+			if node.Value == value {
+				return
+			}
+		}
+		values := make([]string, len(schema.Enum))
+		for i, value := range schema.Enum {
+			values[i] = strconv.Quote(value)
+		}
+		c.fail(node, path, "expected one of %s, got %s", strings.Join(values, ", "), describeNode(node))
+	}
+}
+
+// scalarMatches tells whether a scalar is of one of the JSON types. Booleans may be written as in YAML 1.1,
+// which yaml.v2 follows.
+func scalarMatches(node *yamlv3.Node, types SchemaTypes) bool {
+	switch node.ShortTag() {
+	case "!!str", "!!timestamp", "!!binary":
+		return types.has("string") || types.has("boolean") && node.Style == 0 && isYAML11Bool(node.Value)
+	case "!!int":
+		return types.has("integer") || types.has("number")
+	case "!!float":
+		return types.has("number")
+	case "!!bool":
+		return types.has("boolean")
+	}
+	return false
+}
+
+// schemaTypeName names the value a scalar schema expects in an error.
+func schemaTypeName(schema *Schema) string {
+	switch {
+	case schema.Type.has("string"):
+		return "a string"
+	case schema.Type.has("boolean"):
+		return "a boolean (true or false)"
+	case schema.Type.has("integer") && schema.Minimum != nil && *schema.Minimum == 0:
+		return "a non-negative integer"
+	case schema.Type.has("integer"):
+		return "an integer"
+	default:
+		return "a number"
+	}
+}
+
+// failNumber reports a value that is not the number expected, with a hint when the number is quoted.
+func (c *strictChecker) failNumber(node *yamlv3.Node, expected, path string) {
+	if node.ShortTag() == "!!str" && node.Style&(yamlv3.DoubleQuotedStyle|yamlv3.SingleQuotedStyle) != 0 {
+		if _, err := strconv.ParseFloat(node.Value, 64); err == nil {
+			c.fail(node, path, "expected %s, got the string %q: remove the quotes", expected, node.Value)
+			return
+		}
+	}
+	c.fail(node, path, "expected %s, got %s", expected, describeNode(node))
+}
+
+type nodePair struct {
+	key, value *yamlv3.Node
+	merged     bool // comes from a merged mapping (<<: *anchor), the keys of the mapping itself override it
+}
+
+// mappingPairs returns the keys and values of a mapping, including the ones of merged mappings.
+func mappingPairs(node *yamlv3.Node) []nodePair {
+	var pairs []nodePair
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.ShortTag() != "!!merge" {
+			pairs = append(pairs, nodePair{key: key, value: value})
+			continue
+		}
+		merged := []*yamlv3.Node{value}
+		if value.Kind == yamlv3.SequenceNode {
+			merged = value.Content
+		}
+		for _, m := range merged {
+			if m.Kind == yamlv3.AliasNode {
+				m = m.Alias
+			}
+			if m.Kind == yamlv3.MappingNode {
+				for _, pair := range mappingPairs(m) {
+					pairs = append(pairs, nodePair{key: pair.key, value: pair.value, merged: true})
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+// closest returns the known key most similar to key, or "" when none is close enough to be a typo of it.
+// Keys differing only in case are the closest.
+func closest(key string, known []string) string {
+	sort.Strings(known)
+	best, bestDistance := "", len(key)/3+1
+	for _, candidate := range known {
+		if distance := editDistance(strings.ToLower(key), strings.ToLower(candidate)); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance counts the insertions, deletions, substitutions and transpositions of adjacent characters
+// turning a into b.
+func editDistance(a, b string) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
+
+// isYAML11Bool tells whether yaml.v2, which follows YAML 1.1, reads a plain scalar as a boolean.
+func isYAML11Bool(value string) bool {
+	switch value {
+	case "y", "Y", "yes", "Yes", "YES", "n", "N", "no", "No", "NO", "on", "On", "ON", "off", "Off", "OFF":
+		return true
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func kindName(kind yamlv3.Kind) string {
+	switch kind {
+	case yamlv3.MappingNode:
+		return "a mapping"
+	case yamlv3.SequenceNode:
+		return "a list"
+	default:
+		return "a single value"
+	}
+}
+
+// describeNode names the kind and, for scalars, the type and value of a node in an error.
+func describeNode(node *yamlv3.Node) string {
+	if node.Kind != yamlv3.ScalarNode {
+		return kindName(node.Kind)
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		return "the integer " + node.Value
+	case "!!float":
+		return "the number " + node.Value
+	case "!!bool":
+		return "the boolean " + node.Value
+	default:
+		return strconv.Quote(node.Value)
+	}
+}
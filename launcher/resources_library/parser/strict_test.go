@@ -0,0 +1,131 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLauncherConfiguration_Strict(t *testing.T) {
+	yamlData := `k8s: false
+configuration:
+  runOnce:
+    mediaProxyAgent:
+      imageAndTag: mcm/mesh-agent:latest
+      gRPCPort: 50051
+      custom_netwrok:
+        enable: false
+    mediaProxyMcm:
+      imageAndTag: mcm/media-proxy:latest
+      volumes: /dev/vfio:/dev/vfio
+  workloadToBeRun:
+    - ffmpegPipeline:
+        name: bcs-ffmpeg-pipeline-tx
+        gRPCport: 50088
+        gRPCPort: "50088"
+        custom_network:
+          enable: maybe
+      nmosClient:
+        nmosPort: 5045
+        nmosPort: 5046
+        colour: blue
+`
+	file := filepath.Join(t.TempDir(), "launcher.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(yamlData), 0644))
+
+	_, err := ParseLauncherConfiguration(file)
+	var configErrors ConfigErrors
+	if assert.ErrorAs(t, err, &configErrors) {
+		assert.Equal(t, ConfigError{File: file, Line: 7, Column: 7, Path: "configuration.runOnce.mediaProxyAgent",
+			Message: `unknown field "custom_netwrok", did you mean "custom_network"?`}, configErrors[0])
+	}
+	assert.EqualError(t, err, file+`:7:7: configuration.runOnce.mediaProxyAgent: unknown field "custom_netwrok", did you mean "custom_network"?
+`+file+`:11:16: configuration.runOnce.mediaProxyMcm.volumes: expected a list, got "/dev/vfio:/dev/vfio"
+`+file+`:15:9: configuration.workloadToBeRun[0].ffmpegPipeline: unknown field "gRPCport", did you mean "gRPCPort"?
+`+file+`:16:19: configuration.workloadToBeRun[0].ffmpegPipeline.gRPCPort: expected an integer, got the string "50088": remove the quotes
+`+file+`:18:19: configuration.workloadToBeRun[0].ffmpegPipeline.custom_network.enable: expected a boolean (true or false), got "maybe"
+`+file+`:21:9: configuration.workloadToBeRun[0].nmosClient: field "nmosPort" is already set on line 20
+`+file+`:22:9: configuration.workloadToBeRun[0].nmosClient: unknown field "colour"`)
+}
+
+func TestParseLauncherConfiguration_ShippedFiles(t *testing.T) {
+	files, err := filepath.Glob("../../configuration_files/bcslauncher-static-config-*.yaml")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+	for _, file := range files {
+		_, err := ParseLauncherConfiguration(file)
+		assert.NoError(t, err, file)
+	}
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	t.Run("Accepts what yaml.v2 decodes", func(t *testing.T) {
+		var config Config
+		err := UnmarshalStrict("launcher.yaml", []byte(`
+k8s: no
+configuration:
+  runOnce:
+    mediaProxyAgent: &agent
+      gRPCPort: 50051
+      restPort: 8100
+    mediaProxyMcm:
+      imageAndTag: mcm/media-proxy:latest
+  workloadToBeRun:
+    - ffmpegPipeline:
+        gRPCPort: 50088
+        custom_network: &network
+          enable: on
+          ip: 10.123.1.1
+      nmosClient:
+        custom_network:
+          <<: *network
+          ip: 10.123.1.2
+`), &config)
+		assert.NoError(t, err)
+		assert.Equal(t, "50051", config.Configuration.RunOnce.MediaProxyAgent.GRPCPort)
+		assert.Equal(t, "mcm/media-proxy:latest", config.Configuration.RunOnce.MediaProxyMcm[0].ImageAndTag, "a single MCM Media Proxy is a mapping")
+		assert.True(t, config.Configuration.WorkloadToBeRun[0].NmosClient.Network.Enable)
+		assert.Equal(t, "10.123.1.2", config.Configuration.WorkloadToBeRun[0].NmosClient.Network.IP)
+	})
+
+	t.Run("Checks merged mappings and list items", func(t *testing.T) {
+		var config Config
+		err := UnmarshalStrict("", []byte(`
+base: &network
+  enabled: true
+configuration:
+  runOnce:
+    mediaProxyMcm:
+      - name: media-proxy-nic0
+        interfaceNmae: eth0
+  workloadToBeRun:
+    - nmosClient:
+        custom_network:
+          <<: *network
+`), &config)
+		assert.EqualError(t, err, `2:1: unknown field "base"
+8:9: configuration.runOnce.mediaProxyMcm[0]: unknown field "interfaceNmae", did you mean "interfaceName"?
+3:3: configuration.workloadToBeRun[0].nmosClient.custom_network: unknown field "enabled", did you mean "enable"?`)
+	})
+
+	t.Run("Reports syntax errors with the file name", func(t *testing.T) {
+		var config Config
+		err := UnmarshalStrict("launcher.yaml", []byte("configuration:\n  runOnce: [\n"), &config)
+		assert.ErrorContains(t, err, "launcher.yaml: yaml: line 2")
+	})
+}
+
+func TestClosest(t *testing.T) {
+	known := []string{"enable", "name", "ip", "driver", "subnet", "gateway"}
+	assert.Equal(t, "subnet", closest("subnte", known))
+	assert.Equal(t, "name", closest("Name", known))
+	assert.Equal(t, "", closest("ipv6", known))
+	assert.Equal(t, "", closest("colour", known))
+}
@@ -6,7 +6,10 @@
 
 package bcs
 
-import "bcs.pod.launcher.intel/resources_library/resources/general"
+import (
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+)
 
 type BcsApp struct {
 	Name       string
@@ -14,17 +17,6 @@ type BcsApp struct {
 	Containers general.Containers
 }
 
-type HwResources struct {
-	Requests struct {
-		CPU    string `yaml:"cpu"`
-		Memory string `yaml:"memory"`
-		Hugepages1Gi string `yaml:"hugepages-1Gi,omitempty"`
-	    Hugepages2Mi string `yaml:"hugepages-2Mi,omitempty"`
-	} `yaml:"requests"`
-	Limits struct {
-		CPU    string `yaml:"cpu"`
-		Memory string `yaml:"memory"`
-		Hugepages1Gi string `yaml:"hugepages-1Gi,omitempty"`
-	    Hugepages2Mi string `yaml:"hugepages-2Mi,omitempty"`
-	} `yaml:"limits"`
-}
+// HwResources is kept here for the BcsConfig API, the type lives with the workload configurations
+// that reuse it in docker mode.
+type HwResources = workloads.HwResources
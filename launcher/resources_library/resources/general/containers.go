@@ -15,6 +15,16 @@ const (
 	NetworkModeHost NetworkMode = "host"
 )
 
+// Labels stamped on every container created by the launcher in docker mode.
+// They tell the launcher's own containers apart from anything else running on the host.
+const (
+	LabelLauncherID    = "bcs.intel.launcher.id"             // ID of the launcher instance owning the container
+	LabelConfigFile    = "bcs.intel.launcher.config-file"    // launcher configuration file the container is declared in
+	LabelWorkloadIndex = "bcs.intel.launcher.workload-index" // index of the workload under workloadToBeRun
+	LabelRole          = "bcs.intel.launcher.role"           // Workload type of the container
+	LabelConfigHash    = "bcs.intel.launcher.config-hash"    // hash of the container configuration
+)
+
 const (
 	MediaProxyAgent Workload = iota
 	MediaProxyMCM
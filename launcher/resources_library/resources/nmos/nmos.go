@@ -15,7 +15,7 @@ type Config struct {
 	ActivateSenders         bool       `json:"activate_senders"`
 	MultiviewerColumns      int        `json:"multiviewer_columns,omitempty"`
 	StreamLoop              int        `json:"stream_loop"`
-	GpuHwAcceleration       string     `json:"gpu_hw_acceleration"`
+	GpuHwAcceleration       string     `json:"gpu_hw_acceleration" jsonschema:"enum=none|intel|nvidia"`
 	GpuHwAccelerationDevice string     `json:"gpu_hw_acceleration_device,omitempty"`
 	Domain                  string     `json:"domain"`
 	FfmpegGrpcServerAddress string     `json:"ffmpeg_grpc_server_address"`
@@ -48,7 +48,7 @@ type Video struct {
 	FrameWidth  int       `json:"frame_width"`
 	FrameHeight int       `json:"frame_height"`
 	FrameRate   FrameRate `json:"frame_rate"`
-	PixelFormat string    `json:"pixel_format"`
+	PixelFormat string    `json:"pixel_format" jsonschema:"enum=|yuv422p10le|y210le|yuv422p|yuv420p|yuv444p10le|rgb24"` // empty for encoded video
 	VideoType   string    `json:"video_type"`
 	Preset      string    `json:"preset,omitempty"`
 	Profile     string    `json:"profile,omitempty"`
@@ -73,16 +73,16 @@ type StreamType struct {
 }
 
 type St2110 struct {
-	Transport    string `json:"transport"`
+	Transport    string `json:"transport" jsonschema:"enum=st2110-20|st2110-22|st2110-30"`
 	Payload_type int    `json:"payloadType"`
 	QueuesCount  int    `json:"queues_cnt"`
 }
 
 type Mcm struct {
-	ConnType             string `json:"conn_type"`
-	Transport            string `json:"transport"`
+	ConnType             string `json:"conn_type" jsonschema:"enum=st2110|multipoint-group"`
+	Transport            string `json:"transport" jsonschema:"enum=st2110-20|st2110-22|st2110-30"`
 	Urn                  string `json:"urn"`
-	TransportPixelFormat string `json:"transportPixelFormat"`
+	TransportPixelFormat string `json:"transportPixelFormat" jsonschema:"enum=yuv422p10rfc4175|yuv422p10le|v210"` // only with st2110-20
 }
 
 type File struct {
@@ -0,0 +1,151 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types/container"
+)
+
+// DefaultRestartPolicy restarts the containers after failures, daemon restarts and reboots unless they were stopped on purpose.
+const DefaultRestartPolicy = container.RestartPolicyUnlessStopped
+
+// defaultHealthCheck is used for the settings a health check leaves empty.
+var defaultHealthCheck = workloads.HealthCheck{Interval: "10s", Timeout: "5s", StartPeriod: "30s", Retries: 3}
+
+// nmosNodeAPIPath is the path of the node API the health check of NMOS clients requests.
+const nmosNodeAPIPath = "/x-nmos/node/"
+
+// containerHealthSettings returns the declared health check and restart policy of a container together with
+// the default probe of its type: the gRPC port of Media Proxy Agent and of the FFmpeg pipelines, the node API of the
+// NMOS clients. MCM Media Proxy serves no port, it has no default probe.
+func containerHealthSettings(containerInfo *general.Containers, config *parser.Configuration) (workloads.HealthCheck, workloads.RestartPolicy, workloads.HealthCheck, workloads.NetworkConfig) {
+	var probe workloads.HealthCheck
+	switch containerInfo.Type {
+	case general.MediaProxyAgent:
+		agent := config.RunOnce.MediaProxyAgent
+		probe.TCPPort, _ = strconv.Atoi(agent.GRPCPort)
+		probe.StartPeriod = "10s"
+		return agent.HealthCheck, agent.RestartPolicy, probe, agent.Network
+	case general.MediaProxyMCM:
+		mcm := config.RunOnce.MediaProxyMcm[containerInfo.Id]
+		return mcm.HealthCheck, mcm.RestartPolicy, probe, mcm.Network
+	case general.BcsPipelineFfmpeg:
+		pipeline := config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline
+		probe.TCPPort = pipeline.GRPCPort
+		return pipeline.HealthCheck, pipeline.RestartPolicy, probe, pipeline.Network
+	case general.BcsPipelineNmosClient:
+		nmosClient := config.WorkloadToBeRun[containerInfo.Id].NmosClient
+		probe.HTTPPort = nmosClient.NmosPort
+		probe.HTTPPath = nmosNodeAPIPath
+		return nmosClient.HealthCheck, nmosClient.RestartPolicy, probe, nmosClient.Network
+	}
+	return workloads.HealthCheck{}, workloads.RestartPolicy{}, probe, workloads.NetworkConfig{}
+}
+
+// probeHost returns the address the service of a container listens on as seen from inside the container:
+// the configured IP, which the FFmpeg pipeline binds to, otherwise the loopback address.
+func probeHost(networkConfig workloads.NetworkConfig) string {
+	if networkConfig.IP != "" && networkConfig.IP != "host" {
+		return networkConfig.IP
+	}
+	return "127.0.0.1"
+}
+
+// parseHealthDuration parses an optional duration of a health check.
+func parseHealthDuration(field, value, fallback string) (time.Duration, error) {
+	if value == "" {
+		value = fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid healthCheck.%s %q", field, value)
+	}
+	return duration, nil
+}
+
+// ContainerHealthcheck returns the Docker health check of a container. The TCP and HTTP probes run bash in the
+// container, which the MCM and BCS images ship. Nil keeps the health check of the image.
+func ContainerHealthcheck(containerInfo *general.Containers, config *parser.Configuration) (*container.HealthConfig, error) {
+	declared, _, probe, networkConfig := containerHealthSettings(containerInfo, config)
+	if declared.Disable {
+		return &container.HealthConfig{Test: []string{"NONE"}}, nil
+	}
+	probes := 0
+	for _, set := range []bool{declared.Command != "", declared.TCPPort != 0, declared.HTTPPort != 0} {
+		if set {
+			probes++
+		}
+	}
+	if probes > 1 {
+		return nil, fmt.Errorf("healthCheck sets more than one of command, tcpPort and httpPort")
+	}
+	if probes == 1 {
+		probe = workloads.HealthCheck{Command: declared.Command, TCPPort: declared.TCPPort, HTTPPort: declared.HTTPPort, HTTPPath: declared.HTTPPath}
+	}
+
+	startPeriodDefault := defaultHealthCheck.StartPeriod
+	if probe.StartPeriod != "" {
+		startPeriodDefault = probe.StartPeriod
+	}
+	healthConfig := &container.HealthConfig{Retries: declared.Retries}
+	var err error
+	if healthConfig.Interval, err = parseHealthDuration("interval", declared.Interval, defaultHealthCheck.Interval); err != nil {
+		return nil, err
+	}
+	if healthConfig.Timeout, err = parseHealthDuration("timeout", declared.Timeout, defaultHealthCheck.Timeout); err != nil {
+		return nil, err
+	}
+	if healthConfig.StartPeriod, err = parseHealthDuration("startPeriod", declared.StartPeriod, startPeriodDefault); err != nil {
+		return nil, err
+	}
+	if healthConfig.Retries < 0 {
+		return nil, fmt.Errorf("invalid healthCheck.retries %d", healthConfig.Retries)
+	}
+	if healthConfig.Retries == 0 {
+		healthConfig.Retries = defaultHealthCheck.Retries
+	}
+
+	host := probeHost(networkConfig)
+	switch {
+	case probe.Command != "":
+		healthConfig.Test = []string{"CMD-SHELL", probe.Command}
+	case probe.TCPPort > 0:
+		healthConfig.Test = []string{"CMD", "bash", "-c", fmt.Sprintf("exec 3<>/dev/tcp/%s/%d", host, probe.TCPPort)}
+	case probe.HTTPPort > 0:
+		path := probe.HTTPPath
+		if path == "" {
+			path = "/"
+		}
+		healthConfig.Test = []string{"CMD", "bash", "-c", fmt.Sprintf(
+			`exec 3<>/dev/tcp/%s/%d && printf 'GET %s HTTP/1.0\r\nHost: %s\r\n\r\n' >&3 && head -n 1 <&3 | tr -d '\r' | grep -Eq ' [23][0-9][0-9]( |$)'`,
+			host, probe.HTTPPort, path, host)}
+	default:
+		// no probe for the container, Docker only tracks whether it runs
+		return nil, nil
+	}
+	return healthConfig, nil
+}
+
+// ContainerRestartPolicy returns the Docker restart policy of a container, DefaultRestartPolicy when none is declared.
+func ContainerRestartPolicy(containerInfo *general.Containers, config *parser.Configuration) (container.RestartPolicy, error) {
+	_, declared, _, _ := containerHealthSettings(containerInfo, config)
+	policy := container.RestartPolicy{Name: container.RestartPolicyMode(declared.Name), MaximumRetryCount: declared.MaximumRetryCount}
+	if policy.Name == "" {
+		policy.Name = DefaultRestartPolicy
+	}
+	if err := container.ValidateRestartPolicy(policy); err != nil {
+		return container.RestartPolicy{}, fmt.Errorf("invalid restartPolicy: %w", err)
+	}
+	return policy, nil
+}
@@ -0,0 +1,130 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types/container"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+func healthTestConfig() *parser.Configuration {
+	return &parser.Configuration{
+		RunOnce: parser.RunOnce{
+			MediaProxyAgent: workloads.MediaProxyAgentConfig{ImageAndTag: "mcm/mesh-agent:latest", GRPCPort: "50051", RestPort: "8100"},
+			MediaProxyMcm:   workloads.MediaProxyMcmInstances{{ImageAndTag: "mcm/media-proxy:latest"}},
+		},
+		WorkloadToBeRun: []workloads.WorkloadConfig{{
+			FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: "ffmpeg-pipeline", ImageAndTag: "tiber-broadcast-suite:latest", GRPCPort: 50088,
+				Network: workloads.NetworkConfig{Enable: true, Name: "bcs-net", IP: "10.0.0.10"}},
+			NmosClient: workloads.NmosClientConfig{Name: "nmos-client", ImageAndTag: "tiber-broadcast-suite-nmos-node:latest", NmosPort: 5004},
+		}},
+	}
+}
+
+func TestContainerHealthcheck_Defaults(t *testing.T) {
+	config := healthTestConfig()
+
+	agent, err := ContainerHealthcheck(&general.Containers{Type: general.MediaProxyAgent}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, &container.HealthConfig{
+		Test:        []string{"CMD", "bash", "-c", "exec 3<>/dev/tcp/127.0.0.1/50051"},
+		Interval:    10 * time.Second,
+		Timeout:     5 * time.Second,
+		StartPeriod: 10 * time.Second,
+		Retries:     3,
+	}, agent)
+
+	mcm, err := ContainerHealthcheck(&general.Containers{Type: general.MediaProxyMCM}, config)
+	assert.NoError(t, err)
+	assert.Nil(t, mcm)
+
+	// the FFmpeg pipeline listens on its configured IP
+	pipeline, err := ContainerHealthcheck(&general.Containers{Type: general.BcsPipelineFfmpeg}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CMD", "bash", "-c", "exec 3<>/dev/tcp/10.0.0.10/50088"}, pipeline.Test)
+	assert.Equal(t, 30*time.Second, pipeline.StartPeriod)
+
+	nmosClient, err := ContainerHealthcheck(&general.Containers{Type: general.BcsPipelineNmosClient}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CMD", "bash", "-c",
+		`exec 3<>/dev/tcp/127.0.0.1/5004 && printf 'GET /x-nmos/node/ HTTP/1.0\r\nHost: 127.0.0.1\r\n\r\n' >&3 && head -n 1 <&3 | tr -d '\r' | grep -Eq ' [23][0-9][0-9]( |$)'`}, nmosClient.Test)
+}
+
+func TestContainerHealthcheck_Declared(t *testing.T) {
+	config := healthTestConfig()
+	pipeline := &general.Containers{Type: general.BcsPipelineFfmpeg, ContainerName: "ffmpeg-pipeline"}
+
+	config.WorkloadToBeRun[0].FfmpegPipeline.HealthCheck = workloads.HealthCheck{Command: "pgrep ffmpeg", Interval: "30s", Retries: 5}
+	healthcheck, err := ContainerHealthcheck(pipeline, config)
+	assert.NoError(t, err)
+	assert.Equal(t, &container.HealthConfig{
+		Test:        []string{"CMD-SHELL", "pgrep ffmpeg"},
+		Interval:    30 * time.Second,
+		Timeout:     5 * time.Second,
+		StartPeriod: 30 * time.Second,
+		Retries:     5,
+	}, healthcheck)
+
+	config.WorkloadToBeRun[0].FfmpegPipeline.HealthCheck = workloads.HealthCheck{HTTPPort: 8080}
+	healthcheck, err = ContainerHealthcheck(pipeline, config)
+	assert.NoError(t, err)
+	assert.Contains(t, healthcheck.Test[3], "GET / HTTP/1.0")
+
+	config.WorkloadToBeRun[0].FfmpegPipeline.HealthCheck = workloads.HealthCheck{Disable: true}
+	healthcheck, err = ContainerHealthcheck(pipeline, config)
+	assert.NoError(t, err)
+	assert.Equal(t, &container.HealthConfig{Test: []string{"NONE"}}, healthcheck)
+
+	config.WorkloadToBeRun[0].FfmpegPipeline.HealthCheck = workloads.HealthCheck{Command: "true", TCPPort: 50088}
+	_, err = ContainerHealthcheck(pipeline, config)
+	assert.EqualError(t, err, "healthCheck sets more than one of command, tcpPort and httpPort")
+
+	config.WorkloadToBeRun[0].FfmpegPipeline.HealthCheck = workloads.HealthCheck{Timeout: "soon"}
+	_, err = ContainerHealthcheck(pipeline, config)
+	assert.EqualError(t, err, `invalid healthCheck.timeout "soon"`)
+}
+
+func TestContainerRestartPolicy(t *testing.T) {
+	config := healthTestConfig()
+	mcm := &general.Containers{Type: general.MediaProxyMCM}
+
+	policy, err := ContainerRestartPolicy(mcm, config)
+	assert.NoError(t, err)
+	assert.Equal(t, container.RestartPolicy{Name: container.RestartPolicyUnlessStopped}, policy)
+
+	config.RunOnce.MediaProxyMcm[0].RestartPolicy = workloads.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}
+	policy, err = ContainerRestartPolicy(mcm, config)
+	assert.NoError(t, err)
+	assert.Equal(t, container.RestartPolicy{Name: container.RestartPolicyOnFailure, MaximumRetryCount: 3}, policy)
+
+	config.RunOnce.MediaProxyMcm[0].RestartPolicy = workloads.RestartPolicy{Name: "always", MaximumRetryCount: 3}
+	_, err = ContainerRestartPolicy(mcm, config)
+	assert.ErrorContains(t, err, "invalid restartPolicy")
+
+	config.RunOnce.MediaProxyMcm[0].RestartPolicy = workloads.RestartPolicy{Name: "sometimes"}
+	_, err = ContainerRestartPolicy(mcm, config)
+	assert.ErrorContains(t, err, "invalid restartPolicy")
+}
+
+func TestConstructContainerConfig_Health(t *testing.T) {
+	config := healthTestConfig()
+	config.WorkloadToBeRun[0].FfmpegPipeline.RestartPolicy = workloads.RestartPolicy{Name: "sometimes"}
+
+	containerConfig, hostConfig, _ := PreviewContainerConfig(&general.Containers{Type: general.MediaProxyAgent}, config, logr.Discard())
+	assert.Equal(t, []string{"CMD", "bash", "-c", "exec 3<>/dev/tcp/127.0.0.1/50051"}, containerConfig.Healthcheck.Test)
+	assert.Equal(t, container.RestartPolicy{Name: container.RestartPolicyUnlessStopped}, hostConfig.RestartPolicy)
+
+	// invalid settings are reported by the validation before starting, the container gets the defaults
+	_, hostConfig, _ = PreviewContainerConfig(&general.Containers{Type: general.BcsPipelineFfmpeg}, config, logr.Discard())
+	assert.Equal(t, container.RestartPolicy{Name: container.RestartPolicyUnlessStopped}, hostConfig.RestartPolicy)
+}
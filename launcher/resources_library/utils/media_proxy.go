@@ -0,0 +1,75 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+)
+
+// defaultMediaProxySDKPort is the port MCM Media Proxy serves the SDK on when its instance sets no sdkPort.
+const defaultMediaProxySDKPort = "8002"
+
+// MediaProxySDKPort returns the port an MCM Media Proxy instance serves the SDK on.
+func MediaProxySDKPort(instance workloads.MediaProxyMcmConfig) string {
+	if instance.SDKPort != "" {
+		return instance.SDKPort
+	}
+	return defaultMediaProxySDKPort
+}
+
+// ValidateMediaProxies checks that the MCM Media Proxy instances have distinct container names, which requires
+// every instance but one to be named, and that the workloads only reference declared instances.
+func ValidateMediaProxies(config *parser.Configuration) error {
+	var errs []error
+	seen := map[string]bool{}
+	for _, instance := range config.RunOnce.MediaProxyMcm {
+		name := instance.ContainerName()
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("mediaProxyMcm: duplicate instance name %q", name))
+		}
+		seen[name] = true
+	}
+	for n, workload := range config.WorkloadToBeRun {
+		if workload.MediaProxy != "" && !seen[workload.MediaProxy] {
+			errs = append(errs, fmt.Errorf("workloadToBeRun[%d]: unknown mediaProxy %q", n, workload.MediaProxy))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pipelineEnvironment returns the environment variables of the FFmpeg pipeline of a workload. A workload referencing
+// an MCM Media Proxy instance gets the address of the instance in MCM_MEDIA_PROXY_IP and MCM_MEDIA_PROXY_PORT,
+// unless it sets them itself.
+func pipelineEnvironment(config *parser.Configuration, id int) []string {
+	workload := config.WorkloadToBeRun[id]
+	environment := workload.FfmpegPipeline.EnvironmentVariables
+	index := config.RunOnce.MediaProxyMcm.Find(workload.MediaProxy)
+	if workload.MediaProxy == "" || index < 0 {
+		return environment
+	}
+	instance := config.RunOnce.MediaProxyMcm[index]
+	address := map[string]string{"MCM_MEDIA_PROXY_IP": "127.0.0.1", "MCM_MEDIA_PROXY_PORT": MediaProxySDKPort(instance)}
+	if instance.Network.Enable && instance.Network.IP != "" {
+		address["MCM_MEDIA_PROXY_IP"] = instance.Network.IP
+	}
+	environment = append([]string{}, environment...)
+	for _, variable := range workload.FfmpegPipeline.EnvironmentVariables {
+		name, _, _ := strings.Cut(variable, "=")
+		delete(address, name)
+	}
+	for _, name := range []string{"MCM_MEDIA_PROXY_IP", "MCM_MEDIA_PROXY_PORT"} {
+		if value, ok := address[name]; ok {
+			environment = append(environment, name+"="+value)
+		}
+	}
+	return environment
+}
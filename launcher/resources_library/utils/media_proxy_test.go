@@ -0,0 +1,52 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package utils
+
+import (
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/stretchr/testify/assert"
+)
+
+func mediaProxyTestConfig() *parser.Configuration {
+	return &parser.Configuration{
+		RunOnce: parser.RunOnce{
+			MediaProxyMcm: workloads.MediaProxyMcmInstances{
+				{ImageAndTag: "mcm/media-proxy:latest", InterfaceName: "eth0"},
+				{Name: "media-proxy-nic1", ImageAndTag: "mcm/media-proxy:latest", InterfaceName: "eth1", SDKPort: "8003",
+					Network: workloads.NetworkConfig{Enable: true, Name: "bcs-net", IP: "10.0.0.3"}},
+			},
+		},
+		WorkloadToBeRun: []workloads.WorkloadConfig{
+			{FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: "ffmpeg-pipeline-0", EnvironmentVariables: []string{"VFIO_PORT_TX=0000:31:01.0"}}},
+			{FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: "ffmpeg-pipeline-1", EnvironmentVariables: []string{"MCM_MEDIA_PROXY_PORT=9000"}}, MediaProxy: "media-proxy-nic1"},
+			{FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: "ffmpeg-pipeline-2"}, MediaProxy: "media-proxy"},
+		},
+	}
+}
+
+func TestValidateMediaProxies(t *testing.T) {
+	config := mediaProxyTestConfig()
+	assert.NoError(t, ValidateMediaProxies(config))
+
+	config.RunOnce.MediaProxyMcm[1].Name = ""
+	config.WorkloadToBeRun[0].MediaProxy = "media-proxy-nic2"
+	err := ValidateMediaProxies(config)
+	assert.EqualError(t, err, "mediaProxyMcm: duplicate instance name \"media-proxy\"\n"+
+		"workloadToBeRun[0]: unknown mediaProxy \"media-proxy-nic2\"\n"+
+		"workloadToBeRun[1]: unknown mediaProxy \"media-proxy-nic1\"")
+}
+
+func TestPipelineEnvironment(t *testing.T) {
+	config := mediaProxyTestConfig()
+
+	assert.Equal(t, []string{"VFIO_PORT_TX=0000:31:01.0"}, pipelineEnvironment(config, 0), "workloads without mediaProxy are left alone")
+	assert.Equal(t, []string{"MCM_MEDIA_PROXY_PORT=9000", "MCM_MEDIA_PROXY_IP=10.0.0.3"}, pipelineEnvironment(config, 1))
+	assert.Equal(t, []string{"MCM_MEDIA_PROXY_IP=127.0.0.1", "MCM_MEDIA_PROXY_PORT=8002"}, pipelineEnvironment(config, 2))
+	assert.Equal(t, []string{"MCM_MEDIA_PROXY_PORT=9000"}, config.WorkloadToBeRun[1].FfmpegPipeline.EnvironmentVariables, "the configuration is not modified")
+}
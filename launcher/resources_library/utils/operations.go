@@ -24,7 +24,6 @@ import (
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/go-connections/nat"
 	"github.com/go-logr/logr"
-	"gopkg.in/yaml.v2"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -36,25 +35,59 @@ import (
 	bcsv1 "bcs.pod.launcher.intel/api/v1"
 )
 
+// updateNmosJsonFile writes the NMOS json file with the FFmpeg gRPC server address and port replaced. A file with
+// references to environment variables or secret files is written to its rendered file instead, see nmosRenderedPath.
 func updateNmosJsonFile(filePath string, ip string, port string) error {
+	updatedJson, err := RenderNmosJsonFile(filePath, ip, port)
+	if err != nil {
+		return err
+	}
+
+	target, perm := nmosRenderedPath(filePath), os.FileMode(0644)
+	if target != filePath {
+		perm = 0600 // it may hold secrets
+	}
+	err = os.WriteFile(target, updatedJson, perm)
+	if err != nil {
+		fmt.Println("Error writing to file:", err)
+		return err
+	}
+
+	fmt.Println("Updated configuration saved to ", target)
+	return nil
+}
+
+// RenderNmosJsonFile returns the content of the NMOS json file with its references to environment variables and
+// secret files resolved and the FFmpeg gRPC server address and port replaced, without writing it back to the file.
+func RenderNmosJsonFile(filePath string, ip string, port string) ([]byte, error) {
+	return renderNmosJsonFile(filePath, ip, port, parser.UnmarshalInterpolatedJSON)
+}
+
+// PreviewNmosJsonFile returns the content of the NMOS json file like RenderNmosJsonFile, but its string values keep
+// their references, so it can be printed without disclosing the secrets.
+func PreviewNmosJsonFile(filePath string, ip string, port string) ([]byte, error) {
+	return renderNmosJsonFile(filePath, ip, port, parser.UnmarshalRedactedJSON)
+}
+
+func renderNmosJsonFile(filePath string, ip string, port string, unmarshal func(string, []byte, interface{}) error) ([]byte, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
 	byteValue, err := io.ReadAll(file)
 	if err != nil {
 		fmt.Println("Error reading file:", err)
-		return err
+		return nil, err
 	}
 
 	var config nmos.Config
-	err = json.Unmarshal(byteValue, &config)
+	err = unmarshal(filePath, byteValue, &config)
 	if err != nil {
 		fmt.Println("Error unmarshalling JSON:", err)
-		return err
+		return nil, err
 	}
 
 	config.FfmpegGrpcServerAddress = ip
@@ -63,17 +96,31 @@ func updateNmosJsonFile(filePath string, ip string, port string) error {
 	updatedJson, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		fmt.Println("Error marshalling JSON:", err)
-		return err
+		return nil, err
 	}
+	return updatedJson, nil
+}
 
-	err = os.WriteFile(filePath, updatedJson, 0644)
-	if err != nil {
-		fmt.Println("Error writing to file:", err)
-		return err
-	}
+// NmosJsonFilePath returns the path of the NMOS json file used by the NMOS client of the given workload.
+func NmosJsonFilePath(config *parser.Configuration, id int) string {
+	return config.WorkloadToBeRun[id].NmosClient.NmosConfigPath + "/" + config.WorkloadToBeRun[id].NmosClient.NmosConfigFileName
+}
 
-	fmt.Println("Updated configuration saved to ", filePath)
-	return nil
+// NmosRenderedFilePath returns the NMOS json file the NMOS client of the given workload is started with, see
+// nmosRenderedPath.
+func NmosRenderedFilePath(config *parser.Configuration, id int) string {
+	return nmosRenderedPath(NmosJsonFilePath(config, id))
+}
+
+// nmosRenderedPath returns the file an NMOS json file is rendered to: the file itself or, when it references
+// environment variables or secret files, <name>.rendered.json next to it, so the references are kept for the next
+// run and the secrets stay out of the file that is edited.
+func nmosRenderedPath(filePath string) string {
+	data, err := os.ReadFile(filePath)
+	if err != nil || !parser.HasReferences(data) {
+		return filePath
+	}
+	return strings.TrimSuffix(filePath, ".json") + ".rendered.json"
 }
 
 func FileExists(filePath string) bool {
@@ -82,13 +129,25 @@ func FileExists(filePath string) bool {
 }
 
 func ConstructContainerConfig(containerInfo *general.Containers, config *parser.Configuration, log logr.Logger) (*container.Config, *container.HostConfig, *network.NetworkingConfig) {
+	return constructContainerConfig(containerInfo, config, log, false)
+}
+
+// PreviewContainerConfig returns the same configuration as ConstructContainerConfig without any side effects:
+// the NMOS json file is not rewritten and nothing is printed to the standard output.
+func PreviewContainerConfig(containerInfo *general.Containers, config *parser.Configuration, log logr.Logger) (*container.Config, *container.HostConfig, *network.NetworkingConfig) {
+	return constructContainerConfig(containerInfo, config, log, true)
+}
+
+func constructContainerConfig(containerInfo *general.Containers, config *parser.Configuration, log logr.Logger, dryRun bool) (*container.Config, *container.HostConfig, *network.NetworkingConfig) {
 	var containerConfig *container.Config
 	var hostConfig *container.HostConfig
 	var networkConfig *network.NetworkingConfig
 
 	switch containerInfo.Type {
 	case general.MediaProxyAgent:
-		fmt.Printf(">> MediaProxyAgentConfig: %+v\n", config.RunOnce.MediaProxyAgent)
+		if !dryRun {
+			fmt.Printf(">> MediaProxyAgentConfig: %+v\n", config.RunOnce.MediaProxyAgent)
+		}
 		containerConfig = &container.Config{
 			User:  "root",
 			Image: config.RunOnce.MediaProxyAgent.ImageAndTag,
@@ -100,7 +159,6 @@ func ConstructContainerConfig(containerInfo *general.Containers, config *parser.
 		}
 
 		hostConfig = &container.HostConfig{
-			Privileged: true,
 			PortBindings: nat.PortMap{
 				nat.Port(fmt.Sprintf("%s/tcp", config.RunOnce.MediaProxyAgent.RestPort)): []nat.PortBinding{{HostPort: config.RunOnce.MediaProxyAgent.RestPort}},
 				nat.Port(fmt.Sprintf("%s/tcp", config.RunOnce.MediaProxyAgent.GRPCPort)): []nat.PortBinding{{HostPort: config.RunOnce.MediaProxyAgent.GRPCPort}},
@@ -122,25 +180,30 @@ func ConstructContainerConfig(containerInfo *general.Containers, config *parser.
 			hostConfig.NetworkMode = "host"
 		}
 	case general.MediaProxyMCM:
-		fmt.Printf(">> MediaProxyMcmConfig: %+v\n", config.RunOnce.MediaProxyMcm)
+		mcm := config.RunOnce.MediaProxyMcm[containerInfo.Id]
+		if !dryRun {
+			fmt.Printf(">> MediaProxyMcmConfig: %+v\n", mcm)
+		}
 		containerConfig = &container.Config{
-			Image: config.RunOnce.MediaProxyMcm.ImageAndTag,
-			Cmd:   []string{"-d", fmt.Sprintf("kernel:%s", config.RunOnce.MediaProxyMcm.InterfaceName), "-i", "localhost"},
+			Image: mcm.ImageAndTag,
+			Cmd:   []string{"-d", fmt.Sprintf("kernel:%s", mcm.InterfaceName), "-i", "localhost"},
+		}
+		if mcm.SDKPort != "" {
+			containerConfig.Cmd = append(containerConfig.Cmd, "-t", mcm.SDKPort)
 		}
 
 		hostConfig = &container.HostConfig{
-			Privileged: true,
-			Binds:      config.RunOnce.MediaProxyMcm.Volumes,
+			Binds: mcm.Volumes,
 		}
 
-		if config.RunOnce.MediaProxyMcm.Network.Enable {
-			hostConfig.NetworkMode = container.NetworkMode(config.RunOnce.MediaProxyMcm.Network.Name)
+		if mcm.Network.Enable {
+			hostConfig.NetworkMode = container.NetworkMode(mcm.Network.Name)
 
 			networkConfig = &network.NetworkingConfig{
 				EndpointsConfig: map[string]*network.EndpointSettings{
-					config.RunOnce.MediaProxyMcm.Network.Name: {
+					mcm.Network.Name: {
 						IPAMConfig: &network.EndpointIPAMConfig{
-							IPv4Address: config.RunOnce.MediaProxyMcm.Network.IP,
+							IPv4Address: mcm.Network.IP,
 						},
 					},
 				},
@@ -150,12 +213,14 @@ func ConstructContainerConfig(containerInfo *general.Containers, config *parser.
 			hostConfig.NetworkMode = "host"
 		}
 	case general.BcsPipelineFfmpeg:
-		fmt.Printf("\n>>> BcsPipelineFfmpeg: %+v\n", config.WorkloadToBeRun[containerInfo.Id])
+		if !dryRun {
+			fmt.Printf("\n>>> BcsPipelineFfmpeg: %+v\n", config.WorkloadToBeRun[containerInfo.Id])
+		}
 
 		containerConfig = &container.Config{
 			User:  "root",
 			Image: config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.ImageAndTag,
-			Env:   config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.EnvironmentVariables,
+			Env:   pipelineEnvironment(config, containerInfo.Id),
 			ExposedPorts: nat.PortSet{
 				nat.Port(fmt.Sprintf("%d/tcp", config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.GRPCPort)): struct{}{},
 			},
@@ -164,8 +229,6 @@ func ConstructContainerConfig(containerInfo *general.Containers, config *parser.
 		containerConfig.Cmd = []string{config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.Network.IP, fmt.Sprintf("%d", config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.GRPCPort)}
 
 		hostConfig = &container.HostConfig{
-			Privileged: true,
-			CapAdd:     []string{"ALL"},
 			PortBindings: nat.PortMap{
 				nat.Port(fmt.Sprintf("%d/tcp", config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.GRPCPort)): []nat.PortBinding{
 					{
@@ -206,16 +269,19 @@ func ConstructContainerConfig(containerInfo *general.Containers, config *parser.
 			hostConfig.NetworkMode = "host"
 		}
 	case general.BcsPipelineNmosClient:
-		nmosFileNameJson := config.WorkloadToBeRun[containerInfo.Id].NmosClient.NmosConfigFileName
-		nmosFilePathJson := config.WorkloadToBeRun[containerInfo.Id].NmosClient.NmosConfigPath + "/" + nmosFileNameJson
-		if !FileExists(nmosFilePathJson) {
-			log.Error(errors.New("NMOS json file does not exist"), "NMOS json file does not exist")
-		}
-		errUpdateJson := updateNmosJsonFile(nmosFilePathJson,
-			config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.Network.IP,
-			strconv.Itoa(config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.GRPCPort))
-		if errUpdateJson != nil {
-			log.Error(errUpdateJson, "Error updating NMOS json file")
+		nmosFilePathJson := NmosJsonFilePath(config, containerInfo.Id)
+		// the NMOS configuration directory is mounted to /home/config, the rendered file is next to the NMOS json file
+		nmosFileNameJson := strings.TrimPrefix(NmosRenderedFilePath(config, containerInfo.Id), config.WorkloadToBeRun[containerInfo.Id].NmosClient.NmosConfigPath+"/")
+		if !dryRun {
+			if !FileExists(nmosFilePathJson) {
+				log.Error(errors.New("NMOS json file does not exist"), "NMOS json file does not exist")
+			}
+			errUpdateJson := updateNmosJsonFile(nmosFilePathJson,
+				config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.Network.IP,
+				strconv.Itoa(config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.GRPCPort))
+			if errUpdateJson != nil {
+				log.Error(errUpdateJson, "Error updating NMOS json file")
+			}
 		}
 		configPathContainer := "config/" + nmosFileNameJson
 		containerConfig = &container.Config{
@@ -229,7 +295,6 @@ func ConstructContainerConfig(containerInfo *general.Containers, config *parser.
 		}
 
 		hostConfig = &container.HostConfig{
-			Privileged: true,
 			PortBindings: nat.PortMap{
 				nat.Port(fmt.Sprintf("%d/tcp", config.WorkloadToBeRun[containerInfo.Id].NmosClient.NmosPort)): []nat.PortBinding{
 					{
@@ -260,9 +325,55 @@ func ConstructContainerConfig(containerInfo *general.Containers, config *parser.
 		containerConfig, hostConfig, networkConfig = nil, nil, nil
 	}
 
+	if containerConfig != nil {
+		containerConfig.Labels = OwnershipLabels(containerInfo, config)
+		healthcheck, err := ContainerHealthcheck(containerInfo, config)
+		if err != nil {
+			log.Error(err, "Ignoring invalid health check of container", "container", containerInfo.ContainerName)
+		} else {
+			containerConfig.Healthcheck = healthcheck
+		}
+	}
+	if hostConfig != nil {
+		profile, err := ContainerSecurityProfile(containerInfo, config)
+		if err != nil {
+			log.Error(err, "Falling back to the minimal security profile", "container", containerInfo.ContainerName)
+			profile = SecurityProfileMinimal
+		}
+		applySecurityProfile(hostConfig, containerInfo.Type, profile)
+
+		restartPolicy, err := ContainerRestartPolicy(containerInfo, config)
+		if err != nil {
+			log.Error(err, "Falling back to the default restart policy", "container", containerInfo.ContainerName)
+			restartPolicy = container.RestartPolicy{Name: DefaultRestartPolicy}
+		}
+		hostConfig.RestartPolicy = restartPolicy
+
+		resources, err := ContainerResources(WorkloadResources(containerInfo, config))
+		if err != nil {
+			log.Error(err, "Ignoring invalid resources of container", "container", containerInfo.ContainerName)
+		} else {
+			applyResources(hostConfig, resources)
+		}
+	}
 	return containerConfig, hostConfig, networkConfig
 }
 
+// OwnershipLabels returns the labels identifying the container as owned by the launcher.
+func OwnershipLabels(containerInfo *general.Containers, config *parser.Configuration) map[string]string {
+	labels := map[string]string{general.LabelRole: containerInfo.Type.String()}
+	if config.LauncherID != "" {
+		labels[general.LabelLauncherID] = config.LauncherID
+	}
+	if config.ConfigFile != "" {
+		labels[general.LabelConfigFile] = config.ConfigFile
+	}
+	if containerInfo.Type == general.BcsPipelineFfmpeg || containerInfo.Type == general.BcsPipelineNmosClient {
+		labels[general.LabelWorkloadIndex] = strconv.Itoa(containerInfo.Id)
+	}
+	return labels
+}
+
 func boolPtr(b bool) *bool { return &b }
 
 type K8sConfig struct {
@@ -309,9 +420,11 @@ type K8sConfig struct {
 	} `yaml:"definition"`
 }
 
+// UnmarshalK8sConfig reads the config.yaml of the launcher ConfigMap. Unknown keys and values of the wrong type are
+// rejected with their position in it, see parser.UnmarshalStrict.
 func UnmarshalK8sConfig(yamlData []byte) (*K8sConfig, error) {
 	var config K8sConfig
-	err := yaml.Unmarshal(yamlData, &config)
+	err := parser.UnmarshalStrict("config.yaml", yamlData, &config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
 	}
@@ -657,6 +770,11 @@ func CreateConfigMap(bcs *bcsv1.BcsConfigSpec) *corev1.ConfigMap {
 }
 
 func CreateBcsDeployment(bcs *bcsv1.BcsConfigSpec) *appsv1.Deployment {
+	// the CRD only admits the known profiles
+	profile, err := BcsSecurityProfile(bcs)
+	if err != nil {
+		profile = SecurityProfileMinimal
+	}
 
 	// Assign default values if CPU or Memory requests/limits are empty for containers
 	if bcs.Nmos.Resources.Requests.CPU == "" {
@@ -709,13 +827,7 @@ func CreateBcsDeployment(bcs *bcsv1.BcsConfigSpec) *appsv1.Deployment {
 							Image:           bcs.Nmos.Image,
 							ImagePullPolicy: corev1.PullIfNotPresent,
 							Args:            bcs.Nmos.Args,
-							SecurityContext: &corev1.SecurityContext{
-								RunAsUser:  int64Ptr(0),
-								Privileged: boolPtr(true),
-								Capabilities: &corev1.Capabilities{
-									Add: []corev1.Capability{"ALL"},
-								},
-							},
+							SecurityContext: K8sSecurityContext(profile, false),
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "config",
@@ -743,13 +855,7 @@ func CreateBcsDeployment(bcs *bcsv1.BcsConfigSpec) *appsv1.Deployment {
 							Image:           bcs.App.Image,
 							ImagePullPolicy: corev1.PullIfNotPresent,
 							Args:            []string{"localhost", fmt.Sprintf("%d", bcs.App.GrpcPort)},
-							SecurityContext: &corev1.SecurityContext{
-								RunAsUser:  int64Ptr(0),
-								Privileged: boolPtr(true),
-								Capabilities: &corev1.Capabilities{
-									Add: []corev1.Capability{"ALL"},
-								},
-							},
+							SecurityContext: K8sSecurityContext(profile, true),
 							VolumeMounts: []corev1.VolumeMount{
 								{Name: "videos", MountPath: "/videos"},
 								{Name: "dri", MountPath: "/usr/local/lib/x86_64-linux-gnu/dri"},
@@ -819,6 +925,30 @@ func CreateBcsDeployment(bcs *bcsv1.BcsConfigSpec) *appsv1.Deployment {
 		bcsDeploy.Spec.Template.Spec.Containers[1].Resources.Limits[corev1.ResourceHugePagesPrefix+"2Mi"] = resource.MustParse("2Mi")
 	}
 
+	// Extended resources are requested and limited to the same count
+	for name, count := range bcs.App.Devices {
+		bcsDeploy.Spec.Template.Spec.Containers[1].Resources.Requests[corev1.ResourceName(name)] = *resource.NewQuantity(count, resource.DecimalSI)
+		bcsDeploy.Spec.Template.Spec.Containers[1].Resources.Limits[corev1.ResourceName(name)] = *resource.NewQuantity(count, resource.DecimalSI)
+	}
+	// The device plugins create the device nodes, the hostPath volumes would hide them
+	if profile == SecurityProfileMinimal {
+		podSpec := &bcsDeploy.Spec.Template.Spec
+		mounts := podSpec.Containers[1].VolumeMounts[:0]
+		for _, mount := range podSpec.Containers[1].VolumeMounts {
+			if !deviceVolumes[mount.Name] {
+				mounts = append(mounts, mount)
+			}
+		}
+		podSpec.Containers[1].VolumeMounts = mounts
+		volumes := podSpec.Volumes[:0]
+		for _, volume := range podSpec.Volumes {
+			if !deviceVolumes[volume.Name] {
+				volumes = append(volumes, volume)
+			}
+		}
+		podSpec.Volumes = volumes
+	}
+
 	affinity := &v1.Affinity{}
 	AssignNodeAffinityFromConfig(affinity, bcs.ScheduleOnNode)
 	bcsDeploy.Spec.Template.Spec.Affinity = affinity
@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	bcsv1 "bcs.pod.launcher.intel/api/v1"
@@ -18,8 +19,10 @@ import (
 
 	"bcs.pod.launcher.intel/resources_library/resources/nmos"
 	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types/strslice"
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -82,6 +85,112 @@ func TestUpdateNmosJsonFile_InvalidJson(t *testing.T) {
 	assert.Contains(t, err.Error(), "invalid character")
 }
 
+func TestRenderNmosJsonFile(t *testing.T) {
+	tempFile, err := os.CreateTemp("", "nmos_test_render_*.json")
+	assert.NoError(t, err)
+	defer os.Remove(tempFile.Name())
+
+	jsonData := `{"ffmpeg_grpc_server_address": "old-address", "ffmpeg_grpc_server_port": "50051"}`
+	_, err = tempFile.Write([]byte(jsonData))
+	assert.NoError(t, err)
+	tempFile.Close()
+
+	rendered, err := RenderNmosJsonFile(tempFile.Name(), "new-address", "50052")
+	assert.NoError(t, err)
+
+	var config map[string]interface{}
+	err = json.Unmarshal(rendered, &config)
+	assert.NoError(t, err)
+	assert.Equal(t, "new-address", config["ffmpeg_grpc_server_address"])
+	assert.Equal(t, "50052", config["ffmpeg_grpc_server_port"])
+
+	onDisk, err := os.ReadFile(tempFile.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, jsonData, string(onDisk))
+}
+
+func TestUpdateNmosJsonFile_References(t *testing.T) {
+	t.Setenv("NMOS_HTTP_PORT", "5004")
+	t.Setenv("NMOS_LABEL", "intel-tx")
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "intel-node-tx.json")
+	jsonData := `{"http_port": "${NMOS_HTTP_PORT}", "label": "${NMOS_LABEL}", "ffmpeg_grpc_server_port": "50051"}`
+	assert.NoError(t, os.WriteFile(filePath, []byte(jsonData), 0644))
+
+	err := updateNmosJsonFile(filePath, "new-address", "50052")
+	assert.NoError(t, err)
+
+	onDisk, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, jsonData, string(onDisk), "the references are kept for the next run")
+
+	renderedPath := nmosRenderedPath(filePath)
+	assert.Equal(t, filepath.Join(dir, "intel-node-tx.rendered.json"), renderedPath)
+	info, err := os.Stat(renderedPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	rendered, err := os.ReadFile(renderedPath)
+	assert.NoError(t, err)
+	var config map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rendered, &config))
+	assert.Equal(t, float64(5004), config["http_port"])
+	assert.Equal(t, "intel-tx", config["label"])
+	assert.Equal(t, "new-address", config["ffmpeg_grpc_server_address"])
+}
+
+func TestPreviewContainerConfig(t *testing.T) {
+	nmosDir := t.TempDir()
+	jsonData := `{"ffmpeg_grpc_server_address": "old-address", "ffmpeg_grpc_server_port": "50051"}`
+	assert.NoError(t, os.WriteFile(nmosDir+"/nmos.json", []byte(jsonData), 0644))
+
+	containerInfo := &general.Containers{Type: general.BcsPipelineNmosClient, Id: 0}
+	config := &parser.Configuration{
+		WorkloadToBeRun: []workloads.WorkloadConfig{
+			{
+				FfmpegPipeline: workloads.FfmpegPipelineConfig{
+					GRPCPort: 50051,
+					Network:  workloads.NetworkConfig{IP: "192.168.1.102"},
+				},
+				NmosClient: workloads.NmosClientConfig{
+					ImageAndTag:        "nmosclient:latest",
+					NmosConfigPath:     nmosDir,
+					NmosConfigFileName: "nmos.json",
+					NmosPort:           8080,
+				},
+			},
+		},
+	}
+
+	previewConfig, previewHostConfig, previewNetworkConfig := PreviewContainerConfig(containerInfo, config, logr.Discard())
+	onDisk, err := os.ReadFile(nmosDir + "/nmos.json")
+	assert.NoError(t, err)
+	assert.Equal(t, jsonData, string(onDisk))
+
+	containerConfig, hostConfig, networkConfig := ConstructContainerConfig(containerInfo, config, logr.Discard())
+	assert.Equal(t, containerConfig, previewConfig)
+	assert.Equal(t, hostConfig, previewHostConfig)
+	assert.Equal(t, networkConfig, previewNetworkConfig)
+}
+
+func TestOwnershipLabels(t *testing.T) {
+	config := &parser.Configuration{LauncherID: "studio-a", ConfigFile: "/etc/bcs/launcher.yaml"}
+
+	labels := OwnershipLabels(&general.Containers{Type: general.BcsPipelineNmosClient, Id: 2}, config)
+	assert.Equal(t, map[string]string{
+		general.LabelLauncherID:    "studio-a",
+		general.LabelConfigFile:    "/etc/bcs/launcher.yaml",
+		general.LabelWorkloadIndex: "2",
+		general.LabelRole:          "BcsPipelineNmosClient",
+	}, labels)
+
+	labels = OwnershipLabels(&general.Containers{Type: general.MediaProxyAgent}, &parser.Configuration{})
+	assert.Equal(t, map[string]string{general.LabelRole: "MediaProxyAgent"}, labels)
+
+	containerConfig, _, _ := ConstructContainerConfig(&general.Containers{Type: general.MediaProxyAgent}, config, logr.Discard())
+	assert.Equal(t, "studio-a", containerConfig.Labels[general.LabelLauncherID])
+}
+
 func TestConstructContainerConfig(t *testing.T) {
 	log := logr.Discard()
 
@@ -161,7 +270,7 @@ func TestConstructContainerConfig(t *testing.T) {
 		containerInfo := &general.Containers{Type: general.MediaProxyMCM}
 		config := &parser.Configuration{
 			RunOnce: parser.RunOnce{
-				MediaProxyMcm: workloads.MediaProxyMcmConfig{
+				MediaProxyMcm: workloads.MediaProxyMcmInstances{{
 					ImageAndTag:   "mediaproxymcm:latest",
 					InterfaceName: "eth0",
 					Volumes:       []string{"/host/path:/container/path"},
@@ -170,7 +279,7 @@ func TestConstructContainerConfig(t *testing.T) {
 						Name:   "test-network",
 						IP:     "192.168.1.101",
 					},
-				},
+				}},
 			},
 		}
 
@@ -190,7 +299,7 @@ func TestConstructContainerConfig(t *testing.T) {
 		containerInfo := &general.Containers{Type: general.MediaProxyMCM}
 		config := &parser.Configuration{
 			RunOnce: parser.RunOnce{
-				MediaProxyMcm: workloads.MediaProxyMcmConfig{
+				MediaProxyMcm: workloads.MediaProxyMcmInstances{{
 					ImageAndTag:   "mediaproxymcm:latest",
 					InterfaceName: "eth0",
 					Volumes:       []string{"/host/path:/container/path"},
@@ -198,7 +307,7 @@ func TestConstructContainerConfig(t *testing.T) {
 						Enable: false,
 						IP:     "192.168.1.101",
 					},
-				},
+				}},
 			},
 		}
 
@@ -214,6 +323,22 @@ func TestConstructContainerConfig(t *testing.T) {
 		assert.Empty(t, networkConfig.EndpointsConfig)
 	})
 
+	t.Run("MediaProxyMCMInstance", func(t *testing.T) {
+		containerInfo := &general.Containers{Type: general.MediaProxyMCM, Id: 1}
+		config := &parser.Configuration{
+			RunOnce: parser.RunOnce{
+				MediaProxyMcm: workloads.MediaProxyMcmInstances{
+					{ImageAndTag: "mediaproxymcm:latest", InterfaceName: "eth0"},
+					{Name: "media-proxy-nic1", ImageAndTag: "mediaproxymcm:latest", InterfaceName: "eth1", SDKPort: "8003"},
+				},
+			},
+		}
+
+		containerConfig, _, _ := ConstructContainerConfig(containerInfo, config, log)
+
+		assert.Equal(t, strslice.StrSlice{"-d", "kernel:eth1", "-i", "localhost", "-t", "8003"}, containerConfig.Cmd)
+	})
+
 	t.Run("BcsPipelineFfmpegConfig", func(t *testing.T) {
 		containerInfo := &general.Containers{Type: general.BcsPipelineFfmpeg, Id: 0}
 		config := &parser.Configuration{
@@ -478,6 +603,35 @@ definition:
 	assert.Nil(t, config)
 	assert.Contains(t, err.Error(), "failed to unmarshal YAML")
 }
+func TestUnmarshalK8sConfig_UnknownField(t *testing.T) {
+	yamlData := `k8s: true
+definition:
+  meshAgent:
+    image: "mesh-agent:latest"
+    requests:
+      cpu: "500m"
+  mediaProxy:
+    sdkport: 8002
+`
+	config, err := UnmarshalK8sConfig([]byte(yamlData))
+	assert.Nil(t, config)
+	assert.EqualError(t, err, `failed to unmarshal YAML: config.yaml:5:5: definition.meshAgent: unknown field "requests"
+config.yaml:8:5: definition.mediaProxy: unknown field "sdkport", did you mean "sdkPort"?`)
+}
+
+func TestUnmarshalK8sConfig_ShippedConfigMap(t *testing.T) {
+	data, err := os.ReadFile("../../configuration_files/bcslauncher-k8s-config-map.yaml")
+	assert.NoError(t, err)
+	var cm struct {
+		Data map[string]string `yaml:"data"`
+	}
+	assert.NoError(t, yaml.Unmarshal(data, &cm))
+
+	config, err := UnmarshalK8sConfig([]byte(cm.Data["config.yaml"]))
+	assert.NoError(t, err)
+	assert.Equal(t, "1Gi", config.Definition.MediaProxy.Resources.Requests.Hugepages1Gi)
+}
+
 func TestCreateMtlManagerDeployment(t *testing.T) {
 	t.Run("ValidConfigMap", func(t *testing.T) {
 		cm := &corev1.ConfigMap{
@@ -0,0 +1,93 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package utils
+
+import (
+	"fmt"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types/container"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// WorkloadResources returns the resources block declared for a container in the launcher configuration.
+func WorkloadResources(containerInfo *general.Containers, config *parser.Configuration) workloads.Resources {
+	switch containerInfo.Type {
+	case general.MediaProxyAgent:
+		return config.RunOnce.MediaProxyAgent.Resources
+	case general.MediaProxyMCM:
+		return config.RunOnce.MediaProxyMcm[containerInfo.Id].Resources
+	case general.BcsPipelineFfmpeg:
+		return config.WorkloadToBeRun[containerInfo.Id].FfmpegPipeline.Resources
+	case general.BcsPipelineNmosClient:
+		return config.WorkloadToBeRun[containerInfo.Id].NmosClient.Resources
+	}
+	return workloads.Resources{}
+}
+
+// parseQuantity parses an optional Kubernetes quantity, an empty value is returned as zero.
+func parseQuantity(field, value string) (resource.Quantity, error) {
+	if value == "" {
+		return resource.Quantity{}, nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return quantity, fmt.Errorf("invalid resources.%s %q: %w", field, value, err)
+	}
+	if quantity.Sign() < 0 {
+		return quantity, fmt.Errorf("invalid resources.%s %q: must not be negative", field, value)
+	}
+	return quantity, nil
+}
+
+// ContainerResources maps a resources block onto the Docker resources of a container the way the kubelet does:
+// the CPU limit becomes NanoCPUs, the CPU request becomes CPU shares (1024 per CPU), the memory limit and request
+// become the memory limit and reservation. Docker has no hugepages limit, hugepages are only checked against the host.
+func ContainerResources(resources workloads.Resources) (container.Resources, error) {
+	var dockerResources container.Resources
+
+	cpuLimit, err := parseQuantity("limits.cpu", resources.Limits.CPU)
+	if err != nil {
+		return dockerResources, err
+	}
+	cpuRequest, err := parseQuantity("requests.cpu", resources.Requests.CPU)
+	if err != nil {
+		return dockerResources, err
+	}
+	memoryLimit, err := parseQuantity("limits.memory", resources.Limits.Memory)
+	if err != nil {
+		return dockerResources, err
+	}
+	memoryRequest, err := parseQuantity("requests.memory", resources.Requests.Memory)
+	if err != nil {
+		return dockerResources, err
+	}
+
+	dockerResources.NanoCPUs = cpuLimit.MilliValue() * 1000000
+	if !cpuRequest.IsZero() {
+		// the kernel refuses less than 2 shares
+		dockerResources.CPUShares = max(cpuRequest.MilliValue()*1024/1000, 2)
+	}
+	dockerResources.Memory = memoryLimit.Value()
+	dockerResources.MemoryReservation = memoryRequest.Value()
+	dockerResources.CpusetCpus = resources.CpusetCpus
+	dockerResources.CpusetMems = resources.CpusetMems
+	return dockerResources, nil
+}
+
+// applyResources sets the limits of a container. The devices of the host configuration share the Resources
+// struct with the limits and are kept.
+func applyResources(hostConfig *container.HostConfig, resources container.Resources) {
+	hostConfig.NanoCPUs = resources.NanoCPUs
+	hostConfig.CPUShares = resources.CPUShares
+	hostConfig.Memory = resources.Memory
+	hostConfig.MemoryReservation = resources.MemoryReservation
+	hostConfig.CpusetCpus = resources.CpusetCpus
+	hostConfig.CpusetMems = resources.CpusetMems
+}
@@ -0,0 +1,73 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package utils
+
+import (
+	"testing"
+
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types/container"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerResources(t *testing.T) {
+	var declared workloads.Resources
+	declared.Requests.CPU = "500m"
+	declared.Requests.Memory = "256Mi"
+	declared.Limits.CPU = "2"
+	declared.Limits.Memory = "1Gi"
+	declared.Limits.Hugepages2Mi = "512Mi"
+	declared.CpusetCpus = "2-3"
+	declared.CpusetMems = "0"
+
+	resources, err := ContainerResources(declared)
+	assert.NoError(t, err)
+	assert.Equal(t, container.Resources{
+		NanoCPUs:          2000000000,
+		CPUShares:         512,
+		Memory:            1 << 30,
+		MemoryReservation: 256 << 20,
+		CpusetCpus:        "2-3",
+		CpusetMems:        "0",
+	}, resources)
+
+	resources, err = ContainerResources(workloads.Resources{})
+	assert.NoError(t, err)
+	assert.Equal(t, container.Resources{}, resources)
+
+	declared.Limits.Memory = "lots"
+	_, err = ContainerResources(declared)
+	assert.ErrorContains(t, err, `invalid resources.limits.memory "lots"`)
+
+	declared.Limits.Memory = "-1Gi"
+	_, err = ContainerResources(declared)
+	assert.EqualError(t, err, `invalid resources.limits.memory "-1Gi": must not be negative`)
+}
+
+func TestConstructContainerConfig_Resources(t *testing.T) {
+	config := &parser.Configuration{
+		RunOnce: parser.RunOnce{MediaProxyMcm: workloads.MediaProxyMcmInstances{{ImageAndTag: "mcm/media-proxy:latest"}}},
+		WorkloadToBeRun: []workloads.WorkloadConfig{{
+			FfmpegPipeline: workloads.FfmpegPipelineConfig{Name: "ffmpeg-pipeline", ImageAndTag: "tiber-broadcast-suite:latest"},
+		}},
+	}
+	config.WorkloadToBeRun[0].FfmpegPipeline.Resources.Limits.CPU = "1500m"
+	config.WorkloadToBeRun[0].FfmpegPipeline.Resources.CpusetCpus = "4-5"
+	config.RunOnce.MediaProxyMcm[0].Resources.Limits.Memory = "invalid"
+
+	_, hostConfig, _ := PreviewContainerConfig(&general.Containers{Type: general.BcsPipelineFfmpeg, ContainerName: "ffmpeg-pipeline"}, config, logr.Discard())
+	assert.Equal(t, int64(1500000000), hostConfig.NanoCPUs)
+	assert.Equal(t, "4-5", hostConfig.CpusetCpus)
+	// the devices share the Resources struct with the limits
+	assert.Len(t, hostConfig.Devices, 2)
+
+	// invalid resources are reported by the validation before starting, the container is built without them
+	_, hostConfig, _ = PreviewContainerConfig(&general.Containers{Type: general.MediaProxyMCM}, config, logr.Discard())
+	assert.Zero(t, hostConfig.Memory)
+}
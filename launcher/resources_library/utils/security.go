@@ -0,0 +1,169 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	bcsv1 "bcs.pod.launcher.intel/api/v1"
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"github.com/docker/docker/api/types/container"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SecurityProfile selects the privileges the containers of BCS pipelines run with.
+type SecurityProfile string
+
+const (
+	// SecurityProfileMinimal grants only the capabilities and devices MTL/DPDK, VFIO and DRI need. It is the default.
+	SecurityProfileMinimal SecurityProfile = "minimal"
+	// SecurityProfilePrivileged runs the containers privileged with all capabilities.
+	SecurityProfilePrivileged SecurityProfile = "privileged"
+)
+
+// mediaCapabilities are the capabilities of the containers sending and receiving media in the minimal profile:
+// IPC_LOCK to lock the DMA memory of MTL/DPDK, NET_RAW and NET_ADMIN to drive the NIC and SYS_NICE for lcore scheduling.
+var mediaCapabilities = []string{"IPC_LOCK", "NET_ADMIN", "NET_RAW", "SYS_NICE"}
+
+// mediaDeviceCgroupRules allow the containers sending and receiving media in the minimal profile to open the VFIO
+// container device (/dev/vfio/vfio) and the DRI render nodes (/dev/dri), including the ones created after the
+// container started. The VFIO group devices (/dev/vfio/<group>) get a rule for their major, see vfioGroupCgroupRule.
+var mediaDeviceCgroupRules = []string{"c 10:196 rwm", "c 226:* rwm"}
+
+// procDevices lists the character and block devices of the host with their majors.
+var procDevices = "/proc/devices"
+
+// vfioGroupCgroupRule returns the cgroup rule allowing to open the VFIO group devices. Their major is allocated
+// dynamically by the vfio driver and read from procDevices, "" when the driver is not loaded. MCM Media Proxy only
+// gets /dev/vfio as a bind mount, which does not allow it to open the devices by itself.
+func vfioGroupCgroupRule() string {
+	data, err := os.ReadFile(procDevices)
+	if err != nil {
+		return ""
+	}
+	characterDevices := false
+	for _, line := range strings.Split(string(data), "\n") {
+		switch line = strings.TrimSpace(line); {
+		case line == "Character devices:":
+			characterDevices = true
+		case strings.HasSuffix(line, ":"):
+			characterDevices = false
+		case characterDevices:
+			if fields := strings.Fields(line); len(fields) == 2 && fields[1] == "vfio" {
+				return fmt.Sprintf("c %s:* rwm", fields[0])
+			}
+		}
+	}
+	return ""
+}
+
+// deviceVolumes are the hostPath volumes of a BCS deployment exposing the VFIO and DRI device nodes of the host.
+// Without privileges the container cannot open them, in the minimal profile the devices come from device plugins.
+var deviceVolumes = map[string]bool{"vfio": true, "dri-dev": true}
+
+// ParseSecurityProfile checks a configured security profile, an empty one is the minimal profile.
+func ParseSecurityProfile(profile string) (SecurityProfile, error) {
+	switch SecurityProfile(profile) {
+	case "", SecurityProfileMinimal:
+		return SecurityProfileMinimal, nil
+	case SecurityProfilePrivileged:
+		return SecurityProfilePrivileged, nil
+	}
+	return "", fmt.Errorf("unknown security profile %q, use %s or %s", profile, SecurityProfileMinimal, SecurityProfilePrivileged)
+}
+
+// BcsSecurityProfile returns the security profile of a BCS deployment. Unlike the launcher configuration, an empty one
+// is the privileged profile, so BcsConfig resources created before the profiles existed keep their hostPath devices.
+func BcsSecurityProfile(bcs *bcsv1.BcsConfigSpec) (SecurityProfile, error) {
+	if bcs.SecurityProfile == "" {
+		return SecurityProfilePrivileged, nil
+	}
+	return ParseSecurityProfile(bcs.SecurityProfile)
+}
+
+// ContainerSecurityProfile returns the security profile of a container: the one of its workload, otherwise the global one.
+func ContainerSecurityProfile(containerInfo *general.Containers, config *parser.Configuration) (SecurityProfile, error) {
+	profile := config.SecurityProfile
+	if containerInfo.Type == general.BcsPipelineFfmpeg || containerInfo.Type == general.BcsPipelineNmosClient {
+		if workloadProfile := config.WorkloadToBeRun[containerInfo.Id].SecurityProfile; workloadProfile != "" {
+			profile = workloadProfile
+		}
+	}
+	return ParseSecurityProfile(profile)
+}
+
+// handlesMedia tells whether a container sends or receives media and needs the NIC, VFIO and DRI devices.
+func handlesMedia(workload general.Workload) bool {
+	return workload == general.MediaProxyMCM || workload == general.BcsPipelineFfmpeg
+}
+
+// applySecurityProfile sets the privileges of a Docker container according to its security profile.
+func applySecurityProfile(hostConfig *container.HostConfig, workload general.Workload, profile SecurityProfile) {
+	if profile == SecurityProfilePrivileged {
+		hostConfig.Privileged = true
+		if workload == general.BcsPipelineFfmpeg {
+			hostConfig.CapAdd = []string{"ALL"}
+		}
+		return
+	}
+	if handlesMedia(workload) {
+		hostConfig.CapAdd = append([]string{}, mediaCapabilities...)
+		hostConfig.DeviceCgroupRules = append([]string{}, mediaDeviceCgroupRules...)
+		if rule := vfioGroupCgroupRule(); rule != "" {
+			hostConfig.DeviceCgroupRules = append(hostConfig.DeviceCgroupRules, rule)
+		}
+	}
+}
+
+// ValidateBcsDevices checks the devices of the application container of a BCS deployment. When it opts into the
+// minimal profile the VFIO and DRI devices have to be allocated by device plugins, so at least one device has to be declared.
+func ValidateBcsDevices(bcs *bcsv1.BcsConfigSpec) error {
+	profile, err := BcsSecurityProfile(bcs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", bcs.Name, err)
+	}
+	for name, count := range bcs.App.Devices {
+		if count <= 0 {
+			return fmt.Errorf("%s: app.devices: %s needs a positive count, got %d", bcs.Name, name, count)
+		}
+	}
+	if profile == SecurityProfileMinimal && len(bcs.App.Devices) == 0 {
+		return fmt.Errorf("%s: the %s security profile needs the VFIO and DRI devices allocated by device plugins, "+
+			"declare their resources under app.devices or use the %s profile", bcs.Name, SecurityProfileMinimal, SecurityProfilePrivileged)
+	}
+	return nil
+}
+
+// K8sSecurityContext returns the security context of a container of a BCS deployment. In the minimal profile
+// the devices are not reachable through hostPath volumes, they have to be allocated by a device plugin.
+func K8sSecurityContext(profile SecurityProfile, mediaIO bool) *corev1.SecurityContext {
+	if profile == SecurityProfilePrivileged {
+		return &corev1.SecurityContext{
+			RunAsUser:  int64Ptr(0),
+			Privileged: boolPtr(true),
+			Capabilities: &corev1.Capabilities{
+				Add: []corev1.Capability{"ALL"},
+			},
+		}
+	}
+	securityContext := &corev1.SecurityContext{
+		RunAsUser:                int64Ptr(0),
+		Privileged:               boolPtr(false),
+		AllowPrivilegeEscalation: boolPtr(false),
+	}
+	if mediaIO {
+		securityContext.Capabilities = &corev1.Capabilities{}
+		for _, capability := range mediaCapabilities {
+			securityContext.Capabilities.Add = append(securityContext.Capabilities.Add, corev1.Capability(capability))
+		}
+	}
+	return securityContext
+}
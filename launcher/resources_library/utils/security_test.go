@@ -0,0 +1,213 @@
+/*
+ * SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+ *
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	bcsv1 "bcs.pod.launcher.intel/api/v1"
+	"bcs.pod.launcher.intel/resources_library/parser"
+	"bcs.pod.launcher.intel/resources_library/resources/general"
+	"bcs.pod.launcher.intel/resources_library/workloads"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestParseSecurityProfile(t *testing.T) {
+	profile, err := ParseSecurityProfile("")
+	assert.NoError(t, err)
+	assert.Equal(t, SecurityProfileMinimal, profile)
+
+	profile, err = ParseSecurityProfile("privileged")
+	assert.NoError(t, err)
+	assert.Equal(t, SecurityProfilePrivileged, profile)
+
+	_, err = ParseSecurityProfile("root")
+	assert.EqualError(t, err, `unknown security profile "root", use minimal or privileged`)
+}
+
+func TestContainerSecurityProfile(t *testing.T) {
+	config := &parser.Configuration{
+		SecurityProfile: "privileged",
+		WorkloadToBeRun: []workloads.WorkloadConfig{{SecurityProfile: "minimal"}, {}},
+	}
+
+	profile, err := ContainerSecurityProfile(&general.Containers{Type: general.MediaProxyMCM}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, SecurityProfilePrivileged, profile)
+
+	profile, err = ContainerSecurityProfile(&general.Containers{Type: general.BcsPipelineFfmpeg, Id: 0}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, SecurityProfileMinimal, profile)
+
+	profile, err = ContainerSecurityProfile(&general.Containers{Type: general.BcsPipelineNmosClient, Id: 1}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, SecurityProfilePrivileged, profile)
+}
+
+// withProcDevices makes the tests read the devices of the host from a file holding the given content.
+func withProcDevices(t *testing.T, content string) {
+	file := filepath.Join(t.TempDir(), "devices")
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	previous := procDevices
+	procDevices = file
+	t.Cleanup(func() { procDevices = previous })
+}
+
+func TestConstructContainerConfig_SecurityProfile(t *testing.T) {
+	withProcDevices(t, "Character devices:\n  1 mem\n226 drm\n\nBlock devices:\n  8 sd\n")
+	config := &parser.Configuration{
+		RunOnce: parser.RunOnce{MediaProxyAgent: workloads.MediaProxyAgentConfig{ImageAndTag: "mcm/mesh-agent:latest", RestPort: "8100", GRPCPort: "50051"}},
+		WorkloadToBeRun: []workloads.WorkloadConfig{{
+			FfmpegPipeline: workloads.FfmpegPipelineConfig{
+				Name:        "ffmpeg-pipeline",
+				ImageAndTag: "tiber-broadcast-suite:latest",
+				Devices:     workloads.Devices{Vfio: "/dev/vfio", Dri: "/dev/dri"},
+			},
+		}},
+	}
+	ffmpeg := &general.Containers{Type: general.BcsPipelineFfmpeg, ContainerName: "ffmpeg-pipeline"}
+	agent := &general.Containers{Type: general.MediaProxyAgent}
+
+	t.Run("Minimal profile by default", func(t *testing.T) {
+		_, hostConfig, _ := PreviewContainerConfig(ffmpeg, config, logr.Discard())
+		assert.False(t, hostConfig.Privileged)
+		assert.Equal(t, strslice.StrSlice{"IPC_LOCK", "NET_ADMIN", "NET_RAW", "SYS_NICE"}, hostConfig.CapAdd)
+		assert.Equal(t, []string{"c 10:196 rwm", "c 226:* rwm"}, hostConfig.DeviceCgroupRules)
+		assert.Len(t, hostConfig.Devices, 2)
+
+		_, hostConfig, _ = PreviewContainerConfig(agent, config, logr.Discard())
+		assert.False(t, hostConfig.Privileged)
+		assert.Empty(t, hostConfig.CapAdd)
+		assert.Empty(t, hostConfig.DeviceCgroupRules)
+	})
+
+	t.Run("Privileged profile as opt-in", func(t *testing.T) {
+		config.SecurityProfile = "privileged"
+		defer func() { config.SecurityProfile = "" }()
+
+		_, hostConfig, _ := PreviewContainerConfig(ffmpeg, config, logr.Discard())
+		assert.True(t, hostConfig.Privileged)
+		assert.Equal(t, strslice.StrSlice{"ALL"}, hostConfig.CapAdd)
+		assert.Empty(t, hostConfig.DeviceCgroupRules)
+
+		_, hostConfig, _ = PreviewContainerConfig(agent, config, logr.Discard())
+		assert.True(t, hostConfig.Privileged)
+	})
+
+	t.Run("Unknown profile falls back to the minimal one", func(t *testing.T) {
+		config.SecurityProfile = "root"
+		defer func() { config.SecurityProfile = "" }()
+
+		_, hostConfig, _ := PreviewContainerConfig(ffmpeg, config, logr.Discard())
+		assert.False(t, hostConfig.Privileged)
+	})
+}
+
+func TestBcsSecurityProfile(t *testing.T) {
+	profile, err := BcsSecurityProfile(&bcsv1.BcsConfigSpec{})
+	assert.NoError(t, err)
+	assert.Equal(t, SecurityProfilePrivileged, profile, "BcsConfig resources without a profile keep privileged containers")
+
+	profile, err = BcsSecurityProfile(&bcsv1.BcsConfigSpec{SecurityProfile: "minimal"})
+	assert.NoError(t, err)
+	assert.Equal(t, SecurityProfileMinimal, profile)
+}
+
+func TestCreateBcsDeployment_SecurityProfile(t *testing.T) {
+	spec := &bcsv1.BcsConfigSpec{Name: "bcs-pipeline", Namespace: "bcs", SecurityProfile: "minimal"}
+
+	deployment := CreateBcsDeployment(spec)
+	nmos, app := deployment.Spec.Template.Spec.Containers[0].SecurityContext, deployment.Spec.Template.Spec.Containers[1].SecurityContext
+	assert.False(t, *nmos.Privileged)
+	assert.False(t, *nmos.AllowPrivilegeEscalation)
+	assert.Nil(t, nmos.Capabilities)
+	assert.False(t, *app.Privileged)
+	assert.Equal(t, []corev1.Capability{"IPC_LOCK", "NET_ADMIN", "NET_RAW", "SYS_NICE"}, app.Capabilities.Add)
+	assert.Equal(t, int64(0), *app.RunAsUser)
+
+	spec.SecurityProfile = "privileged"
+	deployment = CreateBcsDeployment(spec)
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		assert.True(t, *container.SecurityContext.Privileged)
+		assert.Equal(t, []corev1.Capability{"ALL"}, container.SecurityContext.Capabilities.Add)
+	}
+}
+
+func TestCreateBcsDeployment_Devices(t *testing.T) {
+	volumeNames := func(deployment *appsv1.Deployment) []string {
+		var names []string
+		for _, mount := range deployment.Spec.Template.Spec.Containers[1].VolumeMounts {
+			names = append(names, mount.Name)
+		}
+		for _, volume := range deployment.Spec.Template.Spec.Volumes {
+			names = append(names, volume.Name)
+		}
+		return names
+	}
+	spec := &bcsv1.BcsConfigSpec{Name: "bcs-pipeline", Namespace: "bcs", SecurityProfile: "minimal", App: bcsv1.App{
+		Devices: map[string]int64{"intel.com/intel_sriov_netdevice": 2, "gpu.intel.com/i915": 1},
+	}}
+
+	deployment := CreateBcsDeployment(spec)
+	app := deployment.Spec.Template.Spec.Containers[1]
+	assert.Equal(t, "2", app.Resources.Requests.Name("intel.com/intel_sriov_netdevice", resource.DecimalSI).String())
+	assert.Equal(t, "2", app.Resources.Limits.Name("intel.com/intel_sriov_netdevice", resource.DecimalSI).String())
+	assert.Equal(t, "1", app.Resources.Limits.Name("gpu.intel.com/i915", resource.DecimalSI).String())
+	assert.NotContains(t, volumeNames(deployment), "vfio", "the device plugins create the device nodes")
+	assert.NotContains(t, volumeNames(deployment), "dri-dev")
+	assert.Contains(t, volumeNames(deployment), "dri")
+
+	spec.SecurityProfile = "privileged"
+	deployment = CreateBcsDeployment(spec)
+	assert.Contains(t, volumeNames(deployment), "vfio")
+	assert.Contains(t, volumeNames(deployment), "dri-dev")
+}
+
+func TestConstructContainerConfig_MediaProxyMcmDevices(t *testing.T) {
+	withProcDevices(t, "Character devices:\n  1 mem\n 10 misc\n226 drm\n243 vfio\n\nBlock devices:\n  8 sd\n250 vfio\n")
+	config := &parser.Configuration{
+		RunOnce: parser.RunOnce{MediaProxyMcm: workloads.MediaProxyMcmInstances{{
+			Name:          "media-proxy",
+			ImageAndTag:   "mcm/media-proxy:latest",
+			InterfaceName: "ens801f0",
+			Volumes:       []string{"/dev/vfio:/dev/vfio"},
+		}}},
+	}
+
+	_, hostConfig, _ := PreviewContainerConfig(&general.Containers{Type: general.MediaProxyMCM}, config, logr.Discard())
+	assert.False(t, hostConfig.Privileged)
+	assert.Equal(t, []string{"/dev/vfio:/dev/vfio"}, hostConfig.Binds)
+	assert.Equal(t, strslice.StrSlice{"IPC_LOCK", "NET_ADMIN", "NET_RAW", "SYS_NICE"}, hostConfig.CapAdd)
+	assert.Equal(t, []string{"c 10:196 rwm", "c 226:* rwm", "c 243:* rwm"}, hostConfig.DeviceCgroupRules,
+		"the VFIO group devices of the bind mount can be opened")
+
+	withProcDevices(t, "Character devices:\n  1 mem\n")
+	_, hostConfig, _ = PreviewContainerConfig(&general.Containers{Type: general.MediaProxyMCM}, config, logr.Discard())
+	assert.Equal(t, []string{"c 10:196 rwm", "c 226:* rwm"}, hostConfig.DeviceCgroupRules, "no rule without the vfio driver")
+}
+
+func TestValidateBcsDevices(t *testing.T) {
+	assert.NoError(t, ValidateBcsDevices(&bcsv1.BcsConfigSpec{Name: "bcs-pipeline"}), "existing resources without a profile deploy as before")
+
+	spec := &bcsv1.BcsConfigSpec{Name: "bcs-pipeline", SecurityProfile: "minimal"}
+	assert.EqualError(t, ValidateBcsDevices(spec), "bcs-pipeline: the minimal security profile needs the VFIO and DRI devices "+
+		"allocated by device plugins, declare their resources under app.devices or use the privileged profile")
+
+	spec.App.Devices = map[string]int64{"intel.com/intel_sriov_netdevice": 0}
+	assert.EqualError(t, ValidateBcsDevices(spec), "bcs-pipeline: app.devices: intel.com/intel_sriov_netdevice needs a positive count, got 0")
+
+	spec.App.Devices["intel.com/intel_sriov_netdevice"] = 1
+	assert.NoError(t, ValidateBcsDevices(spec))
+
+	assert.NoError(t, ValidateBcsDevices(&bcsv1.BcsConfigSpec{SecurityProfile: "privileged"}))
+}
@@ -0,0 +1,27 @@
+//
+//  SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+
+package workloads
+
+// HealthCheck describes how Docker checks the service of a container. At most one of Command, TCPPort and
+// HTTPPort is set; when none is, the default probe of the container type is used.
+type HealthCheck struct {
+	Disable     bool   `yaml:"disable,omitempty"`     // turns off the health check, including the one of the image
+	Command     string `yaml:"command,omitempty"`     // run by the shell of the container, healthy on exit code 0
+	TCPPort     int    `yaml:"tcpPort,omitempty"`     // healthy when the port accepts connections
+	HTTPPort    int    `yaml:"httpPort,omitempty"`    // healthy when a GET of HTTPPath answers with 2xx or 3xx
+	HTTPPath    string `yaml:"httpPath,omitempty"`    // / when empty
+	Interval    string `yaml:"interval,omitempty"`    // time between two checks, e.g. 10s
+	Timeout     string `yaml:"timeout,omitempty"`     // time a check may take before it counts as failed
+	StartPeriod string `yaml:"startPeriod,omitempty"` // time the service gets to start before failed checks count
+	Retries     int    `yaml:"retries,omitempty"`     // consecutive failed checks after which the container is unhealthy
+}
+
+// RestartPolicy tells Docker when to restart a container, including after a daemon restart or a reboot.
+type RestartPolicy struct {
+	Name              string `yaml:"name,omitempty" jsonschema:"enum=no|always|unless-stopped|on-failure"`
+	MaximumRetryCount int    `yaml:"maximumRetryCount,omitempty"` // only with on-failure, 0 retries forever
+}
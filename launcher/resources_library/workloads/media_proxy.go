@@ -0,0 +1,48 @@
+//
+//  SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+
+package workloads
+
+// DefaultMediaProxyName is the container name of an MCM Media Proxy instance without a name.
+const DefaultMediaProxyName = "media-proxy"
+
+// MediaProxyMcmInstances are the MCM Media Proxy instances of the host, e.g. one per NIC. Each instance runs in
+// its own container named after the instance.
+type MediaProxyMcmInstances []MediaProxyMcmConfig
+
+// UnmarshalYAML reads either a list of instances or, as in older configuration files, a single instance.
+func (m *MediaProxyMcmInstances) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var instances []MediaProxyMcmConfig
+	if err := unmarshal(&instances); err == nil {
+		*m = instances
+		return nil
+	}
+	var instance MediaProxyMcmConfig
+	if err := unmarshal(&instance); err != nil {
+		return err
+	}
+	*m = MediaProxyMcmInstances{instance}
+	return nil
+}
+
+// ContainerName returns the name of the container of the instance.
+func (c MediaProxyMcmConfig) ContainerName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return DefaultMediaProxyName
+}
+
+// Find returns the index of the instance a workload attaches to: the instance with the given container name,
+// the first instance when name is empty. It returns -1 when there is no such instance.
+func (m MediaProxyMcmInstances) Find(name string) int {
+	for i, instance := range m {
+		if name == "" || instance.ContainerName() == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,31 @@
+//
+//  SPDX-FileCopyrightText: Copyright (c) 2024 Intel Corporation
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+
+package workloads
+
+// HwResources are the CPU, memory and hugepages requested and limited for a container, as Kubernetes quantities.
+type HwResources struct {
+	Requests struct {
+		CPU          string `json:"cpu" yaml:"cpu"`
+		Memory       string `json:"memory" yaml:"memory"`
+		Hugepages1Gi string `json:"hugepages-1Gi,omitempty" yaml:"hugepages-1Gi,omitempty"`
+		Hugepages2Mi string `json:"hugepages-2Mi,omitempty" yaml:"hugepages-2Mi,omitempty"`
+	} `json:"requests" yaml:"requests"`
+	Limits struct {
+		CPU          string `json:"cpu" yaml:"cpu"`
+		Memory       string `json:"memory" yaml:"memory"`
+		Hugepages1Gi string `json:"hugepages-1Gi,omitempty" yaml:"hugepages-1Gi,omitempty"`
+		Hugepages2Mi string `json:"hugepages-2Mi,omitempty" yaml:"hugepages-2Mi,omitempty"`
+	} `json:"limits" yaml:"limits"`
+}
+
+// Resources are the hardware resources of a container in docker mode: the HwResources of kubernetes mode
+// and the host CPUs and NUMA nodes the container is restricted to.
+type Resources struct {
+	HwResources `yaml:",inline"`
+	CpusetCpus  string `yaml:"cpusetCpus,omitempty"` // host CPUs the container may run on, e.g. 0-3,8
+	CpusetMems  string `yaml:"cpusetMems,omitempty"` // NUMA nodes the container may allocate memory on, e.g. 0
+}
@@ -7,22 +7,33 @@
 package workloads
 
 type MediaProxyAgentConfig struct {
-	ImageAndTag string        `yaml:"imageAndTag"`
-	GRPCPort    string        `yaml:"gRPCPort"`
-	RestPort    string        `yaml:"restPort"`
-	Network     NetworkConfig `yaml:"custom_network"`
+	ImageAndTag   string        `yaml:"imageAndTag"`
+	GRPCPort      string        `yaml:"gRPCPort"`
+	RestPort      string        `yaml:"restPort"`
+	Network       NetworkConfig `yaml:"custom_network"`
+	Resources     Resources     `yaml:"resources"`
+	HealthCheck   HealthCheck   `yaml:"healthCheck"`
+	RestartPolicy RestartPolicy `yaml:"restartPolicy"`
 }
 
 type MediaProxyMcmConfig struct {
+	Name          string        `yaml:"name,omitempty"` // container name, media-proxy when empty
 	ImageAndTag   string        `yaml:"imageAndTag"`
 	InterfaceName string        `yaml:"interfaceName"`
+	SDKPort       string        `yaml:"sdkPort,omitempty"` // port the SDK of the pipelines connects to, the default of MCM Media Proxy when empty
 	Volumes       []string      `yaml:"volumes"`
 	Network       NetworkConfig `yaml:"custom_network"`
+	Resources     Resources     `yaml:"resources"`
+	HealthCheck   HealthCheck   `yaml:"healthCheck"`
+	RestartPolicy RestartPolicy `yaml:"restartPolicy"`
 }
 
 type WorkloadConfig struct {
-	FfmpegPipeline FfmpegPipelineConfig `yaml:"ffmpegPipeline"`
-	NmosClient     NmosClientConfig     `yaml:"nmosClient"`
+	FfmpegPipeline  FfmpegPipelineConfig `yaml:"ffmpegPipeline"`
+	NmosClient      NmosClientConfig     `yaml:"nmosClient"`
+	ImageSource     string               `yaml:"imageSource"`                                          // overrides the global image source for the images of the workload
+	SecurityProfile string               `yaml:"securityProfile" jsonschema:"enum=minimal|privileged"` // overrides the global security profile for the containers of the workload
+	MediaProxy      string               `yaml:"mediaProxy,omitempty"`                                 // name of the MCM Media Proxy instance the pipeline attaches to, the first one when empty
 }
 
 type Volumes struct {
@@ -49,6 +60,16 @@ type FfmpegPipelineConfig struct {
 	Volumes              Volumes       `yaml:"volumes"`
 	Devices              Devices       `yaml:"devices"`
 	Network              NetworkConfig `yaml:"custom_network"`
+	Resources            Resources     `yaml:"resources"`
+	CPUPinning           CPUPinning    `yaml:"cpuPinning"`
+	HealthCheck          HealthCheck   `yaml:"healthCheck"`
+	RestartPolicy        RestartPolicy `yaml:"restartPolicy"`
+}
+
+// CPUPinning allocates cores local to the NIC of an FFmpeg pipeline, so its lcores do not cross NUMA nodes.
+type CPUPinning struct {
+	Cores      int    `yaml:"cores"`                // number of cores allocated to the pipeline, 0 disables the pinning
+	PCIAddress string `yaml:"pciAddress,omitempty"` // PCI address of the VF, taken from VFIO_PORT_TX or VFIO_PORT_RX of the workload when empty
 }
 
 type NmosClientConfig struct {
@@ -61,10 +82,26 @@ type NmosClientConfig struct {
 	NmosPort                int           `yaml:"nmosPort"`
 	FfmpegConnectionAddress string        `yaml:"ffmpegConnectionAddress"`
 	FfmpegConnectionPort    string        `yaml:"ffmpegConnectionPort"`
+	Resources               Resources     `yaml:"resources"`
+	HealthCheck             HealthCheck   `yaml:"healthCheck"`
+	RestartPolicy           RestartPolicy `yaml:"restartPolicy"`
 }
 
 type NetworkConfig struct {
 	Enable bool   `yaml:"enable"`
 	Name   string `yaml:"name,omitempty"`
 	IP     string `yaml:"ip,omitempty"`
+	// The fields below describe the network created by the launcher when it does not exist yet.
+	Driver        string            `yaml:"driver,omitempty"`        // bridge (default) | macvlan | ipvlan
+	Subnet        string            `yaml:"subnet,omitempty"`        // CIDR, required to assign static IPs
+	Gateway       string            `yaml:"gateway,omitempty"`       // gateway IP within the subnet
+	IPRange       string            `yaml:"ipRange,omitempty"`       // CIDR within the subnet dynamic IPs are allocated from
+	Parent        string            `yaml:"parent,omitempty"`        // host interface used by macvlan and ipvlan networks, e.g. the ST 2110 NIC
+	DriverOptions map[string]string `yaml:"driverOptions,omitempty"` // e.g. macvlan_mode: bridge
+	IPAM          IPAMConfig        `yaml:"ipam,omitempty"`
+}
+
+type IPAMConfig struct {
+	Driver  string            `yaml:"driver,omitempty"`
+	Options map[string]string `yaml:"options,omitempty"`
 }
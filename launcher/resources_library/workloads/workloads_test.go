@@ -97,3 +97,92 @@ ip: 192.168.1.1
 	assert.Equal(t, "test-network", config.Name)
 	assert.Equal(t, "192.168.1.1", config.IP)
 }
+
+func TestNetworkConfig_UnmarshalYAML_NetworkDefinition(t *testing.T) {
+	yamlData := `
+enable: true
+name: st2110-net
+ip: 192.168.50.10
+driver: macvlan
+subnet: 192.168.50.0/24
+gateway: 192.168.50.1
+ipRange: 192.168.50.128/25
+parent: ens801f0
+driverOptions:
+  macvlan_mode: bridge
+ipam:
+  driver: default
+  options:
+    foo: bar
+`
+	var config NetworkConfig
+	err := yaml.Unmarshal([]byte(yamlData), &config)
+	assert.NoError(t, err)
+	assert.Equal(t, "macvlan", config.Driver)
+	assert.Equal(t, "192.168.50.0/24", config.Subnet)
+	assert.Equal(t, "192.168.50.1", config.Gateway)
+	assert.Equal(t, "192.168.50.128/25", config.IPRange)
+	assert.Equal(t, "ens801f0", config.Parent)
+	assert.Equal(t, map[string]string{"macvlan_mode": "bridge"}, config.DriverOptions)
+	assert.Equal(t, IPAMConfig{Driver: "default", Options: map[string]string{"foo": "bar"}}, config.IPAM)
+}
+
+func TestResources_UnmarshalYAML(t *testing.T) {
+	yamlData := `
+name: test-pipeline
+resources:
+  requests:
+    cpu: "2"
+    memory: 2Gi
+  limits:
+    cpu: "4"
+    memory: 4Gi
+    hugepages-2Mi: 1Gi
+  cpusetCpus: 2-5
+  cpusetMems: "0"
+`
+	var config FfmpegPipelineConfig
+	err := yaml.Unmarshal([]byte(yamlData), &config)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", config.Resources.Requests.CPU)
+	assert.Equal(t, "2Gi", config.Resources.Requests.Memory)
+	assert.Equal(t, "4", config.Resources.Limits.CPU)
+	assert.Equal(t, "4Gi", config.Resources.Limits.Memory)
+	assert.Equal(t, "1Gi", config.Resources.Limits.Hugepages2Mi)
+	assert.Equal(t, "2-5", config.Resources.CpusetCpus)
+	assert.Equal(t, "0", config.Resources.CpusetMems)
+}
+
+func TestMediaProxyMcmInstances_UnmarshalYAML(t *testing.T) {
+	yamlData := `
+legacy:
+  imageAndTag: mcm/media-proxy:latest
+  interfaceName: eth0
+instances:
+  - imageAndTag: mcm/media-proxy:latest
+    interfaceName: eth0
+  - name: media-proxy-nic1
+    imageAndTag: mcm/media-proxy:latest
+    interfaceName: eth1
+    sdkPort: "8003"
+`
+	var config struct {
+		Legacy    MediaProxyMcmInstances `yaml:"legacy"`
+		Instances MediaProxyMcmInstances `yaml:"instances"`
+	}
+	err := yaml.Unmarshal([]byte(yamlData), &config)
+	assert.NoError(t, err)
+	assert.Equal(t, MediaProxyMcmInstances{{ImageAndTag: "mcm/media-proxy:latest", InterfaceName: "eth0"}}, config.Legacy)
+	assert.Len(t, config.Instances, 2)
+	assert.Equal(t, "media-proxy", config.Instances[0].ContainerName())
+	assert.Equal(t, "media-proxy-nic1", config.Instances[1].ContainerName())
+	assert.Equal(t, "8003", config.Instances[1].SDKPort)
+
+	assert.Equal(t, 0, config.Instances.Find(""))
+	assert.Equal(t, 1, config.Instances.Find("media-proxy-nic1"))
+	assert.Equal(t, -1, config.Instances.Find("media-proxy-nic2"))
+	assert.Equal(t, -1, MediaProxyMcmInstances{}.Find(""))
+
+	err = yaml.Unmarshal([]byte("instances: eth0"), &config)
+	assert.Error(t, err)
+}